@@ -80,7 +80,7 @@ func main() {
 	mux.HandleFunc("GET /api/raw", m.H(handleRaw))
 
 	log.Println("🚀 Server running at http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", mux))
+	log.Fatal(m.Server(":8080", mux).ListenAndServe())
 }
 
 // ============================================================================
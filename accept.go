@@ -0,0 +1,127 @@
+package m
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MediaRange is one entry of an Accept header, such as "text/html;level=1;q=0.8"
+type MediaRange struct {
+	// Type is the lowercased media range, e.g. "application/json", "text/*" or "*/*"
+	Type string
+	// Params holds the parameters other than q
+	Params map[string]string
+	// Quality is the q value, 1 when absent
+	Quality float64
+}
+
+// specificity ranks how narrowly the range matches: exact types above
+// wildcard subtypes above "*/*", and ranges with parameters above those without
+func (mr MediaRange) specificity() int {
+	var s int
+	switch {
+	case mr.Type == "*/*":
+		s = 0
+	case strings.HasSuffix(mr.Type, "/*"):
+		s = 2
+	default:
+		s = 4
+	}
+	if len(mr.Params) > 0 {
+		s++
+	}
+	return s
+}
+
+// matches reports whether the range covers mediaType with the given parameters
+func (mr MediaRange) matches(mediaType string, params map[string]string) bool {
+	if !mediaTypeMatches(mr.Type, mediaType) {
+		return false
+	}
+	for key, value := range mr.Params {
+		if !strings.EqualFold(params[key], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseAccept parses an Accept header into its media ranges, ordered by preference
+// as in RFC 9110: by quality, then by specificity, then by position in the header.
+// Malformed entries and entries with an invalid q value are skipped
+func ParseAccept(header string) []MediaRange {
+	var ranges []MediaRange
+	for _, entry := range strings.Split(header, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		// Some clients send a bare "*" for "*/*"
+		if entry == "*" || strings.HasPrefix(entry, "*;") {
+			entry = "*/*" + entry[1:]
+		}
+
+		mediaType, params, err := mime.ParseMediaType(entry)
+		if err != nil || !strings.Contains(mediaType, "/") {
+			continue
+		}
+
+		mr := MediaRange{Type: mediaType, Quality: 1}
+		if q, ok := params["q"]; ok {
+			quality, err := strconv.ParseFloat(q, 64)
+			if err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+			mr.Quality = quality
+			delete(params, "q")
+		}
+		if len(params) > 0 {
+			mr.Params = params
+		}
+		ranges = append(ranges, mr)
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].Quality != ranges[j].Quality {
+			return ranges[i].Quality > ranges[j].Quality
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
+
+// BestMatch returns the offered media type the client prefers according to the
+// accept header, or "" if it accepts none of them. Each offer is weighed by the
+// most specific range matching it, so "text/*;q=0.5, text/html" prefers text/html.
+// Ties go to the earlier offer, as does an empty accept header
+func BestMatch(accept string, offered []string) string {
+	if len(offered) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offered[0]
+	}
+
+	ranges := ParseAccept(accept)
+	best, bestQuality := "", 0.0
+	for _, offer := range offered {
+		mediaType, params, err := mime.ParseMediaType(offer)
+		if err != nil {
+			continue
+		}
+
+		quality, specificity := 0.0, -1
+		for _, mr := range ranges {
+			if s := mr.specificity(); s > specificity && mr.matches(mediaType, params) {
+				quality, specificity = mr.Quality, s
+			}
+		}
+
+		if quality > bestQuality {
+			best, bestQuality = offer, quality
+		}
+	}
+	return best
+}
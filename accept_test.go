@@ -0,0 +1,89 @@
+package m
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAccept(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected []MediaRange
+	}{
+		{
+			name:   "weighted",
+			header: "text/html;q=0.9, application/json;q=1.0",
+			expected: []MediaRange{
+				{Type: "application/json", Quality: 1},
+				{Type: "text/html", Quality: 0.9},
+			},
+		},
+		{
+			name:   "specificity breaks ties",
+			header: "*/*, text/*, text/html;level=1, text/html",
+			expected: []MediaRange{
+				{Type: "text/html", Params: map[string]string{"level": "1"}, Quality: 1},
+				{Type: "text/html", Quality: 1},
+				{Type: "text/*", Quality: 1},
+				{Type: "*/*", Quality: 1},
+			},
+		},
+		{
+			name:   "header order breaks remaining ties",
+			header: "application/xml;q=0.5, application/json;q=0.5",
+			expected: []MediaRange{
+				{Type: "application/xml", Quality: 0.5},
+				{Type: "application/json", Quality: 0.5},
+			},
+		},
+		{
+			name:   "invalid entries are skipped",
+			header: "text/plain;q=2, nonsense, ;;, Application/JSON;q=0.8, *;q=0.1",
+			expected: []MediaRange{
+				{Type: "application/json", Quality: 0.8},
+				{Type: "*/*", Quality: 0.1},
+			},
+		},
+		{name: "empty", header: "", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseAccept(tt.header)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseAccept(%q) = %+v, expected %+v", tt.header, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBestMatch(t *testing.T) {
+	offered := []string{"text/html", "application/json"}
+
+	tests := []struct {
+		name     string
+		accept   string
+		offered  []string
+		expected string
+	}{
+		{"higher quality wins", "text/html;q=0.9, application/json;q=1.0", offered, "application/json"},
+		{"offer order breaks ties", "text/html, application/json", offered, "text/html"},
+		{"wildcard", "application/*", offered, "application/json"},
+		{"most specific range decides", "text/*;q=0.5, text/html;q=0.1, */*;q=0.3", offered, "application/json"},
+		{"q=0 excludes", "text/html;q=0, */*;q=0.1", offered, "application/json"},
+		{"nothing acceptable", "image/png", offered, ""},
+		{"empty header takes the first offer", "", offered, "text/html"},
+		{"range parameters must match", "text/html;level=1;q=1, text/html;q=0.2, application/json;q=0.5", offered, "application/json"},
+		{"offer with parameters", "text/plain;charset=utf-8", []string{"text/plain; charset=utf-8"}, "text/plain; charset=utf-8"},
+		{"no offers", "*/*", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BestMatch(tt.accept, tt.offered); got != tt.expected {
+				t.Errorf("BestMatch(%q, %q) = %q, expected %q", tt.accept, tt.offered, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package m
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+type benchOrder struct {
+	ID       int64             `json:"id"`
+	Customer User              `json:"customer"`
+	Items    []benchItem       `json:"items"`
+	Tags     []string          `json:"tags"`
+	Meta     map[string]string `json:"meta"`
+}
+
+type benchItem struct {
+	SKU   string  `json:"sku"`
+	Qty   int     `json:"qty"`
+	Price float64 `json:"price"`
+}
+
+func benchPayloads() map[string]any {
+	order := func(items int) benchOrder {
+		o := benchOrder{
+			ID:       42,
+			Customer: User{Name: "Alice", Email: "alice@example.com", Age: 30},
+			Tags:     []string{"priority", "gift"},
+			Meta:     map[string]string{"channel": "web", "coupon": "SPRING"},
+		}
+		for i := range items {
+			o.Items = append(o.Items, benchItem{SKU: "SKU-" + string(rune('A'+i%26)), Qty: i + 1, Price: 9.99})
+		}
+		return o
+	}
+	return map[string]any{
+		"small":  User{Name: "Alice", Email: "alice@example.com", Age: 30},
+		"medium": order(10),
+		"large":  order(1000),
+	}
+}
+
+// discardWriter is a ResponseWriter that keeps nothing, so benchmarks measure encoding only
+type discardWriter struct {
+	header http.Header
+}
+
+func (d *discardWriter) Header() http.Header         { return d.header }
+func (d *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardWriter) WriteHeader(int)             {}
+
+var benchBufPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// pooledEncode encodes into a pooled buffer and writes it in one call
+func pooledEncode(w io.Writer, v any) error {
+	buf := benchBufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer benchBufPool.Put(buf)
+
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// BenchmarkJSONResponse compares the ways handleCommonTypes can encode a struct:
+// json.Marshal plus one Write (the default), json.Encoder straight to the
+// response, and json.Encoder into a pooled buffer
+func BenchmarkJSONResponse(b *testing.B) {
+	strategies := []struct {
+		name string
+		opt  Option
+	}{
+		{"marshal", WithJSONMarshal(json.Marshal)},
+		{"encoder", WithJSONMarshal(nil)},
+		{"pooled", WithJSONEncode(pooledEncode)},
+	}
+	payloads := benchPayloads()
+
+	for _, size := range []string{"small", "medium", "large"} {
+		for _, strategy := range strategies {
+			b.Run(size+"/"+strategy.name, func(b *testing.B) {
+				Reset()
+				Configure(strategy.opt)
+				defer Reset()
+
+				w := &discardWriter{header: make(http.Header)}
+				b.ReportAllocs()
+				for range b.N {
+					if err := handleCommonTypes(w, payloads[size]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkJSONStreamThreshold compares the buffered, Content-Length path with
+// element-by-element streaming for slices of growing length
+func BenchmarkJSONStreamThreshold(b *testing.B) {
+	for _, n := range []int{10, 1000, 100000} {
+		items := make([]benchItem, n)
+		for i := range items {
+			items[i] = benchItem{SKU: "SKU-1", Qty: i, Price: 9.99}
+		}
+
+		for _, mode := range []struct {
+			name      string
+			threshold int
+		}{
+			{"buffered", n},
+			{"streamed", n - 1},
+		} {
+			b.Run(fmt.Sprintf("%d/%s", n, mode.name), func(b *testing.B) {
+				Reset()
+				Configure(WithJSONStreamThreshold(mode.threshold))
+				defer Reset()
+
+				w := &discardWriter{header: make(http.Header)}
+				b.ReportAllocs()
+				for range b.N {
+					if err := handleCommonTypes(w, items); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package m
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errBodyReadTimeout = errors.New("request body read timed out")
+
+type bodyWriterKey struct{}
+
+// withBodyWriter records w in the request, so limitBodyTime can set a read
+// deadline on the connection behind it. It is a no-op without a body read timeout
+func withBodyWriter(w http.ResponseWriter, r *http.Request) *http.Request {
+	if global.get().BodyReadTimeout <= 0 || r.Body == nil || r.Body == http.NoBody {
+		return r
+	}
+	if r.Context().Value(bodyWriterKey{}) != nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), bodyWriterKey{}, w))
+}
+
+// limitBodyTime bounds the remaining reads of r.Body by Config.BodyReadTimeout,
+// starting now. It is a no-op when no timeout is configured or the body is
+// already bounded, so every body-reading extractor can call it.
+// The deadline is set on the connection when the server supports it;
+// otherwise, e.g. for a request built in a test, reads are timed by deadlineBody
+func limitBodyTime(r *http.Request) {
+	timeout := global.get().BodyReadTimeout
+	if timeout <= 0 || r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+	switch r.Body.(type) {
+	case *connDeadlineBody, *deadlineBody:
+		return
+	}
+
+	deadline := time.Now().Add(timeout)
+	if w, ok := r.Context().Value(bodyWriterKey{}).(http.ResponseWriter); ok {
+		if http.NewResponseController(w).SetReadDeadline(deadline) == nil {
+			r.Body = &connDeadlineBody{rc: r.Body}
+			return
+		}
+	}
+	r.Body = &deadlineBody{rc: r.Body, deadline: deadline}
+}
+
+// connDeadlineBody reports reads past the connection read deadline as
+// errBodyReadTimeout. The server clears the deadline once the body is read
+// in full, and resets it for the next request on the connection
+type connDeadlineBody struct {
+	rc io.ReadCloser
+}
+
+func (c *connDeadlineBody) Read(p []byte) (int, error) {
+	n, err := c.rc.Read(p)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return n, errBodyReadTimeout
+	}
+	return n, err
+}
+
+func (c *connDeadlineBody) Close() error {
+	return c.rc.Close()
+}
+
+// deadlineBody fails reads that do not complete before its deadline.
+// One goroutine per body does the reads, into a buffer reused between them.
+// Once a read times out the underlying body is closed and every later read fails
+type deadlineBody struct {
+	rc       io.ReadCloser
+	deadline time.Time
+
+	mu       sync.Mutex
+	buf      []byte
+	reqs     chan []byte
+	results  chan readResult
+	timedOut bool
+	closed   bool
+}
+
+type readResult struct {
+	n   int
+	err error
+}
+
+func (d *deadlineBody) Read(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	remaining := time.Until(d.deadline)
+	if d.timedOut || remaining <= 0 {
+		return 0, d.timeout()
+	}
+	if d.closed {
+		return 0, http.ErrBodyReadAfterClose
+	}
+	if d.reqs == nil {
+		d.reqs = make(chan []byte)
+		d.results = make(chan readResult, 1)
+		go d.readLoop()
+	}
+
+	// Read into the private buffer: on timeout the read is abandoned and may
+	// still complete later, after p has been handed back to the caller
+	if cap(d.buf) < len(p) {
+		d.buf = make([]byte, len(p))
+	}
+	d.reqs <- d.buf[:len(p)]
+
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+
+	select {
+	case res := <-d.results:
+		return copy(p, d.buf[:res.n]), res.err
+	case <-timer.C:
+		return 0, d.timeout()
+	}
+}
+
+// readLoop serves the reads of d until it times out or is closed
+func (d *deadlineBody) readLoop() {
+	for buf := range d.reqs {
+		n, err := d.rc.Read(buf)
+		d.results <- readResult{n: n, err: err}
+	}
+}
+
+// timeout closes the body and ends readLoop once its pending read returns.
+// The caller holds d.mu
+func (d *deadlineBody) timeout() error {
+	if !d.timedOut {
+		d.timedOut = true
+		d.stop()
+		d.rc.Close()
+	}
+	return errBodyReadTimeout
+}
+
+// stop ends readLoop. The caller holds d.mu
+func (d *deadlineBody) stop() {
+	if d.reqs != nil && !d.closed {
+		close(d.reqs)
+	}
+	d.closed = true
+}
+
+func (d *deadlineBody) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stop()
+	return d.rc.Close()
+}
+
+// bodyReadError converts an error from reading the request body into an ExtractError
+func bodyReadError(err error) error {
+	if errors.Is(err, errBodyReadTimeout) {
+		return NewBodyReadTimeoutError()
+	}
+	var encErr *encodingError
+	if errors.As(err, &encErr) {
+		return NewContentEncodingError(encErr.err)
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return NewBodyTooLargeError(tooLarge.Limit)
+	}
+	return NewBodyReadError(err)
+}
+
+// decompressBody replaces a gzip or deflate encoded r.Body with one that
+// yields the decoded bytes, and drops the headers describing the encoded form.
+// Other encodings are rejected; "identity" and an absent header are left alone
+func decompressBody(r *http.Request) error {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	if encoding != "gzip" && encoding != "x-gzip" && encoding != "deflate" {
+		return NewUnsupportedEncodingError(encoding)
+	}
+
+	r.Body = &decodingBody{src: &sourceReader{rc: r.Body}, encoding: encoding}
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return nil
+}
+
+// encodingError marks a failure caused by malformed compressed data,
+// as opposed to one reading the underlying body
+type encodingError struct {
+	err error
+}
+
+func (e *encodingError) Error() string { return "malformed request body encoding: " + e.err.Error() }
+func (e *encodingError) Unwrap() error { return e.err }
+
+// sourceReader records the last error returned by the compressed body,
+// so decodingBody can tell transport failures from decoding ones
+type sourceReader struct {
+	rc  io.ReadCloser
+	err error
+}
+
+func (s *sourceReader) Read(p []byte) (int, error) {
+	n, err := s.rc.Read(p)
+	if err != nil && err != io.EOF {
+		s.err = err
+	}
+	return n, err
+}
+
+// decodingBody decompresses src on demand. The decompressor is created on the
+// first read, so reading the gzip header happens inside the extractor and is
+// bounded by Config.BodyReadTimeout like any other body read
+type decodingBody struct {
+	src      *sourceReader
+	encoding string
+	r        io.ReadCloser
+}
+
+func (d *decodingBody) Read(p []byte) (int, error) {
+	if d.r == nil {
+		var err error
+		if d.encoding == "deflate" {
+			d.r, err = zlib.NewReader(d.src)
+		} else {
+			d.r, err = gzip.NewReader(d.src)
+		}
+		if err != nil {
+			return 0, d.wrap(err)
+		}
+	}
+
+	n, err := d.r.Read(p)
+	return n, d.wrap(err)
+}
+
+func (d *decodingBody) wrap(err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	if d.src.err != nil {
+		return d.src.err
+	}
+	return &encodingError{err: err}
+}
+
+func (d *decodingBody) Close() error {
+	if d.r != nil {
+		d.r.Close()
+	}
+	return d.src.rc.Close()
+}
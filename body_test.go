@@ -0,0 +1,346 @@
+package m
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowReader yields its data one byte at a time, pausing before each read
+type slowReader struct {
+	data  string
+	delay time.Duration
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	if s.data == "" {
+		return 0, io.EOF
+	}
+	time.Sleep(s.delay)
+	p[0] = s.data[0]
+	s.data = s.data[1:]
+	return 1, nil
+}
+
+func TestBodyReadTimeout(t *testing.T) {
+	slowRequest := func(body, contentType string, delay time.Duration) *http.Request {
+		req := httptest.NewRequest("POST", "/", io.NopCloser(&slowReader{data: body, delay: delay}))
+		req.Header.Set("Content-Type", contentType)
+		return req
+	}
+
+	t.Run("slow JSON body times out with 408", func(t *testing.T) {
+		Reset()
+		Configure(WithBodyReadTimeout(30 * time.Millisecond))
+		defer Reset()
+
+		handler := H(func(user JSON[User]) User { return user.Value })
+		rec := httptest.NewRecorder()
+		handler(rec, slowRequest(`{"name":"Alice","email":"alice@example.com"}`, "application/json", 5*time.Millisecond))
+
+		if rec.Code != 408 {
+			t.Errorf("expected status 408, got %d", rec.Code)
+		}
+		var httpErr HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+		if httpErr.Err != "request_timeout" {
+			t.Errorf("unexpected error: %+v", httpErr)
+		}
+	})
+
+	t.Run("slow form body times out", func(t *testing.T) {
+		Reset()
+		Configure(WithBodyReadTimeout(30 * time.Millisecond))
+		defer Reset()
+
+		body := url.Values{"username": {strings.Repeat("a", 50)}}.Encode()
+		var f Form[FormData]
+		err := f.Extract(slowRequest(body, "application/x-www-form-urlencoded", 5*time.Millisecond))
+
+		var extractErr *ExtractError
+		if !errors.As(err, &extractErr) || extractErr.Type != ErrTypeBodyTimeout {
+			t.Errorf("expected body timeout error, got %v", err)
+		}
+	})
+
+	t.Run("body within timeout", func(t *testing.T) {
+		Reset()
+		Configure(WithBodyReadTimeout(time.Second))
+		defer Reset()
+
+		var j JSON[User]
+		if err := j.Extract(slowRequest(`{"name":"Bob"}`, "application/json", time.Millisecond)); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		if j.Value.Name != "Bob" {
+			t.Errorf("expected Name=Bob, got %s", j.Value.Name)
+		}
+	})
+
+	t.Run("deadline set on the connection", func(t *testing.T) {
+		Reset()
+		Configure(WithBodyReadTimeout(50 * time.Millisecond))
+		defer Reset()
+
+		onConn := make(chan bool, 1)
+		ts := httptest.NewServer(H(func(r *http.Request) error {
+			limitBodyTime(r)
+			_, ok := r.Body.(*connDeadlineBody)
+			onConn <- ok
+			_, err := io.ReadAll(r.Body)
+			return bodyReadError(err)
+		}))
+		defer ts.Close()
+
+		conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "POST / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 100\r\n\r\n{\"name\":")
+
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if !<-onConn {
+			t.Error("expected the deadline to be set on the connection")
+		}
+		if resp.StatusCode != 408 {
+			t.Errorf("expected status 408, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("request context outlives the deadline", func(t *testing.T) {
+		Reset()
+		Configure(WithBodyReadTimeout(20 * time.Millisecond))
+		defer Reset()
+
+		ts := httptest.NewServer(H(func(r *http.Request, user JSON[User]) string {
+			time.Sleep(60 * time.Millisecond)
+			if err := r.Context().Err(); err != nil {
+				return err.Error()
+			}
+			return user.Value.Name
+		}))
+		defer ts.Close()
+
+		resp, err := ts.Client().Post(ts.URL, "application/json", strings.NewReader(`{"name":"Dan"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != 200 || string(body) != "Dan" {
+			t.Errorf("unexpected response: %d %q", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("no timeout by default", func(t *testing.T) {
+		Reset()
+		req := slowRequest(`{"name":"Carol"}`, "application/json", time.Millisecond)
+		var j JSON[User]
+		if err := j.Extract(req); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		if _, ok := req.Body.(*deadlineBody); ok {
+			t.Error("expected body to be left unwrapped")
+		}
+	})
+}
+
+func TestRequestDecompression(t *testing.T) {
+	const payload = `{"name":"Alice","email":"alice@example.com","age":30}`
+
+	compress := func(t *testing.T, encoding, data string) []byte {
+		var buf bytes.Buffer
+		var w io.WriteCloser = gzip.NewWriter(&buf)
+		if encoding == "deflate" {
+			w = zlib.NewWriter(&buf)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+		return buf.Bytes()
+	}
+
+	post := func(handler http.HandlerFunc, encoding string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", encoding)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	echo := H(func(user JSON[User]) User { return user.Value })
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		t.Run(encoding+" JSON body", func(t *testing.T) {
+			Reset()
+			Configure(WithRequestDecompression(true))
+			defer Reset()
+
+			rec := post(echo, encoding, compress(t, encoding, payload))
+			if rec.Code != 200 {
+				t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			var user User
+			parseJSONResponse(t, rec.Body.Bytes(), &user)
+			if user.Name != "Alice" || user.Age != 30 {
+				t.Errorf("unexpected user: %+v", user)
+			}
+		})
+	}
+
+	t.Run("buffered body is decompressed once", func(t *testing.T) {
+		Reset()
+		Configure(WithRequestDecompression(true), WithBodyBuffering(true))
+		defer Reset()
+
+		handler := H(func(user JSON[User], raw BodyReader) string {
+			data, _ := io.ReadAll(raw.Value)
+			return user.Value.Name + " " + string(data)
+		})
+		rec := post(handler, "gzip", compress(t, "gzip", payload))
+		if rec.Body.String() != "Alice "+payload {
+			t.Errorf("unexpected body: %q", rec.Body.String())
+		}
+	})
+
+	t.Run("malformed data is a 400", func(t *testing.T) {
+		Reset()
+		Configure(WithRequestDecompression(true))
+		defer Reset()
+
+		truncated := compress(t, "gzip", payload)
+		truncated = truncated[:len(truncated)-10]
+
+		for name, body := range map[string][]byte{
+			"not gzip":  []byte(payload),
+			"truncated": truncated,
+		} {
+			rec := post(echo, "gzip", body)
+			if rec.Code != 400 {
+				t.Errorf("%s: expected status 400, got %d", name, rec.Code)
+			}
+			var httpErr HTTPError
+			parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+			if httpErr.Err != "invalid_encoding" {
+				t.Errorf("%s: unexpected error: %+v", name, httpErr)
+			}
+		}
+	})
+
+	t.Run("unsupported encoding is a 415", func(t *testing.T) {
+		Reset()
+		Configure(WithRequestDecompression(true))
+		defer Reset()
+
+		rec := post(echo, "br", []byte(payload))
+		if rec.Code != 415 {
+			t.Errorf("expected status 415, got %d", rec.Code)
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		Reset()
+		rec := post(echo, "gzip", compress(t, "gzip", payload))
+		if rec.Code != 400 {
+			t.Errorf("expected status 400, got %d", rec.Code)
+		}
+	})
+}
+
+func TestDeclaredBodyTooLarge(t *testing.T) {
+	upload := func(r JSON[User]) string { return r.Value.Name }
+
+	t.Run("rejected before reading", func(t *testing.T) {
+		Reset()
+		Configure(WithMaxBodySize(16))
+		defer Reset()
+
+		body := &countingReader{r: strings.NewReader(`{"name": "a very long name indeed"}`)}
+		req := httptest.NewRequest("POST", "/", body)
+		req.Header.Set("Content-Type", "application/json")
+		req.ContentLength = 36
+
+		rec := httptest.NewRecorder()
+		H(upload)(rec, req)
+		if rec.Code != 413 {
+			t.Errorf("expected status 413, got %d", rec.Code)
+		}
+		if body.n != 0 {
+			t.Errorf("expected the body to be left unread, read %d bytes", body.n)
+		}
+	})
+
+	t.Run("no 100 Continue for an oversized upload", func(t *testing.T) {
+		Reset()
+		Configure(WithMaxBodySize(1024))
+		defer Reset()
+
+		srv := httptest.NewServer(H(upload))
+		defer srv.Close()
+
+		conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: test\r\nContent-Type: application/json\r\n"+
+			"Content-Length: %d\r\nExpect: 100-continue\r\n\r\n", 1<<20)
+
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 413 {
+			t.Errorf("expected an immediate 413 instead of 100 Continue, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("unknown length is still limited while reading", func(t *testing.T) {
+		Reset()
+		Configure(WithMaxBodySize(16))
+		defer Reset()
+
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"name": "a very long name indeed"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.ContentLength = -1
+
+		rec := httptest.NewRecorder()
+		H(upload)(rec, req)
+		if rec.Code != 413 {
+			t.Errorf("expected status 413, got %d", rec.Code)
+		}
+	})
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
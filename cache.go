@@ -0,0 +1,226 @@
+package m
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedResponse is a buffered response stored by the cache middleware
+type CachedResponse struct {
+	Status    int
+	Header    http.Header
+	Body      []byte
+	ExpiresAt time.Time
+}
+
+// CacheStore is the storage backend used by WithCache
+type CacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse)
+	Delete(key string)
+}
+
+// MemoryCache is an in-memory LRU CacheStore, safe for concurrent use
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type memoryCacheEntry struct {
+	key  string
+	resp *CachedResponse
+}
+
+// NewMemoryCache creates an LRU cache holding at most capacity responses
+func NewMemoryCache(capacity int) *MemoryCache {
+	if capacity <= 0 {
+		capacity = 1024
+	}
+	return &MemoryCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*memoryCacheEntry).resp, true
+}
+
+func (c *MemoryCache) Set(key string, resp *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*memoryCacheEntry).resp = resp
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&memoryCacheEntry{key: key, resp: resp})
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*memoryCacheEntry).key)
+	}
+}
+
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.ll.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+// WithCache returns a middleware caching successful GET responses for ttl.
+// Responses are keyed on method, path and query, and on the Accept and
+// Accept-Encoding headers, so negotiated and compressed responses are only replayed
+// to clients asking for the same. Only 200 responses are stored; those setting
+// cookies, varying on other request headers, sent with "Cache-Control: no-store",
+// flushed while streaming, or never written at all are not.
+// The request ID of the original response is never replayed.
+// A nil store falls back to a MemoryCache with default capacity
+func WithCache(store CacheStore, ttl time.Duration) func(http.Handler) http.Handler {
+	if store == nil {
+		store = NewMemoryCache(0)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := cacheKey(r)
+
+			if cached, ok := store.Get(key); ok {
+				if time.Now().Before(cached.ExpiresAt) {
+					WriteHeaders(w, cached.Header)
+					w.WriteHeader(cached.Status)
+					w.Write(cached.Body)
+					return
+				}
+				store.Delete(key)
+			}
+
+			cw := &cacheWriter{ResponseWriter: w}
+			next.ServeHTTP(cw, r)
+
+			if cw.header == nil || cw.code != http.StatusOK || cw.flushed {
+				return
+			}
+			if !cacheable(cw.header) {
+				return
+			}
+
+			header := cw.header.Clone()
+			header.Del(RequestIDHeader)
+			store.Set(key, &CachedResponse{
+				Status:    http.StatusOK,
+				Header:    header,
+				Body:      cw.body.Bytes(),
+				ExpiresAt: time.Now().Add(ttl),
+			})
+		})
+	}
+}
+
+// cacheVaryHeaders are the request headers responses are keyed on. They cover
+// content negotiation and response compression
+var cacheVaryHeaders = []string{"Accept", "Accept-Encoding"}
+
+// cacheKey identifies the response to r by method, path, query and cacheVaryHeaders
+func cacheKey(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery)
+	for _, name := range cacheVaryHeaders {
+		b.WriteString("\n" + strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// cacheable reports whether a response with the given header may be stored:
+// it must not opt out with no-store, set cookies for one client, or vary on
+// request headers other than cacheVaryHeaders
+func cacheable(header http.Header) bool {
+	if strings.Contains(strings.ToLower(header.Get("Cache-Control")), "no-store") {
+		return false
+	}
+	if len(header.Values("Set-Cookie")) > 0 {
+		return false
+	}
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" && !slices.ContainsFunc(cacheVaryHeaders, func(h string) bool { return strings.EqualFold(h, name) }) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// cacheWriter passes the response through while keeping a copy of it.
+// A flushed response is streamed, so its copy is dropped and not kept up
+type cacheWriter struct {
+	http.ResponseWriter
+	code    int
+	header  http.Header
+	body    bytes.Buffer
+	flushed bool
+}
+
+func (cw *cacheWriter) WriteHeader(code int) {
+	if cw.header == nil {
+		cw.code = code
+		cw.header = cw.ResponseWriter.Header().Clone()
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *cacheWriter) Write(b []byte) (int, error) {
+	if cw.header == nil {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.flushed {
+		cw.body.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, passing the flush on and giving up on caching
+func (cw *cacheWriter) Flush() {
+	if cw.header == nil {
+		cw.WriteHeader(http.StatusOK)
+	}
+	cw.flushed = true
+	cw.body = bytes.Buffer{}
+	if err := http.NewResponseController(cw.ResponseWriter).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logger().Printf("failed to flush response: %v", err)
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (cw *cacheWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,256 @@
+package m
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithCache(t *testing.T) {
+	newCounter := func() (http.Handler, *int) {
+		calls := 0
+		handler := H(func(q Query[QueryParams]) Result[string] {
+			calls++
+			return OK(fmt.Sprintf("page %d, call %d", q.Value.Page, calls)).
+				WithHeader("X-Call", fmt.Sprint(calls))
+		})
+		return handler, &calls
+	}
+
+	t.Run("cache hit and miss", func(t *testing.T) {
+		Reset()
+		handler, calls := newCounter()
+		cached := WithCache(NewMemoryCache(10), time.Minute)(handler)
+
+		for i := 0; i < 2; i++ {
+			rec := httptest.NewRecorder()
+			cached.ServeHTTP(rec, httptest.NewRequest("GET", "/items?page=1", nil))
+			if rec.Body.String() != "page 1, call 1" {
+				t.Errorf("unexpected body: %s", rec.Body.String())
+			}
+			if rec.Header().Get("X-Call") != "1" {
+				t.Errorf("expected cached header, got %q", rec.Header().Get("X-Call"))
+			}
+		}
+		if *calls != 1 {
+			t.Errorf("expected 1 handler call, got %d", *calls)
+		}
+
+		rec := httptest.NewRecorder()
+		cached.ServeHTTP(rec, httptest.NewRequest("GET", "/items?page=2", nil))
+		if rec.Body.String() != "page 2, call 2" {
+			t.Errorf("different query should miss, got %s", rec.Body.String())
+		}
+	})
+
+	t.Run("ttl expiry", func(t *testing.T) {
+		handler, calls := newCounter()
+		cached := WithCache(nil, 20*time.Millisecond)(handler)
+
+		cached.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		cached.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if *calls != 1 {
+			t.Fatalf("expected 1 handler call before expiry, got %d", *calls)
+		}
+
+		time.Sleep(30 * time.Millisecond)
+		cached.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if *calls != 2 {
+			t.Errorf("expected 2 handler calls after expiry, got %d", *calls)
+		}
+	})
+
+	t.Run("only caches 200 GET responses", func(t *testing.T) {
+		calls := 0
+		handler := H(func(r *http.Request) Result[string] {
+			calls++
+			if r.URL.Path == "/missing" {
+				return Err[string](404, &HTTPError{Code: 404, Err: "not_found"})
+			}
+			return OK("created").WithStatus(201)
+		})
+		cached := WithCache(nil, time.Minute)(handler)
+
+		for _, target := range []string{"/missing", "/missing", "/created", "/created"} {
+			cached.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+		}
+		cached.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/created", nil))
+		cached.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/created", nil))
+		if calls != 6 {
+			t.Errorf("expected 6 handler calls, got %d", calls)
+		}
+	})
+
+	t.Run("skips responses never written", func(t *testing.T) {
+		calls := 0
+		cached := WithCache(nil, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls > 1 {
+				w.Write([]byte("hello"))
+			}
+		}))
+
+		cached.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		rec := httptest.NewRecorder()
+		cached.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if calls != 2 || rec.Body.String() != "hello" {
+			t.Errorf("expected the empty response not to be cached, got %d calls and %q", calls, rec.Body.String())
+		}
+	})
+
+	t.Run("passes flushes on and skips flushed responses", func(t *testing.T) {
+		calls := 0
+		var unwrapped http.ResponseWriter
+		cached := WithCache(nil, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			unwrapped = w.(interface{ Unwrap() http.ResponseWriter }).Unwrap()
+			w.Write([]byte("data: 1\n\n"))
+			http.NewResponseController(w).Flush()
+			w.Write([]byte("data: 2\n\n"))
+		}))
+
+		for range 2 {
+			rec := newFlushRecorder()
+			cached.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+			if len(rec.flushed) != 1 || rec.flushed[0] != "data: 1\n\n" {
+				t.Errorf("expected the first event to be flushed, got %q", rec.flushed)
+			}
+			if unwrapped != http.ResponseWriter(rec) {
+				t.Error("expected Unwrap to return the underlying writer")
+			}
+		}
+		if calls != 2 {
+			t.Errorf("expected 2 handler calls, got %d", calls)
+		}
+	})
+
+	t.Run("respects no-store", func(t *testing.T) {
+		calls := 0
+		handler := H(func() Result[string] {
+			calls++
+			return OK("private").WithHeader("Cache-Control", "no-store")
+		})
+		cached := WithCache(nil, time.Minute)(handler)
+
+		cached.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		cached.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if calls != 2 {
+			t.Errorf("expected 2 handler calls, got %d", calls)
+		}
+	})
+
+	t.Run("keyed on Accept-Encoding", func(t *testing.T) {
+		Reset()
+		Configure(WithCompression(true), WithCompressionMinSize(0))
+		defer Reset()
+
+		cached := WithCache(nil, time.Minute)(H(func() string { return "hello" }))
+		get := func(acceptEncoding string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "/", nil)
+			if acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			cached.ServeHTTP(rec, req)
+			return rec
+		}
+
+		get("gzip")
+		rec := get("")
+		if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "hello" {
+			t.Errorf("expected a plain response, got %q %q", rec.Header().Get("Content-Encoding"), rec.Body.String())
+		}
+		if rec = get("gzip"); rec.Header().Get("Content-Encoding") != "gzip" {
+			t.Errorf("expected the cached gzip response, got headers %v", rec.Header())
+		}
+	})
+
+	t.Run("keyed on Accept", func(t *testing.T) {
+		calls := 0
+		cached := WithCache(nil, time.Minute)(H(func(r *http.Request) string {
+			calls++
+			return r.Header.Get("Accept")
+		}))
+
+		for _, accept := range []string{"application/json", "application/xml", "application/json"} {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept", accept)
+			rec := httptest.NewRecorder()
+			cached.ServeHTTP(rec, req)
+			if rec.Body.String() != accept {
+				t.Errorf("expected %q, got %q", accept, rec.Body.String())
+			}
+		}
+		if calls != 2 {
+			t.Errorf("expected 2 handler calls, got %d", calls)
+		}
+	})
+
+	t.Run("skips responses varying on other headers", func(t *testing.T) {
+		calls := 0
+		cached := WithCache(nil, time.Minute)(H(func() Result[string] {
+			calls++
+			return OK("mine").WithHeader("Vary", "Accept-Encoding, Authorization")
+		}))
+
+		cached.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		cached.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if calls != 2 {
+			t.Errorf("expected 2 handler calls, got %d", calls)
+		}
+	})
+
+	t.Run("skips responses setting cookies", func(t *testing.T) {
+		calls := 0
+		cached := WithCache(nil, time.Minute)(H(func(w http.ResponseWriter) {
+			calls++
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: fmt.Sprint(calls)})
+			w.Write([]byte("welcome"))
+		}))
+
+		for i := 1; i <= 2; i++ {
+			rec := httptest.NewRecorder()
+			cached.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+			if cookie := rec.Header().Get("Set-Cookie"); cookie != fmt.Sprintf("session=%d", i) {
+				t.Errorf("expected a fresh cookie, got %q", cookie)
+			}
+		}
+	})
+
+	t.Run("does not replay the request ID", func(t *testing.T) {
+		handler, _ := newCounter()
+		cached := WithCache(nil, time.Minute)(handler)
+
+		first := httptest.NewRecorder()
+		cached.ServeHTTP(first, httptest.NewRequest("GET", "/", nil))
+		if first.Header().Get(RequestIDHeader) == "" {
+			t.Fatal("expected the handler to set a request ID")
+		}
+
+		rec := httptest.NewRecorder()
+		cached.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Header().Get("X-Call") != "1" {
+			t.Fatalf("expected a cached response, got call %q", rec.Header().Get("X-Call"))
+		}
+		if id := rec.Header().Get(RequestIDHeader); id != "" {
+			t.Errorf("expected no replayed request ID, got %q", id)
+		}
+	})
+
+	t.Run("lru eviction", func(t *testing.T) {
+		c := NewMemoryCache(2)
+		c.Set("a", &CachedResponse{})
+		c.Set("b", &CachedResponse{})
+		c.Get("a")
+		c.Set("c", &CachedResponse{})
+
+		if _, ok := c.Get("b"); ok {
+			t.Error("expected least recently used entry to be evicted")
+		}
+		if _, ok := c.Get("a"); !ok {
+			t.Error("expected recently used entry to be kept")
+		}
+	})
+}
@@ -0,0 +1,203 @@
+package m
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultCompressionMinSize is the smallest response body compressed by default;
+// below it the gzip framing costs about as much as it saves
+const defaultCompressionMinSize = 1024
+
+var gzipWriterPool = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows a gzip response,
+// either by naming gzip (or x-gzip) or through "*", with a non-zero q value
+func acceptsGzip(header string) bool {
+	gzipQ, wildcardQ := -1.0, -1.0
+	for _, entry := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(entry, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		switch coding {
+		case "gzip", "x-gzip":
+			gzipQ = q
+		case "*":
+			wildcardQ = q
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return wildcardQ > 0
+}
+
+// isCompressedType reports whether a media type is already compressed,
+// so gzipping it again would only cost CPU
+func isCompressedType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "image/svg+xml":
+		return false
+	case strings.HasPrefix(mediaType, "image/"),
+		strings.HasPrefix(mediaType, "video/"),
+		strings.HasPrefix(mediaType, "audio/"),
+		strings.HasPrefix(mediaType, "font/woff"):
+		return true
+	}
+	switch mediaType {
+	case "application/gzip", "application/x-gzip", "application/zip", "application/zstd",
+		"application/x-bzip2", "application/x-xz", "application/x-7z-compressed",
+		"application/x-rar-compressed", "application/pdf", "application/octet-stream":
+		return true
+	}
+	return false
+}
+
+// gzipWriter compresses the response it passes to the underlying writer.
+// It holds the status and the start of the body back until minSize bytes
+// have been written, then decides: responses that are small, already encoded,
+// partial, or of an already compressed type are sent as they are.
+// It sits beneath the mint ResponseWriter, which keeps tracking what the handler wrote
+type gzipWriter struct {
+	http.ResponseWriter
+	minSize int
+
+	code    int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (gw *gzipWriter) WriteHeader(code int) {
+	if gw.code != 0 || gw.decided {
+		return
+	}
+	gw.code = code
+	// Informational and bodiless responses have nothing to compress
+	if code < 200 || code == http.StatusNoContent || code == http.StatusNotModified {
+		gw.start(false)
+	}
+}
+
+func (gw *gzipWriter) Write(b []byte) (int, error) {
+	if !gw.decided {
+		gw.buf = append(gw.buf, b...)
+		if len(gw.buf) < gw.minSize {
+			return len(b), nil
+		}
+		if err := gw.start(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if gw.gz != nil {
+		return gw.gz.Write(b)
+	}
+	return gw.ResponseWriter.Write(b)
+}
+
+// Flush sends what has been written so far. Before minSize is reached the
+// final size is unknown, so the response goes out uncompressed from then on
+func (gw *gzipWriter) Flush() {
+	if !gw.decided {
+		gw.start(false)
+	}
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	http.NewResponseController(gw.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (gw *gzipWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
+
+// Close sends a response still held back uncompressed, as it stayed below
+// minSize, or finishes the gzip stream. It must be called once the handler is done
+func (gw *gzipWriter) Close() error {
+	if !gw.decided {
+		if gw.code == 0 && len(gw.buf) == 0 {
+			// Nothing was written; leave the status to the server
+			gw.decided = true
+			return nil
+		}
+		return gw.start(false)
+	}
+	if gw.gz == nil {
+		return nil
+	}
+	err := gw.gz.Close()
+	gw.gz.Reset(nil)
+	gzipWriterPool.Put(gw.gz)
+	gw.gz = nil
+	return err
+}
+
+// start writes the header, compressed if want is set and the response qualifies,
+// followed by any buffered body
+func (gw *gzipWriter) start(want bool) error {
+	gw.decided = true
+	if gw.code == 0 {
+		gw.code = http.StatusOK
+	}
+
+	h := gw.Header()
+	// Sniff now, as the server would otherwise sniff the compressed bytes
+	if h.Get("Content-Type") == "" && len(gw.buf) > 0 && !gw.sniffingDisabled() {
+		h.Set("Content-Type", http.DetectContentType(gw.buf))
+	}
+
+	if want && gw.code != http.StatusPartialContent &&
+		h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" &&
+		!isCompressedType(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		gw.gz = gzipWriterPool.Get().(*gzip.Writer)
+		gw.gz.Reset(gw.ResponseWriter)
+	}
+
+	gw.ResponseWriter.WriteHeader(gw.code)
+	buf := gw.buf
+	gw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if gw.gz != nil {
+		_, err = gw.gz.Write(buf)
+	} else {
+		_, err = gw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// sniffingDisabled reports whether the handler opted out of content sniffing
+// by setting an empty Content-Type, which net/http honors
+func (gw *gzipWriter) sniffingDisabled() bool {
+	v, ok := gw.Header()["Content-Type"]
+	return ok && len(v) == 0
+}
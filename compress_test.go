@@ -0,0 +1,231 @@
+package m
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.5", true},
+		{"GZIP", true},
+		{"x-gzip", true},
+		{"br", false},
+		{"gzip;q=0", false},
+		{"*", true},
+		{"*;q=0", false},
+		{"gzip;q=0, *", false},
+		{"br, *;q=0.1", true},
+	}
+
+	for _, tt := range tests {
+		if got := acceptsGzip(tt.header); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v, expected %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	large := strings.Repeat("mint ", 400)
+
+	serve := func(handler http.HandlerFunc, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	gunzip := func(t *testing.T, rec *httptest.ResponseRecorder) string {
+		t.Helper()
+		zr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("response is not gzipped: %v", err)
+		}
+		body, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("failed to decompress response: %v", err)
+		}
+		return string(body)
+	}
+
+	t.Run("compresses large responses", func(t *testing.T) {
+		Reset()
+		Configure(WithCompression(true))
+		defer Reset()
+
+		rec := serve(H(func() []string { return []string{large} }), "gzip, br")
+		if rec.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("expected a gzipped response, got headers %v", rec.Header())
+		}
+		if rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("expected Vary: Accept-Encoding, got %q", rec.Header().Get("Vary"))
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		if body := gunzip(t, rec); body != `["`+large+`"]` {
+			t.Errorf("unexpected body %q", body)
+		}
+	})
+
+	t.Run("keeps the status code", func(t *testing.T) {
+		Reset()
+		Configure(WithCompression(true))
+		defer Reset()
+
+		var status int
+		handler := HWith(func() Status[string] { return WithStatus(201, large) }, func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r)
+				status = w.(*ResponseWriter).Status()
+			})
+		})
+
+		rec := serve(handler, "gzip")
+		if rec.Code != 201 || status != 201 {
+			t.Errorf("expected status 201, got %d (middleware saw %d)", rec.Code, status)
+		}
+		if body := gunzip(t, rec); body != large {
+			t.Errorf("unexpected body %q", body)
+		}
+	})
+
+	t.Run("skips small responses", func(t *testing.T) {
+		Reset()
+		Configure(WithCompression(true))
+		defer Reset()
+
+		rec := serve(H(func() string { return "ok" }), "gzip")
+		if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "ok" {
+			t.Errorf("expected a plain response, got %q %q", rec.Header().Get("Content-Encoding"), rec.Body.String())
+		}
+	})
+
+	t.Run("minimum size", func(t *testing.T) {
+		Reset()
+		Configure(WithCompression(true), WithCompressionMinSize(2))
+		defer Reset()
+
+		rec := serve(H(func() string { return "ok" }), "gzip")
+		if body := gunzip(t, rec); body != "ok" {
+			t.Errorf("unexpected body %q", body)
+		}
+	})
+
+	t.Run("clients not accepting gzip", func(t *testing.T) {
+		Reset()
+		Configure(WithCompression(true))
+		defer Reset()
+
+		handler := H(func() string { return large })
+		for _, accept := range []string{"", "br", "gzip;q=0"} {
+			rec := serve(handler, accept)
+			if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != large {
+				t.Errorf("%q: expected a plain response", accept)
+			}
+			if rec.Header().Get("Vary") != "Accept-Encoding" {
+				t.Errorf("%q: expected Vary: Accept-Encoding", accept)
+			}
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		Reset()
+		rec := serve(H(func() string { return large }), "gzip")
+		if rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("Vary") != "" {
+			t.Errorf("expected no compression, got headers %v", rec.Header())
+		}
+	})
+
+	t.Run("skips compressed content types", func(t *testing.T) {
+		Reset()
+		Configure(WithCompression(true))
+		defer Reset()
+
+		png := "\x89PNG\r\n\x1a\n" + large
+		rec := serve(H(func(w http.ResponseWriter) {
+			w.Write([]byte(png))
+		}), "gzip")
+		if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != png {
+			t.Errorf("expected a plain response, got headers %v", rec.Header())
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+			t.Errorf("expected a sniffed content type, got %q", ct)
+		}
+	})
+
+	t.Run("skips encoded responses", func(t *testing.T) {
+		Reset()
+		Configure(WithCompression(true))
+		defer Reset()
+
+		rec := serve(H(func(w http.ResponseWriter) {
+			w.Header().Set("Content-Encoding", "br")
+			w.Write([]byte(large))
+		}), "gzip, br")
+		if rec.Header().Get("Content-Encoding") != "br" || rec.Body.String() != large {
+			t.Errorf("expected the response untouched, got headers %v", rec.Header())
+		}
+	})
+
+	t.Run("flush before the threshold sends plain", func(t *testing.T) {
+		Reset()
+		Configure(WithCompression(true))
+		defer Reset()
+
+		rec := newFlushRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		H(func(w http.ResponseWriter) {
+			w.Write([]byte("data: 1\n\n"))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(large))
+		})(rec, req)
+
+		if len(rec.flushed) != 1 || rec.flushed[0] != "data: 1\n\n" {
+			t.Errorf("expected the first event to be flushed, got %q", rec.flushed)
+		}
+		if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "data: 1\n\n"+large {
+			t.Errorf("expected a plain response, got headers %v", rec.Header())
+		}
+	})
+
+	t.Run("errors are compressed too", func(t *testing.T) {
+		Reset()
+		Configure(WithCompression(true), WithCompressionMinSize(0))
+		defer Reset()
+
+		rec := serve(H(func() (string, error) { return "", NotFound("no such user") }), "gzip")
+		if rec.Code != 404 {
+			t.Fatalf("expected status 404, got %d", rec.Code)
+		}
+		var body HTTPError
+		parseJSONResponse(t, []byte(gunzip(t, rec)), &body)
+		if body.Message != "no such user" {
+			t.Errorf("unexpected error body %+v", body)
+		}
+	})
+
+	t.Run("empty responses", func(t *testing.T) {
+		Reset()
+		Configure(WithCompression(true), WithCompressionMinSize(0))
+		defer Reset()
+
+		rec := serve(H(func() StatusCode { return 204 }), "gzip")
+		if rec.Code != 204 || rec.Header().Get("Content-Encoding") != "" || rec.Body.Len() != 0 {
+			t.Errorf("expected a bare 204, got %d %v", rec.Code, rec.Header())
+		}
+	})
+}
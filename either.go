@@ -0,0 +1,45 @@
+package m
+
+// Either is a response holding one of two outcomes, such as a domain-level
+// report (Left) or a success value (Right). Only the side that is set is encoded,
+// as plain JSON with no wrapper, and it is sent with that side's status (200 if zero).
+// Right wins if both are set; a zero Either writes an empty response
+type Either[L, R any] struct {
+	Left  *L
+	Right *R
+
+	LeftStatus  int
+	RightStatus int
+}
+
+// LeftOf returns an Either holding the left value v
+func LeftOf[L, R any](v L) Either[L, R] {
+	return Either[L, R]{Left: &v}
+}
+
+// RightOf returns an Either holding the right value v
+func RightOf[L, R any](v R) Either[L, R] {
+	return Either[L, R]{Right: &v}
+}
+
+// WithStatuses returns a copy of e that responds with left or right
+// as the status code, depending on which side is set
+func (e Either[L, R]) WithStatuses(left, right int) Either[L, R] {
+	e.LeftStatus, e.RightStatus = left, right
+	return e
+}
+
+func (e Either[L, R]) isResultType() bool {
+	return true
+}
+
+func (e Either[L, R]) toResult() Result[any] {
+	switch {
+	case e.Right != nil:
+		return Result[any]{Code: e.RightStatus, Data: *e.Right}
+	case e.Left != nil:
+		return Result[any]{Code: e.LeftStatus, Data: *e.Left}
+	default:
+		return Result[any]{}
+	}
+}
@@ -0,0 +1,75 @@
+package m
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEither(t *testing.T) {
+	type ValidationReport struct {
+		Valid    bool     `json:"valid"`
+		Problems []string `json:"problems"`
+	}
+
+	handler := H(func(r *http.Request) Either[ValidationReport, User] {
+		if r.URL.Query().Get("name") == "" {
+			report := ValidationReport{Problems: []string{"name is missing"}}
+			return LeftOf[ValidationReport, User](report).WithStatuses(422, 201)
+		}
+		return RightOf[ValidationReport](User{Name: r.URL.Query().Get("name")}).WithStatuses(422, 201)
+	})
+
+	t.Run("right side", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("POST", "/?name=Alice", nil))
+
+		if rec.Code != 201 {
+			t.Errorf("expected status 201, got %d", rec.Code)
+		}
+		var user User
+		parseJSONResponse(t, rec.Body.Bytes(), &user)
+		if user.Name != "Alice" {
+			t.Errorf("expected Name=Alice, got %s", user.Name)
+		}
+	})
+
+	t.Run("left side", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("POST", "/", nil))
+
+		if rec.Code != 422 {
+			t.Errorf("expected status 422, got %d", rec.Code)
+		}
+		if rec.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+			t.Errorf("unexpected Content-Type: %s", rec.Header().Get("Content-Type"))
+		}
+		if body := rec.Body.String(); body != `{"valid":false,"problems":["name is missing"]}` {
+			t.Errorf("unexpected body: %s", body)
+		}
+	})
+
+	t.Run("default status", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		H(func() Either[ValidationReport, User] {
+			return LeftOf[ValidationReport, User](ValidationReport{Valid: true})
+		})(rec, httptest.NewRequest("POST", "/", nil))
+
+		if rec.Code != 200 {
+			t.Errorf("expected status 200, got %d", rec.Code)
+		}
+		if body := rec.Body.String(); body != `{"valid":true,"problems":null}` {
+			t.Errorf("unexpected body: %s", body)
+		}
+	})
+
+	t.Run("right wins over left", func(t *testing.T) {
+		e := Either[string, int]{Left: new(string), Right: new(int)}
+		if data := e.toResult().Data; data != 0 {
+			t.Errorf("expected right value, got %v", data)
+		}
+	})
+}
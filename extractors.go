@@ -0,0 +1,614 @@
+package m
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/schema"
+)
+
+// Version extracts the API version from a vendor media type in the Accept header,
+// such as "application/vnd.api.v2+json", preferring the one with the highest q value.
+// Value holds the version ("v2") and Number its numeric part (2). Both are zero
+// when the client asks for no version.
+// If Config.APIVersions is set, other versions are rejected with 406
+type Version struct {
+	Value  string
+	Number int
+}
+
+func (v *Version) Extract(r *http.Request) error {
+	for _, mr := range ParseAccept(r.Header.Get("Accept")) {
+		if mr.Quality == 0 {
+			continue
+		}
+		if version, number, ok := parseVendorVersion(mr.Type); ok {
+			v.Value, v.Number = version, number
+			break
+		}
+	}
+
+	if supported := apiVersions(); v.Value != "" && len(supported) > 0 && !slices.Contains(supported, v.Value) {
+		return NewUnsupportedVersionError(v.Value)
+	}
+
+	return nil
+}
+
+// parseVendorVersion finds the "vN" segment of a vendor media type,
+// e.g. "v2" in "application/vnd.api.v2+json"
+func parseVendorVersion(mediaType string) (string, int, bool) {
+	_, subtype, ok := strings.Cut(mediaType, "/")
+	if !ok || !strings.HasPrefix(subtype, "vnd.") {
+		return "", 0, false
+	}
+	subtype, _, _ = strings.Cut(subtype, "+")
+
+	for _, segment := range strings.Split(subtype, ".")[1:] {
+		digits, ok := strings.CutPrefix(segment, "v")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(digits); err == nil && n >= 0 {
+			return segment, n, true
+		}
+	}
+	return "", 0, false
+}
+
+// AuthHeader extracts the Authorization header, split into its scheme (e.g. "Bearer")
+// and the credentials that follow it, for auth schemes mint does not parse itself.
+// A missing header is rejected with 401, as is a scheme outside Config.AuthSchemes
+// when it is set. Schemes match case-insensitively and Scheme is reported as
+// spelled in Config.AuthSchemes
+type AuthHeader struct {
+	Scheme string
+	Params string
+}
+
+func (a *AuthHeader) Extract(r *http.Request) error {
+	scheme, params, _ := strings.Cut(strings.TrimSpace(r.Header.Get("Authorization")), " ")
+	if scheme == "" {
+		return NewAuthSchemeError("")
+	}
+	a.Scheme, a.Params = scheme, strings.TrimSpace(params)
+
+	if allowed := authSchemes(); len(allowed) > 0 {
+		i := slices.IndexFunc(allowed, func(s string) bool { return strings.EqualFold(s, scheme) })
+		if i < 0 {
+			return NewAuthSchemeError(scheme)
+		}
+		a.Scheme = allowed[i]
+	}
+
+	return nil
+}
+
+// Header decodes request headers into a struct using `header` struct tags, the way
+// Query does for query parameters, e.g. `header:"X-Tenant-ID"`. Names match
+// case-insensitively and fields without a tag use the field name. Slice fields take
+// every value of a header, splitting comma-separated lists. Missing headers leave
+// fields zero unless tagged required: `header:"X-Tenant-ID,required"`.
+// Conversion failures and missing required headers are rejected with 400
+type Header[T any] struct {
+	Value T
+}
+
+func (h *Header[T]) Extract(r *http.Request) error {
+	val := reflect.ValueOf(&h.Value).Elem()
+	target := getPointer(val)
+	if err := decodeTagged(headerDecoder(), "header", target, headerValues(r.Header, val.Type())); err != nil {
+		return NewHeaderError(err)
+	}
+
+	if err := validate(r.Context(), target); err != nil {
+		return NewValidationError(err)
+	}
+
+	return nil
+}
+
+// headerDecoder decodes Header values, reading `header` tags
+var headerDecoder = sync.OnceValue(func() *schema.Decoder {
+	decoder := newDefaultSchemaDecoder()
+	decoder.SetAliasTag("header")
+	return decoder
+})
+
+// headerValues collects the headers the fields of t ask for, keyed by their tag
+// names, so the lookup is case-insensitive regardless of how the tag is spelled
+func headerValues(header http.Header, t reflect.Type) map[string][]string {
+	fields, ok := taggedFields(t, "header")
+	if !ok {
+		return header
+	}
+
+	src := make(map[string][]string)
+	for _, f := range fields {
+		values := header.Values(f.name)
+		if len(values) == 0 {
+			continue
+		}
+		if f.slice {
+			values = splitHeaderList(values)
+		}
+		src[f.name] = values
+	}
+	return src
+}
+
+// taggedField is a struct field decoded from a request value named by its tag
+type taggedField struct {
+	name     string
+	slice    bool
+	required bool
+}
+
+// taggedFields lists the exported fields of the struct t (or *t) under the names
+// given by tag, defaulting to the field name. ok is false if t is not a struct
+func taggedFields(t reflect.Type, tag string) (fields []taggedField, ok bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, taggedField{
+			name:     name,
+			slice:    field.Type.Kind() == reflect.Slice,
+			required: slices.Contains(strings.Split(opts, ","), "required"),
+		})
+	}
+	return fields, true
+}
+
+// splitHeaderList splits comma-separated header values into their elements
+func splitHeaderList(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// MultiPath decodes all path parameters of the matched pattern into a struct, using
+// `path` struct tags or else the field names, e.g. for "/orgs/{org}/repos/{repo}":
+//
+//	type RepoPath struct {
+//		Org  string `path:"org"`
+//		Repo string `path:"repo"`
+//	}
+//
+// Unlike Path, it does not take a parameter position, so it can be combined with
+// Path parameters freely. A wildcard such as "{rest...}" is decoded as one string;
+// split it in the handler to capture repeating segments.
+// Values that fail to convert are rejected with 400
+type MultiPath[T any] struct {
+	Value T
+}
+
+func (p *MultiPath[T]) Extract(r *http.Request) error {
+	src := make(map[string][]string)
+	for _, name := range extractPatternNames(r.Pattern) {
+		if value := r.PathValue(name); value != "" {
+			src[name] = []string{value}
+		}
+	}
+
+	val := reflect.ValueOf(&p.Value).Elem()
+	target := getPointer(val)
+	if err := decodeTagged(pathDecoder(), "path", target, src); err != nil {
+		return NewPathParamsError(err)
+	}
+
+	if err := validate(r.Context(), target); err != nil {
+		return NewValidationError(err)
+	}
+
+	return nil
+}
+
+// pathDecoder decodes MultiPath values, reading `path` tags
+var pathDecoder = sync.OnceValue(func() *schema.Decoder {
+	decoder := newDefaultSchemaDecoder()
+	decoder.SetAliasTag("path")
+	return decoder
+})
+
+// Cookie decodes request cookies into a struct using `cookie` struct tags, e.g.
+// `cookie:"session_id"`. Fields without a tag use the field name, and slice fields
+// take every cookie sent under their name. A missing cookie leaves its field zero,
+// unless tagged required (`cookie:"session_id,required"`), which is rejected with
+// 400 missing_cookie. Values that fail to convert are rejected with 400 invalid_cookie
+type Cookie[T any] struct {
+	Value T
+}
+
+func (c *Cookie[T]) Extract(r *http.Request) error {
+	val := reflect.ValueOf(&c.Value).Elem()
+	src := make(map[string][]string)
+
+	fields, ok := taggedFields(val.Type(), "cookie")
+	if !ok {
+		for _, cookie := range r.Cookies() {
+			src[cookie.Name] = append(src[cookie.Name], cookie.Value)
+		}
+	}
+	for _, f := range fields {
+		cookies := r.CookiesNamed(f.name)
+		if len(cookies) == 0 {
+			if f.required {
+				return NewMissingCookieError(f.name)
+			}
+			continue
+		}
+		for _, cookie := range cookies {
+			src[f.name] = append(src[f.name], cookie.Value)
+		}
+	}
+
+	target := getPointer(val)
+	if err := decodeTagged(cookieDecoder(), "cookie", target, src); err != nil {
+		return NewCookieError(err)
+	}
+
+	if err := validate(r.Context(), target); err != nil {
+		return NewValidationError(err)
+	}
+
+	return nil
+}
+
+// cookieDecoder decodes Cookie values, reading `cookie` tags
+var cookieDecoder = sync.OnceValue(func() *schema.Decoder {
+	decoder := newDefaultSchemaDecoder()
+	decoder.SetAliasTag("cookie")
+	return decoder
+})
+
+// MultipartForm decodes a multipart/form-data body, e.g. a file upload. Regular
+// fields decode like Form, and fields tagged `file:"avatar"` of type
+// *multipart.FileHeader or []*multipart.FileHeader receive the uploaded files.
+// A missing file leaves its field nil unless tagged required (`file:"avatar,required"`).
+// Up to Config.MaxMultipartMemory bytes are kept in memory. Bodies that are not
+// multipart are rejected with 400
+type MultipartForm[T any] struct {
+	Value T
+}
+
+func (f *MultipartForm[T]) ReadsBody() bool {
+	return true
+}
+
+func (f *MultipartForm[T]) ExpectedContentType() string {
+	return "multipart/form-data"
+}
+
+func (f *MultipartForm[T]) Extract(r *http.Request) error {
+	limitBodyTime(r)
+	if err := r.ParseMultipartForm(global.get().MaxMultipartMemory); err != nil {
+		if errors.Is(err, errBodyReadTimeout) {
+			return NewBodyReadTimeoutError()
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return NewBodyTooLargeError(tooLarge.Limit)
+		}
+		return NewMultipartError(err)
+	}
+	if n, limit := len(r.MultipartForm.Value)+len(r.MultipartForm.File), global.get().MaxFormFields; limit > 0 && n > limit {
+		return NewTooManyFieldsError(n, limit)
+	}
+
+	val := reflect.ValueOf(&f.Value).Elem()
+	target := getPointer(val)
+	if err := decodeValues(formDecoder(), target, r.MultipartForm.Value); err != nil {
+		return err
+	}
+	if err := bindFiles(reflect.ValueOf(target).Elem(), r.MultipartForm.File); err != nil {
+		return err
+	}
+
+	if err := validate(r.Context(), target); err != nil {
+		return NewValidationError(err)
+	}
+
+	return nil
+}
+
+func (f *MultipartForm[T]) checkFileFields() {
+	checkFileFields(reflect.TypeFor[T]())
+}
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// fileFieldsChecker is implemented by the extractors that bind `file` tagged fields
+type fileFieldsChecker interface {
+	checkFileFields()
+}
+
+// checkFileFields panics if a `file` tagged field of the struct t has a type
+// bindFiles cannot set. H calls it when the handler is built
+func checkFileFields(t reflect.Type) {
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if _, ok := field.Tag.Lookup("file"); !ok || !field.IsExported() {
+			continue
+		}
+		if field.Type != fileHeaderType && field.Type != fileHeadersType {
+			log.Panicf("MultipartForm: file field %s must be *multipart.FileHeader or []*multipart.FileHeader, got %s", field.Name, field.Type)
+		}
+	}
+}
+
+// bindFiles sets the `file` tagged fields of the struct v from files
+func bindFiles(v reflect.Value, files map[string][]*multipart.FileHeader) error {
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("file")
+		if !ok || !field.IsExported() {
+			continue
+		}
+		if field.Type != fileHeaderType && field.Type != fileHeadersType {
+			// Rejected by checkFileFields when the handler was built
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		headers := files[name]
+		if len(headers) == 0 {
+			if slices.Contains(strings.Split(opts, ","), "required") {
+				return NewMissingFileError(name)
+			}
+			continue
+		}
+
+		if field.Type == fileHeaderType {
+			v.Field(i).Set(reflect.ValueOf(headers[0]))
+		} else {
+			v.Field(i).Set(reflect.ValueOf(headers))
+		}
+	}
+	return nil
+}
+
+// RequestInfo extracts common request metadata, for handlers that would
+// otherwise take *http.Request only to read it.
+// Pattern is the ServeMux pattern that matched the request, if any
+type RequestInfo struct {
+	Method     string
+	Path       string
+	RemoteAddr string
+	Host       string
+	UserAgent  string
+	Pattern    string
+}
+
+func (ri *RequestInfo) Extract(r *http.Request) error {
+	ri.Method = r.Method
+	ri.Path = r.URL.Path
+	ri.RemoteAddr = r.RemoteAddr
+	ri.Host = r.Host
+	ri.UserAgent = r.UserAgent()
+	ri.Pattern = r.Pattern
+	return nil
+}
+
+// BodyReader gives the handler the request body as an io.Reader, for streaming
+// it somewhere without reading it into memory first.
+// As a body extractor it is only buffered when combined with another one
+// or when Config.BufferRequestBody is set; Config.BodyReadTimeout does not apply
+type BodyReader struct {
+	Value io.Reader
+}
+
+func (b *BodyReader) ReadsBody() bool {
+	return true
+}
+
+func (b *BodyReader) Extract(r *http.Request) error {
+	if r.Body == nil {
+		b.Value = http.NoBody
+		return nil
+	}
+	b.Value = r.Body
+	return nil
+}
+
+// RawBody extracts the request body as read from the wire (after decompression),
+// e.g. to verify a webhook signature. It can be combined with other body extractors
+// such as JSON, which then see the same bytes. Bound its size with Config.MaxBodySize
+type RawBody struct {
+	Value []byte
+}
+
+func (b *RawBody) ReadsBody() bool {
+	return true
+}
+
+func (b *RawBody) Extract(r *http.Request) error {
+	if r.Body == nil {
+		b.Value = nil
+		return nil
+	}
+
+	limitBodyTime(r)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return bodyReadError(err)
+	}
+	b.Value = body
+	// Leave the body readable for a handler that also takes *http.Request
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+// XML extracts an XML request body into T, like JSON does for JSON bodies.
+// Decoding uses Config.XMLUnmarshalFunc, encoding/xml by default. Malformed documents
+// are rejected with 400 invalid_xml_syntax and values of the wrong type with
+// 400 invalid_xml_type
+type XML[T any] struct {
+	Value T
+}
+
+func (x *XML[T]) ReadsBody() bool {
+	return true
+}
+
+func (x *XML[T]) ExpectedContentType() string {
+	return "application/xml, text/xml"
+}
+
+func (x *XML[T]) Extract(r *http.Request) error {
+	limitBodyTime(r)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return bodyReadError(err)
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return NewEmptyBodyError()
+	}
+
+	target := getPointer(reflect.ValueOf(&x.Value).Elem())
+	if err := xmlUnmarshal(body, target); err != nil {
+		return NewXMLError(err)
+	}
+
+	if err := validate(r.Context(), target); err != nil {
+		return NewValidationError(err)
+	}
+
+	return nil
+}
+
+// Patch extracts a JSON object body for partial updates. Besides the decoded
+// Value it records which top-level keys the client sent, so a field set to its
+// zero value can be told apart from one that was omitted.
+// Only the fields present in the body are validated
+type Patch[T any] struct {
+	Value   T
+	present map[string]bool
+}
+
+func (p *Patch[T]) ReadsBody() bool {
+	return true
+}
+
+func (p *Patch[T]) ExpectedContentType() string {
+	return "application/json"
+}
+
+func (p *Patch[T]) Extract(r *http.Request) error {
+	limitBodyTime(r)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return bodyReadError(err)
+	}
+
+	if len(body) == 0 {
+		return NewEmptyBodyError()
+	}
+
+	var fields map[string]json.RawMessage
+	if err := jsonUnmarshal(body, &fields); err != nil {
+		return err
+	}
+
+	p.present = make(map[string]bool, len(fields))
+	for key := range fields {
+		p.present[key] = true
+	}
+
+	target := getPointer(reflect.ValueOf(&p.Value).Elem())
+	if err := jsonUnmarshal(body, target); err != nil {
+		return err
+	}
+
+	if err := p.validate(r.Context(), target); err != nil {
+		return NewValidationError(err)
+	}
+
+	return nil
+}
+
+// Present reports whether the body contained the top-level key field,
+// as named in the JSON (i.e. by its `json` tag)
+func (p *Patch[T]) Present(field string) bool {
+	return p.present[field]
+}
+
+// validate runs the validator over the struct fields that were present in the body
+func (p *Patch[T]) validate(ctx context.Context, target any) error {
+	if !global.get().EnableValidation {
+		return nil
+	}
+	v := requestValidator(ctx)
+	if v == nil {
+		return nil
+	}
+
+	t := reflect.TypeOf(target).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	names := make([]string, 0, len(p.present))
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = field.Name
+		}
+		if p.present[key] {
+			names = append(names, field.Name)
+		}
+	}
+
+	return v.StructPartial(target, names...)
+}
@@ -0,0 +1,969 @@
+package m
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVersionExtractor(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		value  string
+		number int
+	}{
+		{"v1", "application/vnd.api.v1+json", "v1", 1},
+		{"v2 with params", "application/vnd.api.v2+json; charset=utf-8", "v2", 2},
+		{"among other types", "text/html, application/vnd.acme.v2+json;q=0.9", "v2", 2},
+		{"no suffix", "application/vnd.acme.v3", "v3", 3},
+		{"highest quality wins", "application/vnd.api.v1+json;q=0.5, application/vnd.api.v2+json", "v2", 2},
+		{"q=0 is refused", "application/vnd.api.v3+json;q=0, application/vnd.api.v1+json;q=0.2", "v1", 1},
+		{"no version", "application/json", "", 0},
+		{"empty header", "", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Reset()
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept", tt.accept)
+
+			var v Version
+			if err := v.Extract(req); err != nil {
+				t.Fatalf("Extract failed: %v", err)
+			}
+			if v.Value != tt.value || v.Number != tt.number {
+				t.Errorf("expected %s/%d, got %s/%d", tt.value, tt.number, v.Value, v.Number)
+			}
+		})
+	}
+
+	t.Run("unsupported version", func(t *testing.T) {
+		Reset()
+		Configure(WithAPIVersions("v1", "v2"))
+		defer Reset()
+
+		handler := H(func(v Version) string {
+			return v.Value
+		})
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/vnd.api.v2+json")
+		handler(rec, req)
+		if rec.Code != 200 || rec.Body.String() != "v2" {
+			t.Errorf("unexpected response: %d %s", rec.Code, rec.Body.String())
+		}
+
+		rec = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/vnd.api.v99+json")
+		handler(rec, req)
+		if rec.Code != 406 {
+			t.Errorf("expected status 406, got %d", rec.Code)
+		}
+
+		var v Version
+		err := v.Extract(req)
+		var extractErr *ExtractError
+		if !errors.As(err, &extractErr) || extractErr.Type != ErrTypeVersion || extractErr.Value != "v99" {
+			t.Errorf("expected unsupported version error, got %v", err)
+		}
+	})
+}
+
+func TestPatchExtractor(t *testing.T) {
+	type Profile struct {
+		Name   string  `json:"name" validate:"required"`
+		Email  string  `json:"email" validate:"required,email"`
+		Age    int     `json:"age" validate:"gte=0"`
+		Active bool    `json:"active"`
+		Bio    *string `json:"bio"`
+	}
+
+	patch := func(body string) *http.Request {
+		req := httptest.NewRequest("PATCH", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	t.Run("detects zero-valued fields", func(t *testing.T) {
+		Reset()
+		var p Patch[Profile]
+		if err := p.Extract(patch(`{"age":0,"active":false,"bio":null}`)); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+
+		for _, field := range []string{"age", "active", "bio"} {
+			if !p.Present(field) {
+				t.Errorf("expected %s to be present", field)
+			}
+		}
+		for _, field := range []string{"name", "email", "Age"} {
+			if p.Present(field) {
+				t.Errorf("expected %s to be absent", field)
+			}
+		}
+		if p.Value.Age != 0 || p.Value.Active || p.Value.Bio != nil {
+			t.Errorf("unexpected value: %+v", p.Value)
+		}
+	})
+
+	t.Run("validates only present fields", func(t *testing.T) {
+		requireValidator(t)
+		Reset()
+		var p Patch[Profile]
+		if err := p.Extract(patch(`{"age":30}`)); err != nil {
+			t.Fatalf("omitted required fields should not fail: %v", err)
+		}
+
+		err := p.Extract(patch(`{"email":"not-an-email"}`))
+		var extractErr *ExtractError
+		if !errors.As(err, &extractErr) || extractErr.Type != ErrTypeValidation {
+			t.Errorf("expected validation error, got %v", err)
+		}
+	})
+
+	t.Run("in handler", func(t *testing.T) {
+		Reset()
+		handler := H(func(p Patch[Profile]) map[string]bool {
+			return map[string]bool{"name": p.Present("name"), "active": p.Present("active")}
+		})
+
+		rec := httptest.NewRecorder()
+		handler(rec, patch(`{"active":false}`))
+		if rec.Code != 200 {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+
+		var result map[string]bool
+		parseJSONResponse(t, rec.Body.Bytes(), &result)
+		if result["name"] || !result["active"] {
+			t.Errorf("unexpected presence: %v", result)
+		}
+	})
+
+	t.Run("non-object body", func(t *testing.T) {
+		Reset()
+		handler := H(func(p Patch[Profile]) Profile { return p.Value })
+
+		rec := httptest.NewRecorder()
+		handler(rec, patch(`[1,2]`))
+		if rec.Code != 400 {
+			t.Errorf("expected status 400, got %d", rec.Code)
+		}
+	})
+}
+
+func TestRequestInfoExtractor(t *testing.T) {
+	Reset()
+	handler := H(func(info RequestInfo) RequestInfo {
+		return info
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /users/{id}", handler)
+
+	req := httptest.NewRequest("POST", "http://api.example.com/users/42?x=1", nil)
+	req.RemoteAddr = "203.0.113.7:5678"
+	req.Header.Set("User-Agent", "mint-test/1.0")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	var info RequestInfo
+	parseJSONResponse(t, rec.Body.Bytes(), &info)
+	expected := RequestInfo{
+		Method:     "POST",
+		Path:       "/users/42",
+		RemoteAddr: "203.0.113.7:5678",
+		Host:       "api.example.com",
+		UserAgent:  "mint-test/1.0",
+		Pattern:    "POST /users/{id}",
+	}
+	if info != expected {
+		t.Errorf("expected %+v, got %+v", expected, info)
+	}
+}
+
+func TestAuthHeaderExtractor(t *testing.T) {
+	handler := H(func(auth AuthHeader) AuthHeader {
+		return auth
+	})
+
+	call := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	t.Run("accepted schemes", func(t *testing.T) {
+		Reset()
+		Configure(WithAuthSchemes("Bearer", "Signature", "HMAC"))
+		defer Reset()
+
+		tests := []struct {
+			header string
+			want   AuthHeader
+		}{
+			{"Bearer abc.def.ghi", AuthHeader{Scheme: "Bearer", Params: "abc.def.ghi"}},
+			{`Signature keyId="k1",algorithm="rsa-sha256",signature="c2ln"`, AuthHeader{Scheme: "Signature", Params: `keyId="k1",algorithm="rsa-sha256",signature="c2ln"`}},
+			{"hmac  client:c2lnbmF0dXJl", AuthHeader{Scheme: "HMAC", Params: "client:c2lnbmF0dXJl"}},
+			{"Bearer", AuthHeader{Scheme: "Bearer"}},
+		}
+		for _, tt := range tests {
+			rec := call(tt.header)
+			if rec.Code != 200 {
+				t.Errorf("%q: expected status 200, got %d", tt.header, rec.Code)
+				continue
+			}
+			var got AuthHeader
+			parseJSONResponse(t, rec.Body.Bytes(), &got)
+			if got != tt.want {
+				t.Errorf("%q: expected %+v, got %+v", tt.header, tt.want, got)
+			}
+		}
+	})
+
+	t.Run("rejected scheme", func(t *testing.T) {
+		Reset()
+		Configure(WithAuthSchemes("Bearer", "Signature"))
+		defer Reset()
+
+		rec := call("Basic YWxpY2U6c2VjcmV0")
+		if rec.Code != 401 {
+			t.Fatalf("expected status 401, got %d", rec.Code)
+		}
+		if got := rec.Header().Values("WWW-Authenticate"); len(got) != 2 || got[0] != "Bearer" || got[1] != "Signature" {
+			t.Errorf("unexpected WWW-Authenticate: %q", got)
+		}
+		var httpErr HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+		if httpErr.Err != "unauthorized" || !strings.Contains(httpErr.Message, "Basic") {
+			t.Errorf("unexpected error: %+v", httpErr)
+		}
+	})
+
+	t.Run("missing header", func(t *testing.T) {
+		Reset()
+		rec := call("")
+		if rec.Code != 401 {
+			t.Errorf("expected status 401, got %d", rec.Code)
+		}
+	})
+
+	t.Run("any scheme without allow-list", func(t *testing.T) {
+		Reset()
+		rec := call("Custom token")
+		var got AuthHeader
+		parseJSONResponse(t, rec.Body.Bytes(), &got)
+		if got.Scheme != "Custom" || got.Params != "token" {
+			t.Errorf("unexpected header: %+v", got)
+		}
+	})
+}
+
+type requestHeaders struct {
+	RequestID string    `header:"X-Request-ID" json:"request_id"`
+	Tenant    int       `header:"X-Tenant,required" json:"tenant"`
+	Languages []string  `header:"Accept-Language" json:"languages"`
+	Since     time.Time `header:"If-Modified-Since" time_format:"Mon, 02 Jan 2006 15:04:05 GMT" json:"since"`
+	Trace     string    `header:"X-Trace" validate:"omitempty,len=8" json:"trace"`
+}
+
+func TestHeaderExtractor(t *testing.T) {
+	handler := H(func(h Header[requestHeaders]) requestHeaders {
+		return h.Value
+	})
+
+	call := func(headers map[string][]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		for name, values := range headers {
+			for _, value := range values {
+				req.Header.Add(name, value)
+			}
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	t.Run("decodes tagged headers", func(t *testing.T) {
+		Reset()
+		rec := call(map[string][]string{
+			"x-request-id":      {"req-1"},
+			"X-Tenant":          {"42"},
+			"Accept-Language":   {"en-US, fr", "de"},
+			"If-Modified-Since": {"Wed, 21 Oct 2015 07:28:00 GMT"},
+		})
+		if rec.Code != 200 {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+
+		var got requestHeaders
+		parseJSONResponse(t, rec.Body.Bytes(), &got)
+		want := requestHeaders{
+			RequestID: "req-1",
+			Tenant:    42,
+			Languages: []string{"en-US", "fr", "de"},
+			Since:     time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC),
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("missing optional headers stay zero", func(t *testing.T) {
+		Reset()
+		rec := call(map[string][]string{"X-Tenant": {"1"}})
+		var got requestHeaders
+		parseJSONResponse(t, rec.Body.Bytes(), &got)
+		if rec.Code != 200 || got.RequestID != "" || got.Languages != nil || !got.Since.IsZero() {
+			t.Errorf("expected zero values, got %d %+v", rec.Code, got)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		headers map[string][]string
+		errType string
+		message string
+	}{
+		{"missing required header", nil, "invalid_header", "X-Tenant is required"},
+		{"conversion failure", map[string][]string{"X-Tenant": {"acme"}}, "invalid_header", "X-Tenant: invalid value"},
+		{"validation failure", map[string][]string{"X-Tenant": {"1"}, "X-Trace": {"abc"}}, "validation_failed", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.errType == "validation_failed" {
+				requireValidator(t)
+			}
+			Reset()
+			rec := call(tt.headers)
+			if rec.Code != 400 {
+				t.Fatalf("expected status 400, got %d", rec.Code)
+			}
+			var httpErr HTTPError
+			parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+			if httpErr.Err != tt.errType {
+				t.Errorf("expected error %q, got %q", tt.errType, httpErr.Err)
+			}
+			if tt.message != "" && httpErr.Message != tt.message {
+				t.Errorf("expected message %q, got %q", tt.message, httpErr.Message)
+			}
+		})
+	}
+}
+
+type slotPath struct {
+	Region string `path:"region"`
+	Zone   string `path:"zone"`
+	Rack   int    `path:"rack" validate:"min=1"`
+	Slot   uint   `path:"slot"`
+}
+
+func TestMultiPathExtractor(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /regions/{region}/zones/{zone}/racks/{rack}/slots/{slot}", H(func(p MultiPath[slotPath]) slotPath {
+		return p.Value
+	}))
+	mux.HandleFunc("GET /mixed/{org}/{repo}", H(func(repo Path[string], p MultiPath[struct{ Org, Repo string }]) string {
+		return repo.Value + "|" + p.Value.Org + "/" + p.Value.Repo
+	}))
+	mux.HandleFunc("GET /files/{bucket}/{key...}", H(func(p MultiPath[struct {
+		Bucket string `path:"bucket"`
+		Key    string `path:"key"`
+	}]) []string {
+		return append([]string{p.Value.Bucket}, strings.Split(p.Value.Key, "/")...)
+	}))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	t.Run("four params into one struct", func(t *testing.T) {
+		Reset()
+		rec := get("/regions/eu/zones/eu-1a/racks/12/slots/3")
+		if rec.Code != 200 {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var got slotPath
+		parseJSONResponse(t, rec.Body.Bytes(), &got)
+		if want := (slotPath{Region: "eu", Zone: "eu-1a", Rack: 12, Slot: 3}); got != want {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("combined with Path", func(t *testing.T) {
+		Reset()
+		if rec := get("/mixed/golang/go"); rec.Body.String() != "golang|golang/go" {
+			t.Errorf("unexpected body %q", rec.Body.String())
+		}
+	})
+
+	t.Run("wildcard", func(t *testing.T) {
+		Reset()
+		var got []string
+		parseJSONResponse(t, get("/files/media/2024/06/photo.jpg").Body.Bytes(), &got)
+		if want := []string{"media", "2024", "06", "photo.jpg"}; !slices.Equal(got, want) {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	})
+
+	t.Run("conversion failure", func(t *testing.T) {
+		Reset()
+		rec := get("/regions/eu/zones/eu-1a/racks/twelve/slots/3")
+		var httpErr HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+		if rec.Code != 400 || httpErr.Err != "invalid_path_parameter" || !strings.Contains(httpErr.Message, "rack") {
+			t.Errorf("unexpected response %d %+v", rec.Code, httpErr)
+		}
+	})
+
+	t.Run("validation failure", func(t *testing.T) {
+		requireValidator(t)
+		Reset()
+		rec := get("/regions/eu/zones/eu-1a/racks/0/slots/3")
+		var httpErr HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+		if rec.Code != 400 || httpErr.Err != "validation_failed" {
+			t.Errorf("unexpected response %d %+v", rec.Code, httpErr)
+		}
+	})
+}
+
+func TestPathWildcard(t *testing.T) {
+	Reset()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /static/{path...}", H(func(path Path[string]) string {
+		return path.Value
+	}))
+	mux.HandleFunc("GET /exact/{id}/{$}", H(func(id Path[int]) string {
+		return strconv.Itoa(id.Value)
+	}))
+
+	for path, want := range map[string]string{
+		"/static/css/site.css": "css/site.css",
+		"/exact/7/":            "7",
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != 200 || rec.Body.String() != want {
+			t.Errorf("%s: expected %q, got %d %q", path, want, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+type sessionInfo struct {
+	SessionID string   `cookie:"session_id,required" json:"session_id"`
+	UserID    int      `cookie:"uid" json:"user_id"`
+	Remember  bool     `cookie:"remember" json:"remember"`
+	Theme     string   `cookie:"theme" validate:"omitempty,oneof=light dark" json:"theme"`
+	Flags     []string `cookie:"flag" json:"flags"`
+}
+
+func TestCookieExtractor(t *testing.T) {
+	handler := H(func(c Cookie[sessionInfo]) sessionInfo {
+		return c.Value
+	})
+
+	call := func(cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	t.Run("decodes named cookies", func(t *testing.T) {
+		Reset()
+		rec := call(
+			&http.Cookie{Name: "session_id", Value: "abc123"},
+			&http.Cookie{Name: "uid", Value: "7"},
+			&http.Cookie{Name: "remember", Value: "true"},
+			&http.Cookie{Name: "theme", Value: "dark"},
+			&http.Cookie{Name: "flag", Value: "beta"},
+			&http.Cookie{Name: "flag", Value: "new-ui"},
+			&http.Cookie{Name: "other", Value: "ignored"},
+		)
+		if rec.Code != 200 {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+
+		var got sessionInfo
+		parseJSONResponse(t, rec.Body.Bytes(), &got)
+		want := sessionInfo{SessionID: "abc123", UserID: 7, Remember: true, Theme: "dark", Flags: []string{"beta", "new-ui"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("missing optional cookies stay zero", func(t *testing.T) {
+		Reset()
+		rec := call(&http.Cookie{Name: "session_id", Value: "abc123"})
+		var got sessionInfo
+		parseJSONResponse(t, rec.Body.Bytes(), &got)
+		if want := (sessionInfo{SessionID: "abc123"}); rec.Code != 200 || !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %+v, got %d %+v", want, rec.Code, got)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		cookies []*http.Cookie
+		errType string
+		message string
+	}{
+		{"missing required cookie", nil, "missing_cookie", "missing required cookie: session_id"},
+		{
+			"conversion failure",
+			[]*http.Cookie{{Name: "session_id", Value: "abc"}, {Name: "uid", Value: "seven"}},
+			"invalid_cookie", "uid: invalid value",
+		},
+		{
+			"validation failure",
+			[]*http.Cookie{{Name: "session_id", Value: "abc"}, {Name: "theme", Value: "neon"}},
+			"validation_failed", "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.errType == "validation_failed" {
+				requireValidator(t)
+			}
+			Reset()
+			rec := call(tt.cookies...)
+			if rec.Code != 400 {
+				t.Fatalf("expected status 400, got %d", rec.Code)
+			}
+			var httpErr HTTPError
+			parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+			if httpErr.Err != tt.errType {
+				t.Errorf("expected error %q, got %q", tt.errType, httpErr.Err)
+			}
+			if tt.message != "" && httpErr.Message != tt.message {
+				t.Errorf("expected message %q, got %q", tt.message, httpErr.Message)
+			}
+		})
+	}
+}
+
+type uploadForm struct {
+	Title       string                  `schema:"title" validate:"required"`
+	Avatar      *multipart.FileHeader   `file:"avatar,required"`
+	Attachments []*multipart.FileHeader `file:"attachments"`
+}
+
+// multipartBody builds a multipart/form-data body from fields and files,
+// each file given as field name and file name to content
+func multipartBody(t *testing.T, fields map[string]string, files map[string]map[string]string) (*bytes.Buffer, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for name, value := range fields {
+		mw.WriteField(name, value)
+	}
+	for field, named := range files {
+		for filename, content := range named {
+			fw, err := mw.CreateFormFile(field, filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.WriteString(fw, content)
+		}
+	}
+	mw.Close()
+	return &buf, mw.FormDataContentType()
+}
+
+func TestMultipartFormExtractor(t *testing.T) {
+	type summary struct {
+		Title       string   `json:"title"`
+		Avatar      string   `json:"avatar"`
+		Content     string   `json:"content"`
+		Attachments []string `json:"attachments"`
+	}
+	handler := H(func(form MultipartForm[uploadForm]) (summary, error) {
+		f, err := form.Value.Avatar.Open()
+		if err != nil {
+			return summary{}, err
+		}
+		defer f.Close()
+		content, _ := io.ReadAll(f)
+
+		s := summary{Title: form.Value.Title, Avatar: form.Value.Avatar.Filename, Content: string(content)}
+		for _, a := range form.Value.Attachments {
+			s.Attachments = append(s.Attachments, a.Filename)
+		}
+		slices.Sort(s.Attachments)
+		return s, nil
+	})
+
+	call := func(body io.Reader, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	t.Run("binds fields and files", func(t *testing.T) {
+		Reset()
+		body, ct := multipartBody(t,
+			map[string]string{"title": "Holiday"},
+			map[string]map[string]string{
+				"avatar":      {"me.png": "png-bytes"},
+				"attachments": {"a.txt": "a", "b.txt": "b"},
+			})
+		rec := call(body, ct)
+		if rec.Code != 200 {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+
+		var got summary
+		parseJSONResponse(t, rec.Body.Bytes(), &got)
+		want := summary{Title: "Holiday", Avatar: "me.png", Content: "png-bytes", Attachments: []string{"a.txt", "b.txt"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("files beyond the memory limit", func(t *testing.T) {
+		Reset()
+		Configure(WithMaxMultipartMemory(16))
+		defer Reset()
+
+		large := strings.Repeat("x", 1024)
+		body, ct := multipartBody(t,
+			map[string]string{"title": "Big"},
+			map[string]map[string]string{"avatar": {"big.bin": large}})
+		rec := call(body, ct)
+
+		var got summary
+		parseJSONResponse(t, rec.Body.Bytes(), &got)
+		if rec.Code != 200 || got.Content != large {
+			t.Errorf("expected the file to be read back, got %d with %d bytes", rec.Code, len(got.Content))
+		}
+	})
+
+	t.Run("unsupported file field type panics in H", func(t *testing.T) {
+		type badForm struct {
+			Avatar []byte `file:"avatar"`
+		}
+		defer func() {
+			r := recover()
+			if r == nil {
+				t.Fatal("expected H to panic")
+			}
+			if msg := fmt.Sprint(r); !strings.Contains(msg, "file field Avatar must be") {
+				t.Errorf("unexpected panic message: %s", msg)
+			}
+		}()
+		H(func(form MultipartForm[badForm]) {})
+	})
+
+	tests := []struct {
+		name    string
+		body    func() (io.Reader, string)
+		errType string
+	}{
+		{
+			"not multipart",
+			func() (io.Reader, string) {
+				return strings.NewReader("title=Holiday"), "application/x-www-form-urlencoded"
+			},
+			"invalid_multipart",
+		},
+		{
+			"missing required file",
+			func() (io.Reader, string) {
+				return multipartBody(t, map[string]string{"title": "Holiday"}, nil)
+			},
+			"missing_file",
+		},
+		{
+			"validation failure",
+			func() (io.Reader, string) {
+				return multipartBody(t, nil, map[string]map[string]string{"avatar": {"me.png": "png"}})
+			},
+			"validation_failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.errType == "validation_failed" {
+				requireValidator(t)
+			}
+			Reset()
+			rec := call(tt.body())
+			if rec.Code != 400 {
+				t.Fatalf("expected status 400, got %d", rec.Code)
+			}
+			var httpErr HTTPError
+			parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+			if httpErr.Err != tt.errType {
+				t.Errorf("expected error %q, got %q", tt.errType, httpErr.Err)
+			}
+		})
+	}
+}
+
+func TestRawBodyExtractor(t *testing.T) {
+	type Event struct {
+		Type string `json:"type"`
+	}
+	secret := []byte("whsec")
+	sign := func(body []byte) string {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	// A webhook verifying the signature of the exact bytes it parses
+	handler := H(func(raw RawBody, event JSON[Event], r *http.Request) (string, error) {
+		if !hmac.Equal([]byte(sign(raw.Value)), []byte(r.Header.Get("X-Signature"))) {
+			return "", Unauthorized("bad signature")
+		}
+		return event.Value.Type, nil
+	})
+
+	send := func(body, signature string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Signature", signature)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	body := `{"type": "invoice.paid"}`
+
+	t.Run("shares the body with JSON", func(t *testing.T) {
+		Reset()
+		rec := send(body, sign([]byte(body)))
+		if rec.Code != 200 || rec.Body.String() != "invoice.paid" {
+			t.Errorf("unexpected response: %d %s", rec.Code, rec.Body.String())
+		}
+
+		if rec := send(body, "forged"); rec.Code != 401 {
+			t.Errorf("expected status 401, got %d", rec.Code)
+		}
+	})
+
+	t.Run("alone", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		H(func(raw RawBody, r *http.Request) string {
+			rest, _ := io.ReadAll(r.Body)
+			return string(raw.Value) + "|" + string(rest)
+		})(rec, httptest.NewRequest("POST", "/", strings.NewReader("abc")))
+		if rec.Body.String() != "abc|abc" {
+			t.Errorf("expected the body to stay readable, got %q", rec.Body.String())
+		}
+	})
+
+	t.Run("body size limit", func(t *testing.T) {
+		Reset()
+		Configure(WithMaxBodySize(int64(len(body))))
+		defer Reset()
+
+		if rec := send(body, sign([]byte(body))); rec.Code != 200 {
+			t.Errorf("expected a body at the limit to pass, got %d", rec.Code)
+		}
+
+		large := `{"type": "invoice.paid", "padding": "` + strings.Repeat("x", 100) + `"}`
+		rec := send(large, sign([]byte(large)))
+		if rec.Code != 413 {
+			t.Fatalf("expected status 413, got %d", rec.Code)
+		}
+		var httpErr HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+		if httpErr.Err != "payload_too_large" {
+			t.Errorf("unexpected error %+v", httpErr)
+		}
+	})
+
+	t.Run("body size limit with a single extractor", func(t *testing.T) {
+		Reset()
+		Configure(WithMaxBodySize(4))
+		defer Reset()
+
+		rec := httptest.NewRecorder()
+		H(func(f Form[struct{ Name string }]) string { return f.Value.Name })(rec, postForm("/", url.Values{"Name": {"too-long"}}))
+		if rec.Code != 413 {
+			t.Errorf("expected status 413 for a form, got %d", rec.Code)
+		}
+	})
+}
+
+func TestBodyReaderExtractor(t *testing.T) {
+	t.Run("streams without buffering", func(t *testing.T) {
+		Reset()
+		const chunkSize, chunks = 64 << 10, 64 // 4 MiB in total
+		firstRead := make(chan struct{})
+
+		handler := H(func(body BodyReader) (map[string]int64, error) {
+			buf := make([]byte, chunkSize)
+			if _, err := io.ReadFull(body.Value, buf); err != nil {
+				return nil, err
+			}
+			close(firstRead)
+			rest, err := io.Copy(io.Discard, body.Value)
+			return map[string]int64{"bytes": chunkSize + rest}, err
+		})
+
+		pr, pw := io.Pipe()
+		go func() {
+			chunk := bytes.Repeat([]byte("x"), chunkSize)
+			pw.Write(chunk)
+			// The rest is only sent once the handler has consumed the first chunk,
+			// which it could not do if the body were read up front
+			select {
+			case <-firstRead:
+			case <-time.After(5 * time.Second):
+				pw.CloseWithError(errors.New("handler did not stream the body"))
+				return
+			}
+			for i := 1; i < chunks; i++ {
+				pw.Write(chunk)
+			}
+			pw.Close()
+		}()
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("POST", "/upload", pr))
+		if rec.Code != 200 {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var result map[string]int64
+		parseJSONResponse(t, rec.Body.Bytes(), &result)
+		if result["bytes"] != chunkSize*chunks {
+			t.Errorf("expected %d bytes, got %d", chunkSize*chunks, result["bytes"])
+		}
+	})
+
+	t.Run("nil body", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Body = nil
+		var b BodyReader
+		if err := b.Extract(req); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		if n, _ := io.Copy(io.Discard, b.Value); n != 0 {
+			t.Errorf("expected empty body, got %d bytes", n)
+		}
+	})
+}
+
+func TestXMLExtractor(t *testing.T) {
+	type Order struct {
+		XMLName xml.Name `xml:"order"`
+		ID      int      `xml:"id,attr"`
+		Item    string   `xml:"item" validate:"required"`
+		Qty     int      `xml:"qty"`
+	}
+	handler := H(func(o XML[Order]) Order { return o.Value })
+
+	send := func(contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+	errorOf := func(rec *httptest.ResponseRecorder) string {
+		var httpErr HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+		return httpErr.Err
+	}
+
+	t.Run("decodes the body", func(t *testing.T) {
+		Reset()
+		rec := send("application/xml", `<order id="7"><item>book</item><qty>2</qty></order>`)
+		if rec.Code != 200 {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var got Order
+		parseJSONResponse(t, rec.Body.Bytes(), &got)
+		if got.ID != 7 || got.Item != "book" || got.Qty != 2 {
+			t.Errorf("unexpected order %+v", got)
+		}
+	})
+
+	tests := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{"empty body", "", "empty_body"},
+		{"malformed document", `<order><item>book</order>`, "invalid_xml_syntax"},
+		{"truncated document", `<order><item>book`, "invalid_xml_syntax"},
+		{"wrong type", `<order><item>book</item><qty>two</qty></order>`, "invalid_xml_type"},
+		{"failed validation", `<order><qty>2</qty></order>`, "validation_failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err == "validation_failed" {
+				requireValidator(t)
+			}
+			Reset()
+			rec := send("application/xml", tt.body)
+			if rec.Code != 400 || errorOf(rec) != tt.err {
+				t.Errorf("expected 400 %s, got %d %s", tt.err, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("content type enforcement accepts both XML types", func(t *testing.T) {
+		Reset()
+		Configure(WithContentTypeEnforcement(true))
+		defer Reset()
+
+		body := `<order><item>book</item></order>`
+		for _, ct := range []string{"application/xml", "text/xml; charset=utf-8"} {
+			if rec := send(ct, body); rec.Code != 200 {
+				t.Errorf("%s: expected status 200, got %d", ct, rec.Code)
+			}
+		}
+		if rec := send("application/json", body); rec.Code != 415 {
+			t.Errorf("expected status 415 for JSON, got %d", rec.Code)
+		}
+	})
+
+	t.Run("custom unmarshal", func(t *testing.T) {
+		Reset()
+		called := false
+		Configure(WithXMLUnmarshal(func(data []byte, v any) error {
+			called = true
+			return xml.Unmarshal(data, v)
+		}))
+		defer Reset()
+
+		if rec := send("application/xml", `<order><item>book</item></order>`); rec.Code != 200 || !called {
+			t.Errorf("expected the custom unmarshal to decode the body, got %d (called %v)", rec.Code, called)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package m
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// ErrNotHandled is returned by a handler wrapped with Fallback to pass the request
+// on to the next handler. Outside of Fallback it is a plain 404
+var ErrNotHandled error = &HTTPError{Code: http.StatusNotFound, Err: "not_found", Message: "not handled"}
+
+type fallbackKey struct{}
+
+// fallbackState is shared between Fallback and the H handlers it calls
+type fallbackState struct {
+	passed bool
+}
+
+// Fallback returns a handler that calls each handler in turn until one does not
+// return ErrNotHandled, either as its error or as the error of a Result.
+// The request body is buffered so every handler can read it in full.
+// A handler passing the request on must not have written to the response or
+// changed its headers. If all handlers pass, the response is a 404.
+// Handlers are built like H, but the middleware registered with Use wraps
+// the Fallback handler as a whole, so it runs once per request
+func Fallback(handlers ...any) http.HandlerFunc {
+	if len(handlers) == 0 {
+		panic("Fallback: at least one handler is required")
+	}
+	wrapped := make([]http.HandlerFunc, len(handlers))
+	for i, h := range handlers {
+		wrapped[i] = newHandler(h)
+	}
+
+	return withMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		// Share one request ID between the attempts
+		if RequestID(r.Context()) == "" {
+			r = withRequestID(w, r)
+		}
+		r = withBodyWriter(w, r)
+		id := RequestID(r.Context())
+
+		var body []byte
+		if r.Body != nil && r.Body != http.NoBody {
+			// Bound the buffered body as H would, before reading any of it
+			if limit := global.get().MaxBodySize; limit > 0 {
+				if r.ContentLength > limit {
+					if e := handleError(w, NewBodyTooLargeError(limit)); e != nil {
+						logger().Printf("failed to write error response: %v", e)
+					}
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+
+			limitBodyTime(r)
+			var err error
+			if body, err = io.ReadAll(r.Body); err != nil {
+				if e := handleError(w, bodyReadError(err)); e != nil {
+					logger().Printf("failed to write error response: %v", e)
+				}
+				return
+			}
+			r.Body.Close()
+		}
+
+		for _, handler := range wrapped {
+			state := &fallbackState{}
+			attempt := r.Clone(context.WithValue(r.Context(), fallbackKey{}, state))
+			if id != "" {
+				attempt.Header.Set(RequestIDHeader, id)
+			}
+			if body != nil {
+				attempt.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			handler(w, attempt)
+			if !state.passed {
+				return
+			}
+		}
+
+		if e := handleError(w, ErrNotHandled); e != nil {
+			logger().Printf("failed to write error response: %v", e)
+		}
+	})
+}
+
+// passedOn reports whether a handler called by Fallback returned ErrNotHandled,
+// recording it so Fallback moves on to the next handler
+func passedOn(r *http.Request, results []reflect.Value) bool {
+	state, _ := r.Context().Value(fallbackKey{}).(*fallbackState)
+	if state == nil {
+		return false
+	}
+
+	for _, result := range results {
+		if isNilValue(result) {
+			continue
+		}
+		var err error
+		switch v := result.Interface().(type) {
+		case error:
+			err = v
+		case resultMarker:
+			err = v.toResult().Err
+		}
+		if err != nil && errors.Is(err, ErrNotHandled) {
+			state.passed = true
+			return true
+		}
+	}
+	return false
+}
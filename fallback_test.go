@@ -0,0 +1,170 @@
+package m
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFallback(t *testing.T) {
+	newFlag := func(enabled bool) func(body JSON[User]) (string, error) {
+		return func(body JSON[User]) (string, error) {
+			if !enabled {
+				return "", ErrNotHandled
+			}
+			return "new: " + body.Value.Name, nil
+		}
+	}
+	legacy := func(body JSON[User]) string {
+		return "legacy: " + body.Value.Name
+	}
+
+	post := func(handler http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"Ann"}`))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	t.Run("primary handles the request", func(t *testing.T) {
+		Reset()
+		rec := post(Fallback(newFlag(true), legacy))
+		if rec.Code != 200 || rec.Body.String() != "new: Ann" {
+			t.Errorf("unexpected response: %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("secondary reads the same body", func(t *testing.T) {
+		Reset()
+		rec := post(Fallback(newFlag(false), legacy))
+		if rec.Code != 200 || rec.Body.String() != "legacy: Ann" {
+			t.Errorf("unexpected response: %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("wrapped sentinel in a Result", func(t *testing.T) {
+		Reset()
+		primary := func() Result[string] {
+			return Err[string](0, fmt.Errorf("variant B: %w", ErrNotHandled))
+		}
+		rec := post(Fallback(primary, legacy))
+		if rec.Body.String() != "legacy: Ann" {
+			t.Errorf("unexpected response: %q", rec.Body.String())
+		}
+	})
+
+	t.Run("other errors do not fall back", func(t *testing.T) {
+		Reset()
+		primary := func() error { return errors.New("user not found") }
+		rec := post(Fallback(primary, legacy))
+		if rec.Code != 404 || strings.Contains(rec.Body.String(), "legacy") {
+			t.Errorf("unexpected response: %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("one request ID across attempts", func(t *testing.T) {
+		Reset()
+		var ids []string
+		record := func(r *http.Request) error {
+			ids = append(ids, RequestID(r.Context()))
+			return ErrNotHandled
+		}
+		rec := post(Fallback(record, record, func(r *http.Request) string {
+			ids = append(ids, RequestID(r.Context()))
+			return "done"
+		}))
+		if len(ids) != 3 || ids[0] == "" || ids[0] != ids[1] || ids[1] != ids[2] {
+			t.Errorf("expected one shared ID, got %q", ids)
+		}
+		if got := rec.Header().Get(RequestIDHeader); got != ids[0] {
+			t.Errorf("expected response ID %q, got %q", ids[0], got)
+		}
+	})
+
+	t.Run("body size limit applies before buffering", func(t *testing.T) {
+		Reset()
+		Configure(WithMaxBodySize(16))
+		defer Reset()
+
+		for _, declared := range []int64{-1, 1 << 20} {
+			body := &countingReader{r: strings.NewReader(strings.Repeat("x", 1<<20))}
+			req := httptest.NewRequest("POST", "/", body)
+			req.ContentLength = declared
+			rec := httptest.NewRecorder()
+			Fallback(newFlag(false), legacy)(rec, req)
+
+			if rec.Code != 413 {
+				t.Errorf("content length %d: expected status 413, got %d", declared, rec.Code)
+			}
+			if body.n > 17 {
+				t.Errorf("content length %d: expected at most the limit to be read, read %d bytes", declared, body.n)
+			}
+		}
+	})
+
+	t.Run("middleware runs once around the handlers", func(t *testing.T) {
+		Reset()
+		defer Reset()
+		calls := 0
+		Use(WithMaxConcurrent(1, 0), func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				next.ServeHTTP(w, r)
+			})
+		})
+		Configure(WithCompression(true))
+
+		rec := post(Fallback(newFlag(false), newFlag(false), legacy))
+		if rec.Code != 200 || rec.Body.String() != "legacy: Ann" {
+			t.Errorf("unexpected response: %d %q", rec.Code, rec.Body.String())
+		}
+		if calls != 1 {
+			t.Errorf("expected the middleware to run once, ran %d times", calls)
+		}
+		if vary := rec.Header().Values("Vary"); len(vary) != 1 {
+			t.Errorf("expected a single Vary header, got %q", vary)
+		}
+	})
+
+	t.Run("cached as a whole", func(t *testing.T) {
+		Reset()
+		defer Reset()
+		Use(WithCache(nil, time.Minute))
+
+		calls := 0
+		handler := Fallback(func() error { return ErrNotHandled }, func() string {
+			calls++
+			return "legacy"
+		})
+		for i := range 2 {
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest("GET", "/", nil))
+			if rec.Code != 200 || rec.Body.String() != "legacy" {
+				t.Errorf("request %d: unexpected response: %d %q", i, rec.Code, rec.Body.String())
+			}
+		}
+		if calls != 1 {
+			t.Errorf("expected the second request to be served from the cache, handler ran %d times", calls)
+		}
+	})
+
+	t.Run("all handlers pass", func(t *testing.T) {
+		Reset()
+		rec := post(Fallback(newFlag(false), newFlag(false)))
+		if rec.Code != 404 {
+			t.Errorf("expected status 404, got %d", rec.Code)
+		}
+	})
+
+	t.Run("sentinel outside Fallback is a 404", func(t *testing.T) {
+		Reset()
+		rec := post(H(newFlag(false)))
+		if rec.Code != 404 {
+			t.Errorf("expected status 404, got %d", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package m
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Content-Disposition types for File
+const (
+	// DispositionAttachment makes browsers save the file
+	DispositionAttachment = "attachment"
+	// DispositionInline makes browsers display the file, e.g. to preview an image or PDF
+	DispositionInline = "inline"
+)
+
+// File is a response that serves a file from disk (Path) or from Content, with
+// support for range and conditional requests as in http.ServeContent.
+// The Content-Type is derived from the extension of Name, or of Path when Name is
+// empty, falling back to sniffing the content. Path is opened as is;
+// do not build it from unchecked request input. A missing file is a 404
+//
+//	return m.File{Path: "reports/q3.pdf", Disposition: m.DispositionInline}
+type File struct {
+	Path string
+
+	// Content is served instead of Path when set, and closed afterwards if it is an io.Closer
+	Content io.ReadSeeker
+	// Name is the file name sent to the client; it defaults to the base name of Path
+	Name string
+	// ModTime enables If-Modified-Since handling for Content; files from Path use their own
+	ModTime time.Time
+
+	// Disposition is DispositionAttachment (the default) or DispositionInline
+	Disposition string
+}
+
+func (f File) Respond(w http.ResponseWriter) {
+	content, name, modTime := f.Content, f.Name, f.ModTime
+	if content != nil {
+		if c, ok := content.(io.Closer); ok {
+			defer c.Close()
+		}
+	} else {
+		file, info, err := openFile(f.Path)
+		if err != nil {
+			if e := handleError(w, err); e != nil {
+				logger().Printf("failed to write error response: %v", e)
+			}
+			return
+		}
+		defer file.Close()
+
+		content, modTime = file, info.ModTime()
+		if name == "" {
+			name = filepath.Base(f.Path)
+		}
+	}
+
+	disposition := f.Disposition
+	switch disposition {
+	case DispositionAttachment, DispositionInline:
+	case "":
+		disposition = DispositionAttachment
+	default:
+		logger().Printf("Warning: unknown file disposition %q, using %q", disposition, DispositionAttachment)
+		disposition = DispositionAttachment
+	}
+	if name != "" {
+		disposition = mime.FormatMediaType(disposition, map[string]string{"filename": name})
+	}
+	w.Header().Set("Content-Disposition", disposition)
+
+	r := requestOf(w)
+	if r == nil {
+		r = &http.Request{Method: http.MethodGet, Header: http.Header{}}
+	}
+	http.ServeContent(w, r, name, modTime, content)
+}
+
+// openFile opens a regular file for serving, reporting missing files and directories as 404
+func openFile(path string) (*os.File, fs.FileInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, NotFound("file not found")
+		}
+		return nil, nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, nil, NotFound("file not found")
+	}
+	return file, info, nil
+}
@@ -0,0 +1,130 @@
+package m
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	reportPath := filepath.Join(dir, "report.pdf")
+	if err := os.WriteFile(reportPath, []byte("%PDF-1.4 report"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(f File, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/download", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		H(func() File { return f })(rec, req)
+		return rec
+	}
+
+	t.Run("attachment by default", func(t *testing.T) {
+		Reset()
+		rec := serve(File{Path: reportPath}, nil)
+		if rec.Code != 200 || rec.Body.String() != "%PDF-1.4 report" {
+			t.Fatalf("unexpected response %d %q", rec.Code, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/pdf" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		if cd := rec.Header().Get("Content-Disposition"); cd != "attachment; filename=report.pdf" {
+			t.Errorf("unexpected content disposition %q", cd)
+		}
+		if rec.Header().Get("Last-Modified") == "" {
+			t.Error("expected a Last-Modified header")
+		}
+	})
+
+	t.Run("inline disposition", func(t *testing.T) {
+		Reset()
+		rec := serve(File{Path: reportPath, Disposition: DispositionInline}, nil)
+		if cd := rec.Header().Get("Content-Disposition"); cd != "inline; filename=report.pdf" {
+			t.Errorf("unexpected content disposition %q", cd)
+		}
+	})
+
+	t.Run("explicit attachment disposition", func(t *testing.T) {
+		Reset()
+		rec := serve(File{Path: reportPath, Disposition: DispositionAttachment}, nil)
+		if cd := rec.Header().Get("Content-Disposition"); cd != "attachment; filename=report.pdf" {
+			t.Errorf("unexpected content disposition %q", cd)
+		}
+	})
+
+	t.Run("unknown disposition falls back to attachment", func(t *testing.T) {
+		Reset()
+		var buf bytes.Buffer
+		Configure(WithLogger(log.New(&buf, "", 0)))
+		defer Reset()
+
+		rec := serve(File{Path: reportPath, Disposition: "preview"}, nil)
+		if cd := rec.Header().Get("Content-Disposition"); cd != "attachment; filename=report.pdf" {
+			t.Errorf("unexpected content disposition %q", cd)
+		}
+		if !strings.Contains(buf.String(), `unknown file disposition "preview"`) {
+			t.Errorf("expected a warning, got %q", buf.String())
+		}
+	})
+
+	t.Run("range request", func(t *testing.T) {
+		Reset()
+		rec := serve(File{Path: reportPath}, map[string]string{"Range": "bytes=0-3"})
+		if rec.Code != 206 || rec.Body.String() != "%PDF" {
+			t.Errorf("expected a partial response, got %d %q", rec.Code, rec.Body.String())
+		}
+		if cr := rec.Header().Get("Content-Range"); cr != "bytes 0-3/15" {
+			t.Errorf("unexpected content range %q", cr)
+		}
+	})
+
+	t.Run("generated content", func(t *testing.T) {
+		Reset()
+		modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		f := File{Content: strings.NewReader(`[{"id":1}]`), Name: "users.json", ModTime: modTime}
+
+		rec := serve(f, nil)
+		if rec.Code != 200 || rec.Body.String() != `[{"id":1}]` {
+			t.Fatalf("unexpected response %d %q", rec.Code, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		if cd := rec.Header().Get("Content-Disposition"); cd != "attachment; filename=users.json" {
+			t.Errorf("unexpected content disposition %q", cd)
+		}
+
+		f.Content = strings.NewReader(`[{"id":1}]`)
+		rec = serve(f, map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)})
+		if rec.Code != 304 {
+			t.Errorf("expected 304 for an unmodified file, got %d", rec.Code)
+		}
+	})
+
+	t.Run("non-ASCII file name", func(t *testing.T) {
+		Reset()
+		rec := serve(File{Content: strings.NewReader("x"), Name: "résumé.txt"}, nil)
+		if cd := rec.Header().Get("Content-Disposition"); cd != "attachment; filename*=utf-8''r%C3%A9sum%C3%A9.txt" {
+			t.Errorf("unexpected content disposition %q", cd)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		Reset()
+		for _, path := range []string{filepath.Join(dir, "nope.pdf"), dir} {
+			if rec := serve(File{Path: path}, nil); rec.Code != 404 {
+				t.Errorf("%s: expected status 404, got %d", path, rec.Code)
+			}
+		}
+	})
+}
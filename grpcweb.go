@@ -0,0 +1,78 @@
+package m
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gRPC status codes, see https://grpc.github.io/grpc/core/md_doc_statuscodes.html
+const (
+	grpcCanceled           = 1
+	grpcUnknown            = 2
+	grpcInvalidArgument    = 3
+	grpcDeadlineExceeded   = 4
+	grpcNotFound           = 5
+	grpcPermissionDenied   = 7
+	grpcResourceExhausted  = 8
+	grpcFailedPrecondition = 9
+	grpcAborted            = 10
+	grpcUnimplemented      = 12
+	grpcInternal           = 13
+	grpcUnavailable        = 14
+	grpcUnauthenticated    = 16
+)
+
+// grpcStatus maps an HTTP status code to the closest gRPC status code
+func grpcStatus(code int) int {
+	switch code {
+	case http.StatusBadRequest:
+		return grpcInvalidArgument
+	case http.StatusUnauthorized:
+		return grpcUnauthenticated
+	case http.StatusForbidden:
+		return grpcPermissionDenied
+	case http.StatusNotFound:
+		return grpcNotFound
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return grpcDeadlineExceeded
+	case http.StatusConflict:
+		return grpcAborted
+	case http.StatusPreconditionFailed:
+		return grpcFailedPrecondition
+	case http.StatusTooManyRequests:
+		return grpcResourceExhausted
+	case 499: // Client Closed Request
+		return grpcCanceled
+	case http.StatusNotImplemented:
+		return grpcUnimplemented
+	case http.StatusServiceUnavailable:
+		return grpcUnavailable
+	}
+	if code >= 500 {
+		return grpcInternal
+	}
+	return grpcUnknown
+}
+
+// writeGRPCWebStatus sets the grpc-status and grpc-message headers for httpErr
+func writeGRPCWebStatus(w http.ResponseWriter, httpErr *HTTPError) {
+	w.Header().Set("grpc-status", strconv.Itoa(grpcStatus(httpErr.Code)))
+	w.Header().Set("grpc-message", encodeGRPCMessage(httpErr.Error()))
+}
+
+// encodeGRPCMessage percent-encodes msg as the gRPC spec requires for grpc-message:
+// bytes outside printable ASCII, and '%' itself, are escaped
+func encodeGRPCMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
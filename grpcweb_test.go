@@ -0,0 +1,59 @@
+package m
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGRPCWebErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        *HTTPError
+		grpcStatus string
+		grpcMsg    string
+	}{
+		{"bad request", &HTTPError{Code: 400, Err: "bad_request", Message: "name is required"}, "3", "name is required"},
+		{"not found", &HTTPError{Code: 404, Err: "not_found"}, "5", "not_found"},
+		{"too many requests", &HTTPError{Code: 429, Err: "too_many_requests"}, "8", "too_many_requests"},
+		{"unavailable", &HTTPError{Code: 503, Err: "service_unavailable"}, "14", "service_unavailable"},
+		{"other server error", &HTTPError{Code: 502, Err: "bad_gateway"}, "13", "bad_gateway"},
+		{"unmapped client error", &HTTPError{Code: 418, Err: "im_a_teapot"}, "2", "im_a_teapot"},
+		{"encoded message", &HTTPError{Code: 400, Err: "bad_request", Message: "100% café"}, "3", "100%25 caf%C3%A9"},
+	}
+
+	Reset()
+	Configure(WithGRPCWebErrors(true))
+	defer Reset()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			H(func() error { return tt.err })(rec, httptest.NewRequest("POST", "/", nil))
+
+			if rec.Code != tt.err.Code {
+				t.Errorf("expected HTTP status %d, got %d", tt.err.Code, rec.Code)
+			}
+			if got := rec.Header().Get("grpc-status"); got != tt.grpcStatus {
+				t.Errorf("expected grpc-status %s, got %s", tt.grpcStatus, got)
+			}
+			if got := rec.Header().Get("grpc-message"); got != tt.grpcMsg {
+				t.Errorf("expected grpc-message %q, got %q", tt.grpcMsg, got)
+			}
+
+			var httpErr HTTPError
+			parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+			if httpErr.Err != tt.err.Err {
+				t.Errorf("expected JSON body to be kept, got %s", rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		H(func() error { return &HTTPError{Code: 404, Err: "not_found"} })(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Header().Get("grpc-status") != "" {
+			t.Errorf("unexpected grpc-status header: %s", rec.Header().Get("grpc-status"))
+		}
+	})
+}
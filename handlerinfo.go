@@ -0,0 +1,127 @@
+package m
+
+import (
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// HandlerSignature describes what a handler wrapped with H takes and returns,
+// for tools such as client or API schema generators
+type HandlerSignature struct {
+	// Name is the name given with Named, or else the fully qualified name
+	// of the handler function
+	Name string
+	// Params describes the handler parameters in order
+	Params []ParamInfo
+	// Returns holds the handler return types in order
+	Returns []reflect.Type
+}
+
+// ParamInfo describes a single handler parameter
+type ParamInfo struct {
+	// Type is the parameter type, e.g. m.Path[int] or *http.Request
+	Type reflect.Type
+	// Extractor reports whether the parameter is populated by an Extractor
+	Extractor bool
+	// Value is the type of the extractor's Value field, e.g. int for m.Path[int];
+	// nil when the parameter has none
+	Value reflect.Type
+	// ReadsBody reports whether the extractor consumes the request body
+	ReadsBody bool
+	// ContentType is the request content type the extractor expects, if any
+	ContentType string
+}
+
+var handlerRegistry struct {
+	sync.Mutex
+	order []uintptr
+	infos map[uintptr]HandlerSignature
+}
+
+// HandlerInfo returns the signature recorded for fn, the function passed to H.
+// Signatures are only recorded while Config.RecordHandlerInfo is on. Closures
+// created from the same function literal share one entry
+func HandlerInfo(fn any) (HandlerSignature, bool) {
+	if named, ok := fn.(namedHandler); ok {
+		fn = named.fn
+	}
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return HandlerSignature{}, false
+	}
+
+	handlerRegistry.Lock()
+	defer handlerRegistry.Unlock()
+	info, ok := handlerRegistry.infos[v.Pointer()]
+	return info, ok
+}
+
+// Handlers returns the signatures of all recorded handlers in the order they were wrapped
+func Handlers() []HandlerSignature {
+	handlerRegistry.Lock()
+	defer handlerRegistry.Unlock()
+	infos := make([]HandlerSignature, len(handlerRegistry.order))
+	for i, ptr := range handlerRegistry.order {
+		infos[i] = handlerRegistry.infos[ptr]
+	}
+	return infos
+}
+
+// funcName returns the fully qualified name of a function, e.g. "main.createUser"
+func funcName(fnVal reflect.Value) string {
+	if f := runtime.FuncForPC(fnVal.Pointer()); f != nil {
+		return f.Name()
+	}
+	return ""
+}
+
+// recordHandler stores the signature of fn, wrapped under name, in the registry
+func recordHandler(fnVal reflect.Value, name string) {
+	fnType := fnVal.Type()
+	info := HandlerSignature{
+		Params:  make([]ParamInfo, fnType.NumIn()),
+		Returns: make([]reflect.Type, fnType.NumOut()),
+		Name:    name,
+	}
+
+	for i := range info.Params {
+		info.Params[i] = paramInfo(fnType.In(i))
+	}
+	for i := range info.Returns {
+		info.Returns[i] = fnType.Out(i)
+	}
+
+	handlerRegistry.Lock()
+	defer handlerRegistry.Unlock()
+	ptr := fnVal.Pointer()
+	if handlerRegistry.infos == nil {
+		handlerRegistry.infos = make(map[uintptr]HandlerSignature)
+	}
+	if _, ok := handlerRegistry.infos[ptr]; !ok {
+		handlerRegistry.order = append(handlerRegistry.order, ptr)
+	}
+	handlerRegistry.infos[ptr] = info
+}
+
+func paramInfo(paramType reflect.Type) ParamInfo {
+	p := ParamInfo{Type: paramType}
+	if !reflect.PointerTo(paramType).Implements(extractorType) {
+		return p
+	}
+
+	p.Extractor = true
+	if paramType.Kind() == reflect.Struct {
+		if f, ok := paramType.FieldByName("Value"); ok {
+			p.Value = f.Type
+		}
+	}
+	extractor := reflect.New(paramType).Interface()
+	if be, ok := extractor.(BodyExtractor); ok {
+		p.ReadsBody = be.ReadsBody()
+	}
+	if ct, ok := extractor.(ContentTyper); ok {
+		p.ContentType = ct.ExpectedContentType()
+	}
+	return p
+}
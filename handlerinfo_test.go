@@ -0,0 +1,87 @@
+package m
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func updateUser(id Path[int], q Query[struct{ Notify bool }], body JSON[User], r *http.Request) (User, error) {
+	return body.Value, nil
+}
+
+func TestHandlerInfo(t *testing.T) {
+	t.Run("records a multi-param handler", func(t *testing.T) {
+		Reset()
+		Configure(WithHandlerInfo(true))
+		defer Reset()
+
+		H(updateUser)
+
+		info, ok := HandlerInfo(updateUser)
+		if !ok {
+			t.Fatal("expected the handler to be recorded")
+		}
+		if !strings.HasSuffix(info.Name, ".updateUser") {
+			t.Errorf("unexpected name %q", info.Name)
+		}
+
+		expected := []ParamInfo{
+			{Type: reflect.TypeOf(Path[int]{}), Extractor: true, Value: reflect.TypeOf(0)},
+			{Type: reflect.TypeOf(Query[struct{ Notify bool }]{}), Extractor: true, Value: reflect.TypeOf(struct{ Notify bool }{})},
+			{Type: reflect.TypeOf(JSON[User]{}), Extractor: true, Value: reflect.TypeOf(User{}), ReadsBody: true, ContentType: "application/json"},
+			{Type: httpRequestType},
+		}
+		if !reflect.DeepEqual(info.Params, expected) {
+			t.Errorf("unexpected params:\n got %+v\nwant %+v", info.Params, expected)
+		}
+
+		returns := []reflect.Type{reflect.TypeOf(User{}), errorType}
+		if !reflect.DeepEqual(info.Returns, returns) {
+			t.Errorf("unexpected returns %v", info.Returns)
+		}
+
+		found := false
+		for _, h := range Handlers() {
+			if h.Name == info.Name {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("expected the handler to be listed by Handlers")
+		}
+	})
+
+	t.Run("uses the name given with Named", func(t *testing.T) {
+		Reset()
+		Configure(WithHandlerInfo(true))
+		defer Reset()
+
+		handler := func(id Path[int]) string { return "ok" }
+		H(Named("getThing", handler))
+
+		info, ok := HandlerInfo(handler)
+		if !ok {
+			t.Fatal("expected the handler to be recorded")
+		}
+		if info.Name != "getThing" {
+			t.Errorf("expected name getThing, got %q", info.Name)
+		}
+	})
+
+	t.Run("not recorded by default", func(t *testing.T) {
+		Reset()
+		handler := func(w http.ResponseWriter) {}
+		H(handler)
+		if _, ok := HandlerInfo(handler); ok {
+			t.Error("expected no signature without RecordHandlerInfo")
+		}
+	})
+
+	t.Run("not a function", func(t *testing.T) {
+		if _, ok := HandlerInfo("nope"); ok {
+			t.Error("expected no signature for a non-function")
+		}
+	})
+}
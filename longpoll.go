@@ -0,0 +1,30 @@
+package m
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// LongPoll waits up to timeout for a value from ch and returns it as a 200 result.
+// If none arrives in time, ch is closed or ctx is done, it returns a 204 No Content
+// result instead, telling the client to poll again. Pass the request context so
+// the wait ends as soon as the client goes away:
+//
+//	mux.HandleFunc("GET /events", m.H(func(r *http.Request) m.Result[Event] {
+//		return m.LongPoll(r.Context(), broker.Subscribe(), 30*time.Second)
+//	}))
+func LongPoll[T any](ctx context.Context, ch <-chan T, timeout time.Duration) Result[T] {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case v, ok := <-ch:
+		if ok {
+			return OK(v)
+		}
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return Result[T]{Code: http.StatusNoContent}
+}
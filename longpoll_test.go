@@ -0,0 +1,75 @@
+package m
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLongPoll(t *testing.T) {
+	poll := func(ctx context.Context, ch <-chan User, timeout time.Duration) *httptest.ResponseRecorder {
+		handler := H(func(r *http.Request) Result[User] {
+			return LongPoll(r.Context(), ch, timeout)
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/events", nil).WithContext(ctx))
+		return rec
+	}
+
+	t.Run("event arrives", func(t *testing.T) {
+		Reset()
+		ch := make(chan User)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			ch <- User{Name: "Ann"}
+		}()
+
+		rec := poll(context.Background(), ch, time.Second)
+		if rec.Code != 200 {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		var user User
+		parseJSONResponse(t, rec.Body.Bytes(), &user)
+		if user.Name != "Ann" {
+			t.Errorf("unexpected event: %+v", user)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		Reset()
+		start := time.Now()
+		rec := poll(context.Background(), make(chan User), 20*time.Millisecond)
+		if rec.Code != 204 || rec.Body.Len() != 0 {
+			t.Errorf("expected empty 204, got %d %q", rec.Code, rec.Body.String())
+		}
+		if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+			t.Errorf("returned before the timeout, after %v", elapsed)
+		}
+	})
+
+	t.Run("client goes away", func(t *testing.T) {
+		Reset()
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		start := time.Now()
+		rec := poll(ctx, make(chan User), time.Minute)
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("did not stop when the request was canceled, took %v", elapsed)
+		}
+		if rec.Code != 204 {
+			t.Errorf("expected status 204, got %d", rec.Code)
+		}
+	})
+
+	t.Run("closed channel", func(t *testing.T) {
+		Reset()
+		ch := make(chan User)
+		close(ch)
+		if rec := poll(context.Background(), ch, time.Minute); rec.Code != 204 {
+			t.Errorf("expected status 204, got %d", rec.Code)
+		}
+	})
+}
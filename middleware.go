@@ -0,0 +1,100 @@
+package m
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Middleware wraps an http.Handler, the same shape as net/http middleware
+type Middleware func(http.Handler) http.Handler
+
+// Use registers middleware wrapping every handler built by H and HWith afterwards.
+// Middleware run in the order given, after any registered earlier
+func Use(mw ...Middleware) {
+	Configure(func(c *Config) {
+		c.Middleware = append(slices.Clip(c.Middleware), mw...)
+	})
+}
+
+// HWith is like H, additionally wrapping the handler in mw, inside the middleware
+// registered with Use. The first middleware is the outermost. Middleware receive
+// the *ResponseWriter the handler writes to, so they can read its Status once
+// the handler returns, and the request already carries its ID
+func HWith(fn any, mw ...Middleware) http.HandlerFunc {
+	return withMiddleware(newHandler(fn), mw...)
+}
+
+// withMiddleware wraps h in the middleware registered with Use, then in mw,
+// handing them a *ResponseWriter and a request carrying its ID
+func withMiddleware(h http.HandlerFunc, mw ...Middleware) http.HandlerFunc {
+	chain := append(slices.Clip(global.get().Middleware), mw...)
+	if len(chain) == 0 {
+		return h
+	}
+
+	var next http.Handler = h
+	for i := len(chain) - 1; i >= 0; i-- {
+		next = chain[i](next)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := &ResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, withRequestID(rw, r))
+	}
+}
+
+// WithMaxConcurrent returns a middleware allowing at most n requests to be handled at once.
+// With a zero wait, requests over the limit are rejected immediately with 503.
+// Otherwise they queue for up to wait for a free slot before being rejected
+func WithMaxConcurrent(n int, wait time.Duration) func(http.Handler) http.Handler {
+	if n <= 0 {
+		log.Panicf("WithMaxConcurrent: limit must be positive, got %d", n)
+	}
+	sem := make(chan struct{}, n)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !acquire(r, sem, wait) {
+				if e := handleError(w, &HTTPError{
+					Code:    http.StatusServiceUnavailable,
+					Err:     "service_unavailable",
+					Message: "too many concurrent requests",
+				}); e != nil {
+					logger().Printf("failed to write error response: %v", e)
+				}
+				return
+			}
+			defer func() { <-sem }()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// acquire takes a slot from sem, waiting up to wait for one to free up.
+// It gives up early if the request is canceled
+func acquire(r *http.Request, sem chan struct{}, wait time.Duration) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+	}
+
+	if wait <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
@@ -0,0 +1,228 @@
+package m
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithMaxConcurrent(t *testing.T) {
+	// blockingHandler holds each request until release is closed
+	blockingHandler := func() (http.Handler, chan struct{}, chan struct{}) {
+		started := make(chan struct{}, 10)
+		release := make(chan struct{})
+		handler := H(func() string {
+			started <- struct{}{}
+			<-release
+			return "done"
+		})
+		return handler, started, release
+	}
+
+	t.Run("rejects request over the limit", func(t *testing.T) {
+		Reset()
+		handler, started, release := blockingHandler()
+		limited := WithMaxConcurrent(2, 0)(handler)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				limited.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			}()
+		}
+		<-started
+		<-started
+
+		rec := httptest.NewRecorder()
+		limited.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected status 503, got %d", rec.Code)
+		}
+
+		close(release)
+		wg.Wait()
+
+		rec = httptest.NewRecorder()
+		limited.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != 200 {
+			t.Errorf("expected status 200 once slots are free, got %d", rec.Code)
+		}
+	})
+
+	t.Run("queues request until a slot frees", func(t *testing.T) {
+		Reset()
+		handler, started, release := blockingHandler()
+		limited := WithMaxConcurrent(1, time.Second)(handler)
+
+		go limited.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		<-started
+
+		done := make(chan int)
+		go func() {
+			rec := httptest.NewRecorder()
+			limited.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+			done <- rec.Code
+		}()
+
+		select {
+		case <-started:
+			t.Fatal("queued request should not start while the slot is taken")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		close(release)
+		if code := <-done; code != 200 {
+			t.Errorf("expected queued request to succeed, got %d", code)
+		}
+	})
+
+	t.Run("queued request times out", func(t *testing.T) {
+		Reset()
+		handler, started, release := blockingHandler()
+		defer close(release)
+		limited := WithMaxConcurrent(1, 10*time.Millisecond)(handler)
+
+		go limited.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		<-started
+
+		rec := httptest.NewRecorder()
+		limited.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected status 503, got %d", rec.Code)
+		}
+	})
+}
+
+func TestHWith(t *testing.T) {
+	// trace records the middleware's name on the way in and out
+	trace := func(name string, calls *[]string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				*calls = append(*calls, name+" in")
+				next.ServeHTTP(w, r)
+				*calls = append(*calls, name+" out")
+			})
+		}
+	}
+
+	t.Run("applies middleware in order", func(t *testing.T) {
+		Reset()
+		var calls []string
+		handler := HWith(func() string {
+			calls = append(calls, "handler")
+			return "ok"
+		}, trace("outer", &calls), trace("inner", &calls))
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+
+		expected := []string{"outer in", "inner in", "handler", "inner out", "outer out"}
+		if !slices.Equal(calls, expected) {
+			t.Errorf("expected %v, got %v", expected, calls)
+		}
+		if rec.Body.String() != "ok" {
+			t.Errorf("unexpected body %q", rec.Body.String())
+		}
+	})
+
+	t.Run("middleware see the handler name", func(t *testing.T) {
+		Reset()
+		var name string
+		logName := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r)
+				name = w.(*ResponseWriter).HandlerName()
+			})
+		}
+
+		HWith(Named("health", func() string { return "ok" }), logName)(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if name != "health" {
+			t.Errorf("expected handler name health, got %q", name)
+		}
+
+		HWith(func() string { return "ok" }, logName)(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if !strings.HasPrefix(name, "github.com/cymoo/mint.TestHWith.func") {
+			t.Errorf("expected the function name, got %q", name)
+		}
+	})
+
+	t.Run("middleware see the final status", func(t *testing.T) {
+		Reset()
+		var status int
+		var requestID string
+		logStatus := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestID = RequestID(r.Context())
+				next.ServeHTTP(w, r)
+				status = w.(*ResponseWriter).Status()
+			})
+		}
+		handler := HWith(func() (string, error) {
+			return "", NotFound("no such user")
+		}, logStatus)
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+
+		if status != 404 || rec.Code != 404 {
+			t.Errorf("expected middleware to see 404, got %d (response %d)", status, rec.Code)
+		}
+		if requestID == "" || rec.Header().Get(RequestIDHeader) != requestID {
+			t.Errorf("expected middleware to see request ID %q, got %q", rec.Header().Get(RequestIDHeader), requestID)
+		}
+	})
+
+	t.Run("middleware can short-circuit", func(t *testing.T) {
+		Reset()
+		requireAuth := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") == "" {
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+					return
+				}
+				next.ServeHTTP(w, r)
+			})
+		}
+		called := false
+		handler := HWith(func() string { called = true; return "secret" }, requireAuth)
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != 401 || called {
+			t.Errorf("expected 401 without calling the handler, got %d (called %v)", rec.Code, called)
+		}
+	})
+
+	t.Run("Use registers global middleware", func(t *testing.T) {
+		Reset()
+		defer Reset()
+		var calls []string
+		Use(trace("global", &calls))
+
+		handler := HWith(func() string { return "ok" }, trace("route", &calls))
+		handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		expected := []string{"global in", "route in", "route out", "global out"}
+		if !slices.Equal(calls, expected) {
+			t.Errorf("expected %v, got %v", expected, calls)
+		}
+
+		calls = nil
+		H(func() string { return "ok" })(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if !slices.Equal(calls, []string{"global in", "global out"}) {
+			t.Errorf("expected H to apply global middleware, got %v", calls)
+		}
+
+		Reset()
+		calls = nil
+		H(func() string { return "ok" })(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if len(calls) != 0 {
+			t.Errorf("expected Reset to clear middleware, got %v", calls)
+		}
+	})
+}
@@ -1,19 +1,27 @@
 package m
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"reflect"
+	"runtime/debug"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/schema"
 )
 
@@ -22,6 +30,15 @@ type Config struct {
 	// SchemaDecoder for parsing form and query parameters
 	SchemaDecoder *schema.Decoder
 
+	// QueryDecoder and FormDecoder, when set, replace SchemaDecoder for the Query
+	// and Form extractors respectively, e.g. to reject unknown query keys only
+	QueryDecoder *schema.Decoder
+	FormDecoder  *schema.Decoder
+
+	// TimeLayouts are tried in order when decoding time.Time query and form fields.
+	// A field can override them with a `time_format` struct tag
+	TimeLayouts []string
+
 	// JSONMarshalFunc for encoding JSON responses
 	JSONMarshalFunc func(v any) ([]byte, error)
 
@@ -31,6 +48,15 @@ type Config struct {
 	// JSONUnmarshalFunc for decoding JSON requests
 	JSONUnmarshalFunc func(data []byte, v any) error
 
+	// XMLUnmarshalFunc for decoding XML requests
+	XMLUnmarshalFunc func(data []byte, v any) error
+
+	// XMLMarshalFunc, when set, enables content negotiation for structs, maps and
+	// slices returned by handlers: they are encoded with it as XML when the request's
+	// Accept header prefers application/xml or text/xml to application/json, and as
+	// JSON otherwise. Nil always sends JSON
+	XMLMarshalFunc func(v any) ([]byte, error)
+
 	// Logger allows user to provide custom logger
 	Logger *log.Logger
 
@@ -38,10 +64,175 @@ type Config struct {
 	EnableValidation bool
 
 	// Validator is the validation instance to use
-	Validator *validator.Validate
+	Validator *Validator
 
 	// ErrorHandler allows custom error handling
 	ErrorHandler func(w http.ResponseWriter, err error)
+
+	// ErrorEncoder, when set, writes the body of JSON error responses in place of
+	// the HTTPError itself, e.g. to wrap it in an API envelope. Unlike ErrorHandler it
+	// receives the error already mapped to an *HTTPError, with its headers set; the
+	// status is sent with the first write, so the encoder may still change headers
+	ErrorEncoder func(w http.ResponseWriter, e *HTTPError) error
+
+	// ErrorTemplate, when set, renders error responses as HTML pages for clients
+	// that prefer text/html to JSON, such as browsers. It is executed with the
+	// *HTTPError the error maps to. Other clients still get the JSON envelope
+	ErrorTemplate *template.Template
+
+	// BufferRequestBody makes H always buffer the request body so that every
+	// body-reading extractor sees the full body. Without it, the body is only
+	// buffered when a handler has more than one such extractor
+	BufferRequestBody bool
+
+	// RecoverPanics makes H recover from panics in handlers, log them with their
+	// stack trace and report them as a *PanicError through the normal error handling
+	// path, which answers 500 unless an ErrorHandler decides otherwise. A panic after
+	// the response has started is only logged, since its status can no longer change
+	RecoverPanics bool
+
+	// PathConverters parse Path[T] values for specific types, taking precedence
+	// over the built-in conversions. Register them with WithPathConverter
+	PathConverters map[reflect.Type]func(string) (any, error)
+
+	// StatusInferrer picks the status code for errors mint has no mapping for.
+	// When nil, the code is inferred from keywords in the error message, e.g. "not found" → 404
+	StatusInferrer func(err error) int
+
+	// AllowedContentTypes restricts the media types accepted for requests with a body.
+	// Entries may use a wildcard subtype such as "text/*". Empty allows everything
+	AllowedContentTypes []string
+
+	// EnforceContentType rejects requests whose body does not have the content type
+	// expected by the handler's extractors (see ContentTyper) with 415
+	EnforceContentType bool
+
+	// APIVersions lists the versions the Version extractor accepts, e.g. "v1", "v2".
+	// Empty accepts any version
+	APIVersions []string
+
+	// AuthSchemes lists the Authorization schemes the AuthHeader extractor accepts,
+	// e.g. "Bearer", "Signature". Empty accepts any scheme
+	AuthSchemes []string
+
+	// ValidateResponses validates struct responses, including Result data, before
+	// they are encoded. Failures are logged as warnings; the response is still sent
+	ValidateResponses bool
+
+	// BodyReadTimeout bounds how long body-reading extractors may spend reading
+	// the request body, guarding against slow clients. Zero means no limit
+	BodyReadTimeout time.Duration
+
+	// RequestIDGenerator creates an ID for requests that arrive without an X-Request-ID header.
+	// H echoes the ID in the response header and stores it in the request context (see RequestID).
+	// Nil disables request IDs
+	RequestIDGenerator func() string
+
+	// MaxBodySize caps how many bytes of a request body H lets extractors read,
+	// after any decompression. Larger bodies are rejected with 413, up front when
+	// their Content-Length already exceeds the limit. Zero means no limit
+	MaxBodySize int64
+
+	// MaxFormFields caps how many distinct fields the Form and MultipartForm extractors
+	// accept, counting query parameters merged into the form. Requests with more are
+	// rejected with 400. Zero means no limit
+	MaxFormFields int
+
+	// MaxMultipartMemory is how many bytes of a multipart form the MultipartForm
+	// extractor keeps in memory; larger files are stored in temporary files
+	MaxMultipartMemory int64
+
+	// MaxQueryLength caps the length of the raw query string accepted by the Query
+	// extractor. Longer queries are rejected with 414. Zero means no limit
+	MaxQueryLength int
+
+	// QuerySliceSeparator, when set, makes the Query extractor split the values of
+	// slice fields on it, so "?tag=a,b" decodes like "?tag=a&tag=b". Every value of a
+	// repeated key is split and the results are concatenated in order, and empty
+	// elements are dropped. Other fields are left as sent
+	QuerySliceSeparator string
+
+	// ResponseTransform, when set, replaces JSON response data with its return value
+	// before encoding, e.g. to wrap every response in an envelope. It receives the
+	// response status and is not applied to errors or non-JSON responses
+	ResponseTransform func(status int, data any) any
+
+	// ProjectResponse, when set, replaces JSON response data with its return value
+	// before encoding, given the request being answered, e.g. to strip fields the
+	// authenticated user may not see. It runs before ResponseTransform and, like it,
+	// is not applied to errors or non-JSON responses
+	ProjectResponse func(r *http.Request, data any) any
+
+	// GRPCWebErrors adds gRPC-Web grpc-status and grpc-message headers to error
+	// responses written by the default error handling, alongside the JSON body
+	GRPCWebErrors bool
+
+	// BeforeHandler runs after all parameters are extracted and right before the handler.
+	// It receives the handler's arguments in order, e.g. a JSON[T] value; returning an
+	// error skips the handler and responds with that error instead
+	BeforeHandler func(r *http.Request, args []any) error
+
+	// ExtractErrorMapper overrides how extraction errors become HTTP errors, e.g. to
+	// answer an empty body with 422. Returning nil falls back to the built-in mapping
+	ExtractErrorMapper func(err *ExtractError) *HTTPError
+
+	// PolymorphicTypes maps each interface type to the concrete types Polymorphic
+	// values of it decode into, keyed by their "@type" name. See WithPolymorphicType
+	PolymorphicTypes map[reflect.Type]map[string]reflect.Type
+
+	// DecompressRequests makes H decode request bodies sent with a gzip or deflate
+	// Content-Encoding before extraction. Malformed data is rejected with 400 and
+	// other encodings with 415
+	DecompressRequests bool
+
+	// CompressResponses makes H gzip response bodies for clients that accept it.
+	// Bodies smaller than CompressionMinSize, partial responses, responses that
+	// already have a Content-Encoding, and already compressed types such as images,
+	// archives and PDFs are sent as they are
+	CompressResponses bool
+
+	// CompressionMinSize is the smallest body, in bytes, CompressResponses compresses
+	CompressionMinSize int
+
+	// ValidationGroups holds a validator per group registered with WithValidationGroup,
+	// each reading its own `validate_<group>` tags. ValidateAs selects the group
+	ValidationGroups map[string]*Validator
+
+	// validationRules records the rules added with WithValidationRule,
+	// so validators rebuilt or registered later get them too
+	validationRules []validationRule
+
+	// customValidator is set when Validator came from WithValidator,
+	// so WithValidationRule cannot rebuild it
+	customValidator bool
+
+	// JSONStreamThreshold, when positive, switches how JSON responses are written
+	// based on their expected size: slices and arrays with more elements than this
+	// are streamed element by element like JSONStream, bounding memory, and other
+	// JSON data is encoded up front and sent with a Content-Length header.
+	// Error responses are unaffected. Zero keeps writing every response with a single encode
+	JSONStreamThreshold int
+
+	// NilPointerAsNull makes a nil pointer response, returned directly or as
+	// Result or Status data, encode as a JSON null. By default it sends an empty body
+	NilPointerAsNull bool
+
+	// LargeIntsAsStrings makes JSON responses send integers outside the range
+	// JavaScript numbers hold exactly (±(2^53-1)) as strings, e.g. "9007199254740993",
+	// so browser clients do not silently lose precision. It rewrites the encoded
+	// output, so a whole float64 that large is quoted too
+	LargeIntsAsStrings bool
+
+	// VoidStatus is the status sent when a handler without return values writes
+	// nothing itself, e.g. 204 for actions with nothing to return. Zero keeps 200
+	VoidStatus int
+
+	// RecordHandlerInfo makes H record the signature of every handler it wraps,
+	// for inspection with HandlerInfo and Handlers
+	RecordHandlerInfo bool
+
+	// Middleware wraps every handler built by H and HWith, outermost first. See Use
+	Middleware []Middleware
 }
 
 // Option is a functional option for configuring the framework
@@ -54,6 +245,27 @@ func WithSchemaDecoder(decoder *schema.Decoder) Option {
 	}
 }
 
+// WithQueryDecoder sets the schema decoder used by the Query extractor
+func WithQueryDecoder(decoder *schema.Decoder) Option {
+	return func(c *Config) {
+		c.QueryDecoder = decoder
+	}
+}
+
+// WithFormDecoder sets the schema decoder used by the Form extractor
+func WithFormDecoder(decoder *schema.Decoder) Option {
+	return func(c *Config) {
+		c.FormDecoder = decoder
+	}
+}
+
+// WithTimeLayouts sets the layouts used to parse time.Time query and form fields
+func WithTimeLayouts(layouts ...string) Option {
+	return func(c *Config) {
+		c.TimeLayouts = layouts
+	}
+}
+
 // WithJSONMarshal sets a custom JSON marshal function
 func WithJSONMarshal(fn func(v any) ([]byte, error)) Option {
 	return func(c *Config) {
@@ -75,6 +287,21 @@ func WithJSONUnmarshal(fn func(data []byte, v any) error) Option {
 	}
 }
 
+// WithXMLUnmarshal sets the function decoding XML request bodies
+func WithXMLUnmarshal(fn func(data []byte, v any) error) Option {
+	return func(c *Config) {
+		c.XMLUnmarshalFunc = fn
+	}
+}
+
+// WithXMLMarshal enables XML responses for clients that ask for them, encoded with fn,
+// e.g. xml.Marshal
+func WithXMLMarshal(fn func(v any) ([]byte, error)) Option {
+	return func(c *Config) {
+		c.XMLMarshalFunc = fn
+	}
+}
+
 // WithLogger sets a custom logger
 func WithLogger(logger *log.Logger) Option {
 	return func(c *Config) {
@@ -89,59 +316,306 @@ func WithValidation(enabled bool) Option {
 	}
 }
 
-// WithValidator sets a custom validator
-func WithValidator(v *validator.Validate) Option {
+// WithErrorHandler sets a custom error handler
+func WithErrorHandler(handler func(w http.ResponseWriter, err error)) Option {
 	return func(c *Config) {
-		c.Validator = v
+		c.ErrorHandler = handler
 	}
 }
 
-// WithErrorHandler sets a custom error handler
-func WithErrorHandler(handler func(w http.ResponseWriter, err error)) Option {
+// WithErrorEncoder sets the function writing the body of JSON error responses
+func WithErrorEncoder(encoder func(w http.ResponseWriter, e *HTTPError) error) Option {
 	return func(c *Config) {
-		c.ErrorHandler = handler
+		c.ErrorEncoder = encoder
 	}
 }
 
-// defaultConfig returns a new config with sensible defaults
-func defaultConfig() *Config {
-	return &Config{
-		SchemaDecoder:     newDefaultSchemaDecoder(),
-		EnableValidation:  true,
-		Validator:         newDefaultValidator(),
-		Logger:            log.Default(),
-		JSONMarshalFunc:   json.Marshal,
-		JSONUnmarshalFunc: json.Unmarshal,
+// WithErrorTemplate sets the template rendering error pages for HTML clients
+func WithErrorTemplate(tmpl *template.Template) Option {
+	return func(c *Config) {
+		c.ErrorTemplate = tmpl
+	}
+}
+
+// WithBodyBuffering enables/disables buffering of the request body for every handler
+func WithBodyBuffering(enabled bool) Option {
+	return func(c *Config) {
+		c.BufferRequestBody = enabled
+	}
+}
+
+// WithRecovery enables/disables panic recovery in handlers
+func WithRecovery(enabled bool) Option {
+	return func(c *Config) {
+		c.RecoverPanics = enabled
+	}
+}
+
+// WithStatusInferrer sets a custom status code inference for unmapped errors
+func WithStatusInferrer(fn func(err error) int) Option {
+	return func(c *Config) {
+		c.StatusInferrer = fn
+	}
+}
+
+// WithAllowedContentTypes sets the media types accepted for requests with a body
+func WithAllowedContentTypes(types ...string) Option {
+	return func(c *Config) {
+		c.AllowedContentTypes = types
+	}
+}
+
+// WithContentTypeEnforcement enables or disables checking the request content type
+// against the type each extractor expects
+func WithContentTypeEnforcement(enabled bool) Option {
+	return func(c *Config) {
+		c.EnforceContentType = enabled
+	}
+}
+
+// WithAPIVersions sets the API versions accepted by the Version extractor
+func WithAPIVersions(versions ...string) Option {
+	return func(c *Config) {
+		c.APIVersions = versions
+	}
+}
+
+// WithAuthSchemes sets the Authorization schemes accepted by the AuthHeader extractor
+func WithAuthSchemes(schemes ...string) Option {
+	return func(c *Config) {
+		c.AuthSchemes = schemes
+	}
+}
+
+// WithResponseValidation enables/disables validation of struct responses
+func WithResponseValidation(enabled bool) Option {
+	return func(c *Config) {
+		c.ValidateResponses = enabled
+	}
+}
+
+// WithRequestIDGenerator sets the function used to generate request IDs
+func WithRequestIDGenerator(fn func() string) Option {
+	return func(c *Config) {
+		c.RequestIDGenerator = fn
+	}
+}
+
+// WithQuerySliceSeparator sets the separator splitting query values of slice fields
+func WithQuerySliceSeparator(sep string) Option {
+	return func(c *Config) {
+		c.QuerySliceSeparator = sep
+	}
+}
+
+// WithMaxQueryLength sets the maximum raw query string length for the Query extractor
+func WithMaxQueryLength(n int) Option {
+	return func(c *Config) {
+		c.MaxQueryLength = n
+	}
+}
+
+// WithRequestDecompression enables/disables decoding of gzip and deflate request bodies
+func WithRequestDecompression(enabled bool) Option {
+	return func(c *Config) {
+		c.DecompressRequests = enabled
+	}
+}
+
+// WithCompression enables/disables gzip compression of response bodies
+func WithCompression(enabled bool) Option {
+	return func(c *Config) {
+		c.CompressResponses = enabled
+	}
+}
+
+// WithCompressionMinSize sets the smallest response body that is compressed, 1 KB by default
+func WithCompressionMinSize(n int) Option {
+	return func(c *Config) {
+		c.CompressionMinSize = n
+	}
+}
+
+// WithJSONStreamThreshold sets the slice length above which JSON responses are streamed
+func WithJSONStreamThreshold(n int) Option {
+	return func(c *Config) {
+		c.JSONStreamThreshold = n
+	}
+}
+
+// WithNilPointerAsNull enables/disables encoding nil pointer responses as JSON null
+func WithNilPointerAsNull(enabled bool) Option {
+	return func(c *Config) {
+		c.NilPointerAsNull = enabled
+	}
+}
+
+// WithLargeIntsAsStrings enables/disables encoding integers beyond 2^53-1 as JSON strings
+func WithLargeIntsAsStrings(enabled bool) Option {
+	return func(c *Config) {
+		c.LargeIntsAsStrings = enabled
+	}
+}
+
+// WithVoidStatus sets the status sent by handlers without return values
+func WithVoidStatus(code int) Option {
+	return func(c *Config) {
+		c.VoidStatus = code
+	}
+}
+
+// WithHandlerInfo enables/disables recording handler signatures in H
+func WithHandlerInfo(enabled bool) Option {
+	return func(c *Config) {
+		c.RecordHandlerInfo = enabled
+	}
+}
+
+// WithResponseTransform sets a function applied to JSON response data before encoding
+func WithResponseTransform(fn func(status int, data any) any) Option {
+	return func(c *Config) {
+		c.ResponseTransform = fn
+	}
+}
+
+// WithMaxBodySize sets the largest request body extractors may read
+func WithMaxBodySize(n int64) Option {
+	return func(c *Config) {
+		c.MaxBodySize = n
+	}
+}
+
+// WithMaxFormFields sets the largest number of fields a form may have
+func WithMaxFormFields(n int) Option {
+	return func(c *Config) {
+		c.MaxFormFields = n
+	}
+}
+
+// WithMaxMultipartMemory sets how many bytes of a multipart form are kept in memory
+func WithMaxMultipartMemory(n int64) Option {
+	return func(c *Config) {
+		c.MaxMultipartMemory = n
+	}
+}
+
+// WithResponseProjection sets a function applied to JSON response data based on the request
+func WithResponseProjection(fn func(r *http.Request, data any) any) Option {
+	return func(c *Config) {
+		c.ProjectResponse = fn
+	}
+}
+
+// WithGRPCWebErrors enables/disables gRPC-Web status headers on error responses
+func WithGRPCWebErrors(enabled bool) Option {
+	return func(c *Config) {
+		c.GRPCWebErrors = enabled
+	}
+}
+
+// WithBeforeHandler sets a hook run between parameter extraction and the handler
+func WithBeforeHandler(fn func(r *http.Request, args []any) error) Option {
+	return func(c *Config) {
+		c.BeforeHandler = fn
+	}
+}
+
+// WithExtractErrorMapper sets a function that overrides the mapping of extraction errors
+func WithExtractErrorMapper(fn func(err *ExtractError) *HTTPError) Option {
+	return func(c *Config) {
+		c.ExtractErrorMapper = fn
+	}
+}
+
+// WithBodyReadTimeout sets the time allowed for reading the request body
+func WithBodyReadTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.BodyReadTimeout = timeout
+	}
+}
+
+// WithPathConverter registers the function used to parse Path[T] values of type T
+func WithPathConverter[T PathValue](fn func(string) (T, error)) Option {
+	return func(c *Config) {
+		converters := make(map[reflect.Type]func(string) (any, error), len(c.PathConverters)+1)
+		for t, convert := range c.PathConverters {
+			converters[t] = convert
+		}
+		converters[reflect.TypeFor[T]()] = func(s string) (any, error) {
+			return fn(s)
+		}
+		c.PathConverters = converters
+	}
+}
+
+// WithPathTimeLayouts sets the layouts, tried in order, used to parse Path[time.Time]
+// values. By default RFC 3339 timestamps and dates like 2024-01-15 are accepted
+func WithPathTimeLayouts(layouts ...string) Option {
+	return WithPathConverter(func(s string) (time.Time, error) {
+		return parseTime(s, layouts)
+	})
+}
+
+// EnumConverter returns a path converter that maps the names in values to their
+// values and rejects any other name. Register it with WithPathConverter:
+//
+//	m.Configure(m.WithPathConverter(m.EnumConverter(map[string]Status{"open": Open, "closed": Closed})))
+func EnumConverter[T PathValue](values map[string]T) func(string) (T, error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	known := strings.Join(names, ", ")
+
+	return func(s string) (T, error) {
+		if v, ok := values[s]; ok {
+			return v, nil
+		}
+		var zero T
+		return zero, fmt.Errorf("unknown value %q, expected one of: %s", s, known)
 	}
 }
 
+// defaultConfig returns a new config with sensible defaults
+func defaultConfig() *Config {
+	cfg := &Config{
+		SchemaDecoder:      newDefaultSchemaDecoder(),
+		TimeLayouts:        []string{time.RFC3339, "2006-01-02"},
+		EnableValidation:   true,
+		Validator:          newDefaultValidator(),
+		Logger:             log.Default(),
+		JSONMarshalFunc:    json.Marshal,
+		JSONUnmarshalFunc:  json.Unmarshal,
+		RequestIDGenerator: newRequestID,
+		MaxMultipartMemory: defaultMaxMultipartMemory,
+		CompressionMinSize: defaultCompressionMinSize,
+	}
+	WithPathConverter(time.ParseDuration)(cfg)
+	WithPathConverter(ParseUUID)(cfg)
+	WithPathTimeLayouts(time.RFC3339, "2006-01-02")(cfg)
+	return cfg
+}
+
+// defaultMaxMultipartMemory matches the limit net/http uses for Request.FormValue
+const defaultMaxMultipartMemory = 32 << 20
+
 // newDefaultSchemaDecoder creates a schema decoder with sensible defaults
 func newDefaultSchemaDecoder() *schema.Decoder {
 	decoder := schema.NewDecoder()
 	decoder.IgnoreUnknownKeys(true)
+	decoder.RegisterConverter(time.Duration(0), convertDuration)
+	decoder.RegisterConverter(UUID(""), convertUUID)
 	return decoder
 }
 
-// newDefaultValidator creates a validator with sensible defaults
-func newDefaultValidator() *validator.Validate {
-	v := validator.New()
-	// Use json tag as field name for validation errors
-	v.RegisterTagNameFunc(func(fld reflect.StructField) string {
-		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
-		if name == "-" {
-			return ""
-		}
-		if name != "" {
-			return name
-		}
-		// Fallback to form tag
-		name = strings.SplitN(fld.Tag.Get("form"), ",", 2)[0]
-		if name == "-" {
-			return ""
-		}
-		return name
-	})
-	return v
+// convertDuration is a schema converter for time.Duration values like "1h30m"
+func convertDuration(value string) reflect.Value {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return reflect.Value{}
+	}
+	return reflect.ValueOf(d)
 }
 
 // configManager manages the global configuration
@@ -220,7 +694,57 @@ func schemaDecoder() *schema.Decoder {
 	return newDefaultSchemaDecoder()
 }
 
+func queryDecoder() *schema.Decoder {
+	if decoder := global.get().QueryDecoder; decoder != nil {
+		return decoder
+	}
+	return schemaDecoder()
+}
+
+func formDecoder() *schema.Decoder {
+	if decoder := global.get().FormDecoder; decoder != nil {
+		return decoder
+	}
+	return schemaDecoder()
+}
+
+func timeLayouts() []string {
+	cfg := global.get()
+	if len(cfg.TimeLayouts) > 0 {
+		return cfg.TimeLayouts
+	}
+	return []string{time.RFC3339}
+}
+
+func statusInferrer() func(err error) int {
+	return global.get().StatusInferrer
+}
+
+func apiVersions() []string {
+	return global.get().APIVersions
+}
+
+func authSchemes() []string {
+	return global.get().AuthSchemes
+}
+
+func pathConverter(t reflect.Type) func(string) (any, error) {
+	return global.get().PathConverters[t]
+}
+
 func jsonEncode(w io.Writer, v any) error {
+	if global.get().LargeIntsAsStrings {
+		var buf bytes.Buffer
+		if err := encodeJSON(&buf, v); err != nil {
+			return err
+		}
+		_, err := w.Write(quoteLargeInts(buf.Bytes()))
+		return err
+	}
+	return encodeJSON(w, v)
+}
+
+func encodeJSON(w io.Writer, v any) error {
 	cfg := global.get()
 
 	if cfg.JSONEncodeFunc != nil {
@@ -241,6 +765,70 @@ func jsonEncode(w io.Writer, v any) error {
 	return encoder.Encode(v)
 }
 
+// maxSafeInteger is the largest integer a JavaScript number represents exactly
+const maxSafeInteger = 1<<53 - 1
+
+// quoteLargeInts wraps the integer literals in the encoded JSON data that exceed
+// maxSafeInteger in magnitude in quotes, leaving strings and other numbers alone
+func quoteLargeInts(data []byte) []byte {
+	var out []byte
+	last := 0
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+		if c == '"' {
+			inString = true
+			continue
+		}
+		if c != '-' && (c < '0' || c > '9') {
+			continue
+		}
+
+		end := i
+		for end < len(data) && strings.IndexByte("-+.eE0123456789", data[end]) >= 0 {
+			end++
+		}
+		if literal := data[i:end]; isLargeInt(literal) {
+			out = append(out, data[last:i]...)
+			out = append(out, '"')
+			out = append(out, literal...)
+			out = append(out, '"')
+			last = end
+		}
+		i = end - 1
+	}
+	if out == nil {
+		return data
+	}
+	return append(out, data[last:]...)
+}
+
+// isLargeInt reports whether the number literal is an integer beyond maxSafeInteger
+func isLargeInt(literal []byte) bool {
+	digits := strings.TrimPrefix(string(literal), "-")
+	if strings.ContainsAny(digits, ".eE+-") {
+		return false
+	}
+	n, err := strconv.ParseUint(digits, 10, 64)
+	return err != nil || n > maxSafeInteger
+}
+
+func xmlUnmarshal(data []byte, v any) error {
+	if fn := global.get().XMLUnmarshalFunc; fn != nil {
+		return fn(data, v)
+	}
+	return xml.Unmarshal(data, v)
+}
+
 func jsonUnmarshal(data []byte, v any) error {
 	cfg := global.get()
 	if cfg.JSONUnmarshalFunc != nil {
@@ -249,18 +837,87 @@ func jsonUnmarshal(data []byte, v any) error {
 	return json.Unmarshal(data, v)
 }
 
-func validate(v any) error {
+func validate(ctx context.Context, v any) error {
 	cfg := global.get()
-	if !cfg.EnableValidation || cfg.Validator == nil {
+	if !cfg.EnableValidation {
 		return nil
 	}
-	return cfg.Validator.Struct(v)
+	if vd := requestValidator(ctx); vd != nil {
+		return vd.Struct(v)
+	}
+	return nil
+}
+
+type validationGroupKey struct{}
+
+// ValidateAs returns a middleware that validates the wrapped handler's extracted
+// values with the rules of a group registered with WithValidationGroup.
+// It panics if the group is not registered yet, so register groups first.
+// Should the group be gone by the time a request arrives, e.g. after Reset,
+// the request is answered with 500
+//
+//	mux.Handle("PATCH /users/{id}", m.ValidateAs("update")(m.H(updateUser)))
+func ValidateAs(group string) func(http.Handler) http.Handler {
+	if _, ok := global.get().ValidationGroups[group]; !ok {
+		log.Panicf("ValidateAs: validation group %q is not registered, add it with WithValidationGroup", group)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			v, ok := global.get().ValidationGroups[group]
+			if !ok {
+				logger().Printf("ValidateAs: validation group %q is not registered", group)
+				if e := handleError(w, &HTTPError{Code: http.StatusInternalServerError, Err: "internal_error"}); e != nil {
+					logger().Printf("failed to write error response: %v", e)
+				}
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), validationGroupKey{}, v)))
+		})
+	}
+}
+
+// requestValidator returns the validator of the request's validation group,
+// or Config.Validator outside of one
+func requestValidator(ctx context.Context) *Validator {
+	if v, ok := ctx.Value(validationGroupKey{}).(*Validator); ok {
+		return v
+	}
+	return global.get().Validator
+}
+
+// validateResponse logs a warning if data is a struct (or pointer to one)
+// that fails validation, when response validation is enabled
+func validateResponse(data any) {
+	cfg := global.get()
+	if !cfg.ValidateResponses || cfg.Validator == nil {
+		return
+	}
+
+	t := reflect.TypeOf(data)
+	if t.Kind() == reflect.Ptr {
+		if reflect.ValueOf(data).IsNil() {
+			return
+		}
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	if err := cfg.Validator.Struct(data); err != nil {
+		logger().Printf("Warning: response %s failed validation: %s", t.String(), formatValidationError(err))
+	}
 }
 
 func errorHandler() func(w http.ResponseWriter, err error) {
 	return global.get().ErrorHandler
 }
 
+func errorEncoder() func(w http.ResponseWriter, e *HTTPError) error {
+	return global.get().ErrorEncoder
+}
+
 const (
 	ErrTypeBodyRead       = "body_read_error"
 	ErrTypeEmptyBody      = "empty_body"
@@ -268,40 +925,182 @@ const (
 	ErrTypePathConversion = "path_conversion_error"
 	ErrTypeMissingPath    = "missing_path_value"
 	ErrTypeValidation     = "validation_error"
+	ErrTypeNDJSONLine     = "ndjson_line_error"
+	ErrTypeMediaType      = "unsupported_media_type"
+	ErrTypeVersion        = "unsupported_version"
+	ErrTypeBodyTimeout    = "body_read_timeout"
+	ErrTypeQueryTooLong   = "query_too_long"
+	ErrTypeEncoding       = "content_encoding_error"
+	ErrTypeAuthScheme     = "auth_scheme_error"
+	ErrTypeHeader         = "header_error"
+	ErrTypeCookie         = "cookie_error"
+	ErrTypeMissingCookie  = "missing_cookie"
+	ErrTypeMultipart      = "multipart_error"
+	ErrTypeMissingFile    = "missing_file"
+	ErrTypeBodyTooLarge   = "body_too_large"
+	ErrTypeTooManyFields  = "too_many_fields"
+	ErrTypeXMLSyntax      = "xml_syntax_error"
+	ErrTypeXMLType        = "xml_type_error"
 )
 
 var (
-	extractorType = reflect.TypeOf((*Extractor)(nil)).Elem()
-	errorType     = reflect.TypeOf((*error)(nil)).Elem()
-	readerType    = reflect.TypeOf((*io.Reader)(nil)).Elem()
-
-	handlerType        = reflect.TypeOf((*http.Handler)(nil)).Elem()
-	responseWriterType = reflect.TypeOf((*http.ResponseWriter)(nil)).Elem()
-	httpRequestType    = reflect.TypeOf((*http.Request)(nil))
+	extractorType     = reflect.TypeOf((*Extractor)(nil)).Elem()
+	bodyExtractorType = reflect.TypeOf((*BodyExtractor)(nil)).Elem()
+	contentTyperType  = reflect.TypeOf((*ContentTyper)(nil)).Elem()
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+	readerType        = reflect.TypeOf((*io.Reader)(nil)).Elem()
+
+	handlerType            = reflect.TypeOf((*http.Handler)(nil)).Elem()
+	responseWriterType     = reflect.TypeOf((*http.ResponseWriter)(nil)).Elem()
+	mintResponseWriterType = reflect.TypeOf((*ResponseWriter)(nil))
+	httpRequestType        = reflect.TypeOf((*http.Request)(nil))
+	contextType            = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+	timeType         = reflect.TypeOf(time.Time{})
+	resultMarkerType = reflect.TypeOf((*resultMarker)(nil)).Elem()
 )
 
+// StatusCode is a response consisting of a status code only.
+// The zero value writes 200 OK, like a handler that writes nothing;
+// NoResponse leaves the response untouched
 type StatusCode int
+
+// NoResponse is a StatusCode that makes H write nothing at all, for handlers
+// that have already written the response through the http.ResponseWriter
+const NoResponse StatusCode = -1
+
 type HTML string
 
-type HTTPError struct {
-	Code    int    `json:"code"`
-	Err     string `json:"error"`
-	Message string `json:"message,omitempty"`
-}
+// RawJSON is a response of already encoded JSON, e.g. from a cache, written as is
+// with the JSON content type. It is not re-encoded, so ResponseTransform and
+// ProjectResponse do not apply. With Config.ValidateResponses on, malformed JSON
+// is logged as a warning
+type RawJSON []byte
 
-func (e HTTPError) Error() string {
-	if e.Message != "" {
-		return e.Message
-	}
-	return e.Err
+// Pair is a single entry of an OrderedMap
+type Pair struct {
+	Key   string
+	Value any
 }
 
-type Result[T any] struct {
+// OrderedMap is a JSON object whose keys are encoded in insertion order,
+// unlike a Go map whose keys encoding/json sorts
+type OrderedMap []Pair
+
+// Set updates the value of key in place, or appends it if it is not present
+func (om *OrderedMap) Set(key string, value any) {
+	for i := range *om {
+		if (*om)[i].Key == key {
+			(*om)[i].Value = value
+			return
+		}
+	}
+	*om = append(*om, Pair{Key: key, Value: value})
+}
+
+// Get returns the value of key and whether it is present
+func (om OrderedMap) Get(key string) (any, bool) {
+	for _, p := range om {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+	return nil, false
+}
+
+func (om OrderedMap) MarshalJSON() ([]byte, error) {
+	if om == nil {
+		return []byte("null"), nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+
+	buf.WriteByte('{')
+	for i, p := range om {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := encoder.Encode(p.Key); err != nil {
+			return nil, err
+		}
+		buf.Truncate(buf.Len() - 1) // drop the newline added by Encode
+		buf.WriteByte(':')
+		if err := encoder.Encode(p.Value); err != nil {
+			return nil, err
+		}
+		buf.Truncate(buf.Len() - 1)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+type HTTPError struct {
+	Code    int    `json:"code"`
+	Err     string `json:"error"`
+	Message string `json:"message,omitempty"`
+
+	// Details carries structured context for clients, such as the offending field
+	Details map[string]any `json:"details,omitempty"`
+
+	// RetryAfter, when positive, is sent as a Retry-After header in whole seconds,
+	// telling clients when to try again, typically with a 429 or 503
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e HTTPError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return e.Err
+}
+
+// WithDetails returns a copy of e carrying details, leaving e itself unchanged
+// so shared errors can be specialized per request
+//
+//	return m.Conflict("email already registered").WithDetails(map[string]any{"field": "email", "reason": "taken"})
+func (e *HTTPError) WithDetails(details map[string]any) *HTTPError {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+// BadRequest returns a 400 "bad_request" error with the given message
+func BadRequest(msg string) *HTTPError {
+	return &HTTPError{Code: http.StatusBadRequest, Err: "bad_request", Message: msg}
+}
+
+// Unauthorized returns a 401 "unauthorized" error with the given message
+func Unauthorized(msg string) *HTTPError {
+	return &HTTPError{Code: http.StatusUnauthorized, Err: "unauthorized", Message: msg}
+}
+
+// Forbidden returns a 403 "forbidden" error with the given message
+func Forbidden(msg string) *HTTPError {
+	return &HTTPError{Code: http.StatusForbidden, Err: "forbidden", Message: msg}
+}
+
+// NotFound returns a 404 "not_found" error with the given message
+func NotFound(msg string) *HTTPError {
+	return &HTTPError{Code: http.StatusNotFound, Err: "not_found", Message: msg}
+}
+
+// Conflict returns a 409 "conflict" error with the given message
+func Conflict(msg string) *HTTPError {
+	return &HTTPError{Code: http.StatusConflict, Err: "conflict", Message: msg}
+}
+
+type Result[T any] struct {
 	Code    int
 	Headers http.Header
 
 	Data T
 	Err  error
+
+	// errBody renders Data as the body of an error result, see ErrWithBody
+	errBody bool
 }
 
 func (Result[T]) isResultType() bool {
@@ -314,13 +1113,67 @@ func (r Result[T]) toResult() Result[any] {
 		Headers: r.Headers,
 		Data:    r.Data,
 		Err:     r.Err,
+		errBody: r.errBody,
+	}
+}
+
+// WithStatus returns a copy of the result with the given status code
+func (r Result[T]) WithStatus(code int) Result[T] {
+	r.Code = code
+	return r
+}
+
+// WithHeader returns a copy of the result with the header key set to value
+func (r Result[T]) WithHeader(key, value string) Result[T] {
+	if r.Headers == nil {
+		r.Headers = http.Header{}
 	}
+	r.Headers.Set(key, value)
+	return r
+}
+
+// WithCookie returns a copy of the result that sets the given cookie.
+// Invalid cookies are silently dropped, as with http.SetCookie
+func (r Result[T]) WithCookie(c *http.Cookie) Result[T] {
+	if v := c.String(); v != "" {
+		if r.Headers == nil {
+			r.Headers = http.Header{}
+		}
+		r.Headers.Add("Set-Cookie", v)
+	}
+	return r
+}
+
+// RateLimit describes a client's request quota
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// WithRateLimit returns a copy of the result carrying rl as the X-RateLimit-Limit,
+// X-RateLimit-Remaining and X-RateLimit-Reset headers. Reset is sent as a Unix
+// timestamp in seconds, and omitted if zero
+func (r Result[T]) WithRateLimit(rl RateLimit) Result[T] {
+	r = r.WithHeader("X-RateLimit-Limit", strconv.Itoa(rl.Limit))
+	r = r.WithHeader("X-RateLimit-Remaining", strconv.Itoa(rl.Remaining))
+	if !rl.Reset.IsZero() {
+		r = r.WithHeader("X-RateLimit-Reset", strconv.FormatInt(rl.Reset.Unix(), 10))
+	}
+	return r
 }
 
 func OK[T any](data T) Result[T] {
 	return Result[T]{Data: data}
 }
 
+// Accepted returns a 202 result for work that continues asynchronously, with data
+// describing the job and a Location header pointing at statusURL, where its
+// progress can be checked
+func Accepted[T any](statusURL string, data T) Result[T] {
+	return OK(data).WithStatus(http.StatusAccepted).WithHeader("Location", statusURL)
+}
+
 func Err[T any](code int, err error) Result[T] {
 	return Result[T]{
 		Code: code,
@@ -328,35 +1181,84 @@ func Err[T any](code int, err error) Result[T] {
 	}
 }
 
+// ErrWithBody returns an error result whose response body is body, e.g. a
+// validation report, instead of the HTTPError envelope. The status is code, or
+// the one mint maps err to if code is zero. err is still logged for 5xx statuses
+// and its headers, such as Retry-After, are still sent
+func ErrWithBody[T any](code int, err error, body T) Result[T] {
+	return Result[T]{
+		Code:    code,
+		Data:    body,
+		Err:     err,
+		errBody: true,
+	}
+}
+
 type Extractor interface {
 	Extract(*http.Request) error
 }
 
+// BodyExtractor is implemented by extractors that consume the request body.
+// When a handler takes several of them, H buffers the body once and hands
+// each extractor a fresh reader over it
+type BodyExtractor interface {
+	Extractor
+	ReadsBody() bool
+}
+
+// ContentTyper is implemented by extractors that expect a particular request
+// content type, or one of several separated by commas. With Config.EnforceContentType
+// on, H rejects requests whose body has a different type with 415 before any extractor runs
+type ContentTyper interface {
+	ExpectedContentType() string
+}
+
 type KeySetter interface {
 	SetKey(string)
 }
 
+// Responder is implemented by return values that write their own response.
+// An error that is also a Responder is written with Respond rather than as a
+// JSON error, wherever it is returned, and the ErrorHandler is not called
 type Responder interface {
 	Respond(w http.ResponseWriter)
 }
 
 type PathValue interface {
-	~string | ~int | ~int64 | ~uint | ~uint64 | ~float64 | ~bool
+	~string | ~int | ~int64 | ~uint | ~uint64 | ~float64 | ~bool | time.Time
 }
 
 type JSON[T any] struct {
 	Value T
+
+	raw []byte
+}
+
+// Raw returns the body T was decoded from, exactly as read (after any
+// decompression), e.g. to verify a webhook signature over the payload
+func (j *JSON[T]) Raw() []byte {
+	return j.raw
+}
+
+func (j *JSON[T]) ReadsBody() bool {
+	return true
+}
+
+func (j *JSON[T]) ExpectedContentType() string {
+	return "application/json"
 }
 
 func (j *JSON[T]) Extract(r *http.Request) error {
+	limitBodyTime(r)
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		return NewBodyReadError(err)
+		return bodyReadError(err)
 	}
 
 	if len(body) == 0 {
 		return NewEmptyBodyError()
 	}
+	j.raw = body
 
 	val := reflect.ValueOf(&j.Value).Elem()
 
@@ -366,48 +1268,162 @@ func (j *JSON[T]) Extract(r *http.Request) error {
 		return err
 	}
 
-	if err := validate(target); err != nil {
+	if err := validate(r.Context(), target); err != nil {
 		return NewValidationError(err)
 	}
 
 	return nil
 }
 
+// NDJSON extracts a newline-delimited JSON body, one T per line.
+// The body is read line by line rather than buffered as a whole, and blank lines are skipped
+type NDJSON[T any] struct {
+	Value []T
+}
+
+func (n *NDJSON[T]) ReadsBody() bool {
+	return true
+}
+
+func (n *NDJSON[T]) Extract(r *http.Request) error {
+	limitBodyTime(r)
+	reader := bufio.NewReader(r.Body)
+	n.Value = nil
+
+	for lineNo := 1; ; lineNo++ {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return bodyReadError(readErr)
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var item T
+			target := getPointer(reflect.ValueOf(&item).Elem())
+
+			if err := jsonUnmarshal(line, target); err != nil {
+				return NewNDJSONLineError(lineNo, err)
+			}
+
+			if err := validate(r.Context(), target); err != nil {
+				return &ExtractError{
+					Type:    ErrTypeValidation,
+					Field:   strconv.Itoa(lineNo),
+					Message: fmt.Sprintf("line %d: %s", lineNo, formatValidationError(err)),
+					Err:     err,
+				}
+			}
+
+			n.Value = append(n.Value, item)
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	if len(n.Value) == 0 {
+		return NewEmptyBodyError()
+	}
+
+	return nil
+}
+
 type Query[T any] struct {
 	Value T
 }
 
 func (q *Query[T]) Extract(r *http.Request) error {
+	if limit := global.get().MaxQueryLength; limit > 0 && len(r.URL.RawQuery) > limit {
+		return NewQueryTooLongError(len(r.URL.RawQuery), limit)
+	}
+
 	val := reflect.ValueOf(&q.Value).Elem()
 
+	values := r.URL.Query()
+	if sep := global.get().QuerySliceSeparator; sep != "" {
+		splitSliceValues(values, reflect.TypeFor[T](), sep)
+	}
+
 	target := getPointer(val)
-	if err := schemaDecoder().Decode(target, r.URL.Query()); err != nil {
+	if err := decodeValues(queryDecoder(), target, values); err != nil {
 		return err
 	}
+	applyDefaults(target, values)
 
-	if err := validate(target); err != nil {
+	if err := validate(r.Context(), target); err != nil {
 		return NewValidationError(err)
 	}
 
 	return nil
 }
 
+func (q *Query[T]) checkDefaults() {
+	structDefaults(reflect.TypeFor[T]())
+}
+
+// splitSliceValues splits the values of the slice fields of t on sep, in place
+func splitSliceValues(values map[string][]string, t reflect.Type, sep string) {
+	fields, ok := taggedFields(t, "schema")
+	if !ok {
+		return
+	}
+	for _, field := range fields {
+		sent, ok := values[field.name]
+		if !field.slice || !ok {
+			continue
+		}
+		split := make([]string, 0, len(sent))
+		for _, value := range sent {
+			for _, item := range strings.Split(value, sep) {
+				if item != "" {
+					split = append(split, item)
+				}
+			}
+		}
+		values[field.name] = split
+	}
+}
+
 type Form[T any] struct {
 	Value T
 }
 
+func (f *Form[T]) checkDefaults() {
+	structDefaults(reflect.TypeFor[T]())
+}
+
+func (f *Form[T]) ReadsBody() bool {
+	return true
+}
+
+func (f *Form[T]) ExpectedContentType() string {
+	return "application/x-www-form-urlencoded"
+}
+
 func (f *Form[T]) Extract(r *http.Request) error {
+	limitBodyTime(r)
 	if err := r.ParseForm(); err != nil {
+		if errors.Is(err, errBodyReadTimeout) {
+			return NewBodyReadTimeoutError()
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return NewBodyTooLargeError(tooLarge.Limit)
+		}
 		return NewFormParseError(err)
 	}
+	if limit := global.get().MaxFormFields; limit > 0 && len(r.Form) > limit {
+		return NewTooManyFieldsError(len(r.Form), limit)
+	}
 
 	val := reflect.ValueOf(&f.Value).Elem()
 	target := getPointer(val)
-	if err := schemaDecoder().Decode(target, r.Form); err != nil {
+	if err := decodeValues(formDecoder(), target, r.Form); err != nil {
 		return err
 	}
+	applyDefaults(target, r.Form)
 
-	if err := validate(target); err != nil {
+	if err := validate(r.Context(), target); err != nil {
 		return NewValidationError(err)
 	}
 
@@ -429,6 +1445,15 @@ func (p *Path[T]) Extract(r *http.Request) error {
 		return NewMissingPathError(p.Key)
 	}
 
+	if convert := pathConverter(reflect.TypeFor[T]()); convert != nil {
+		val, err := convert(pv)
+		if err != nil {
+			return NewPathConversionError(p.Key, pv, reflect.TypeFor[T]().String(), err)
+		}
+		p.Value = val.(T)
+		return nil
+	}
+
 	switch ptr := any(&p.Value).(type) {
 	case *string:
 		*ptr = pv
@@ -469,11 +1494,12 @@ func (p *Path[T]) Extract(r *http.Request) error {
 			*ptr = val
 		}
 	default:
-		return &ExtractError{
-			Type:    "unsupported_type",
-			Field:   p.Key,
-			Message: fmt.Sprintf("Unsupported path parameter type: %T", &p.Value),
+		// Named types such as `type UserID int` fall through to here
+		val, err := convertString(pv, reflect.TypeFor[T]())
+		if err != nil {
+			return NewPathConversionError(p.Key, pv, reflect.TypeFor[T]().String(), err)
 		}
+		p.Value = val.Interface().(T)
 	}
 	return nil
 }
@@ -494,10 +1520,75 @@ func (e *ExtractError) Unwrap() error {
 	return e.Err
 }
 
+// PanicError wraps a value recovered from a panicking handler
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// logPanic logs a recovered panic with its stack trace and the name of the handler,
+// prefixed with the request ID if there is one
+func logPanic(r *http.Request, handler string, e *PanicError) {
+	if id := RequestID(r.Context()); id != "" {
+		logger().Printf("[%s] %s %s %s: %v\n%s", id, handler, r.Method, r.URL.Path, e, e.Stack)
+	} else {
+		logger().Printf("%s %s %s: %v\n%s", handler, r.Method, r.URL.Path, e, e.Stack)
+	}
+}
+
 type ResponseWriter struct {
 	http.ResponseWriter
 	statusCode    int
 	headerWritten bool
+	pendingStatus int
+
+	// request is the request being answered, for Config.ProjectResponse
+	request *http.Request
+	// handlerName names the handler writing the response, see Named
+	handlerName string
+}
+
+// HandlerName returns the name of the handler wrapped with H that is writing
+// the response, see Named. It is empty until the handler starts running
+func (rw *ResponseWriter) HandlerName() string {
+	return rw.handlerName
+}
+
+// SetStatus records the status to respond with without writing it yet. It is sent
+// with the first Write or Flush, or when the handler returns, so headers set in the
+// meantime, including those mint sets for the returned value, still reach the client.
+// An explicit WriteHeader, e.g. for a returned error or StatusCode, takes precedence
+func (rw *ResponseWriter) SetStatus(code int) {
+	if rw.headerWritten {
+		logger().Printf("Warning: SetStatus(%d) after the status was written, original status code: %d", code, rw.statusCode)
+		return
+	}
+	rw.pendingStatus = code
+}
+
+// commitStatus writes the status recorded by SetStatus, if nothing has been written yet
+func (rw *ResponseWriter) commitStatus() {
+	if !rw.headerWritten && rw.pendingStatus != 0 {
+		rw.WriteHeader(rw.pendingStatus)
+	}
+}
+
+// Status returns the status code sent to the client, or 200 if nothing has been written yet
+func (rw *ResponseWriter) Status() int {
+	if rw.statusCode == 0 {
+		return http.StatusOK
+	}
+	return rw.statusCode
 }
 
 func (rw *ResponseWriter) WriteHeader(code int) {
@@ -515,23 +1606,68 @@ func (rw *ResponseWriter) WriteHeader(code int) {
 
 func (rw *ResponseWriter) Write(b []byte) (int, error) {
 	if !rw.headerWritten {
-		rw.WriteHeader(http.StatusOK)
+		rw.WriteHeader(rw.pendingStatus)
 	}
 	return rw.ResponseWriter.Write(b)
 }
 
+// Flush implements http.Flusher, sending buffered data to the client
+// if the underlying writer supports it
+func (rw *ResponseWriter) Flush() {
+	if !rw.headerWritten {
+		rw.WriteHeader(rw.pendingStatus)
+	}
+	if err := http.NewResponseController(rw.ResponseWriter).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logger().Printf("failed to flush response: %v", err)
+	}
+}
+
+// Unwrap returns the underlying writer, letting http.ResponseController
+// reach features like flushing that the wrapper does not expose itself
+func (rw *ResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 type resultMarker interface {
 	isResultType() bool
 	toResult() Result[any]
 }
 
+// H turns fn into an http.HandlerFunc, wrapped in the middleware registered with Use
 func H(fn any) http.HandlerFunc {
-	fnVal := reflect.ValueOf(fn)
-	fnType := fnVal.Type()
+	return HWith(fn)
+}
+
+// namedHandler is a handler function given a name with Named
+type namedHandler struct {
+	name string
+	fn   any
+}
+
+// Named gives fn a name to be wrapped with H or HWith under, e.g.
+// m.H(m.Named("createUser", func(...) ...)). The name identifies the handler in
+// panic and server error logs, and middleware can read it from the ResponseWriter,
+// e.g. for access logs. Without it, the name of the function is used, such as
+// "main.createUser", or "main.main.func1" for a function literal
+func Named(name string, fn any) any {
+	return namedHandler{name: name, fn: fn}
+}
+
+// newHandler builds the handler for fn, without any middleware
+func newHandler(fn any) http.HandlerFunc {
+	var name string
+	if named, ok := fn.(namedHandler); ok {
+		name, fn = named.name, named.fn
+	}
 
-	if fnType.Kind() != reflect.Func {
+	fnVal := reflect.ValueOf(fn)
+	if fnVal.Kind() != reflect.Func {
 		log.Panicf("H: handler must be a function, got %T", fn)
 	}
+	fnType := fnVal.Type()
+	if name == "" {
+		name = funcName(fnVal)
+	}
 
 	paramTypes := make([]reflect.Type, fnType.NumIn())
 	for i := 0; i < fnType.NumIn(); i++ {
@@ -559,7 +1695,7 @@ func H(fn any) http.HandlerFunc {
 		if rt1.Kind() == reflect.Interface {
 			log.Panic("H: first return value cannot be an interface when returning two values")
 		}
-		if rt1.Implements(reflect.TypeOf((*resultMarker)(nil)).Elem()) {
+		if rt1.Implements(resultMarkerType) {
 			log.Panicf("H: first return value cannot be Result when returning two values")
 		}
 
@@ -568,13 +1704,140 @@ func H(fn any) http.HandlerFunc {
 		}
 	}
 
+	bodyExtractors := 0
+	var contentTypes []string
+	for _, paramType := range paramTypes {
+		if reflect.PointerTo(paramType).Implements(bodyExtractorType) {
+			bodyExtractors++
+		}
+		if reflect.PointerTo(paramType).Implements(contentTyperType) {
+			ct := reflect.New(paramType).Interface().(ContentTyper).ExpectedContentType()
+			contentTypes = append(contentTypes, ct)
+		}
+		// Malformed `default` tags are reported now rather than on the first request
+		if checker, ok := reflect.New(paramType).Interface().(defaultsChecker); ok {
+			checker.checkDefaults()
+		}
+		// As are `file` fields of a type MultipartForm cannot set
+		if checker, ok := reflect.New(paramType).Interface().(fileFieldsChecker); ok {
+			checker.checkFileFields()
+		}
+	}
+
+	if global.get().RecordHandlerInfo {
+		recordHandler(fnVal, name)
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		args := make([]reflect.Value, len(paramTypes))
 
 		pathKeys := extractPatternNames(r.Pattern)
 		keyIdx := 0
 
-		rw := &ResponseWriter{ResponseWriter: w}
+		// Reuse the writer HWith handed to the middleware, so they see the final status
+		rw, ok := w.(*ResponseWriter)
+		if !ok {
+			rw = &ResponseWriter{ResponseWriter: w}
+		}
+		if RequestID(r.Context()) == "" {
+			r = withRequestID(rw, r)
+		}
+		r = withBodyWriter(rw, r)
+		rw.request = r
+		rw.handlerName = name
+
+		// Deferred first, so the gzip stream is finished after everything else is written
+		if global.get().CompressResponses {
+			// Once per response, also when Fallback runs several handlers on it
+			if !slices.Contains(rw.Header().Values("Vary"), "Accept-Encoding") {
+				rw.Header().Add("Vary", "Accept-Encoding")
+			}
+			if _, ok := rw.ResponseWriter.(*gzipWriter); !ok && r.Method != http.MethodHead && acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				gw := &gzipWriter{ResponseWriter: rw.ResponseWriter, minSize: global.get().CompressionMinSize}
+				rw.ResponseWriter = gw
+				defer func() {
+					if err := gw.Close(); err != nil {
+						logger().Printf("failed to finish compressed response: %v", err)
+					}
+					rw.ResponseWriter = gw.ResponseWriter
+				}()
+			}
+		}
+		defer rw.commitStatus()
+
+		if global.get().RecoverPanics {
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				panicErr := &PanicError{Value: v, Stack: debug.Stack()}
+				logPanic(r, name, panicErr)
+				if rw.headerWritten {
+					return
+				}
+				if e := handleError(rw, panicErr); e != nil {
+					logger().Printf("failed to write error response: %v", e)
+				}
+			}()
+		}
+
+		if err := checkContentType(r, global.get().AllowedContentTypes); err != nil {
+			if e := handleError(rw, err); e != nil {
+				logger().Printf("failed to write error response: %v", e)
+			}
+			return
+		}
+
+		if global.get().EnforceContentType {
+			for _, ct := range contentTypes {
+				if err := checkContentType(r, strings.Split(ct, ",")); err != nil {
+					if e := handleError(rw, err); e != nil {
+						logger().Printf("failed to write error response: %v", e)
+					}
+					return
+				}
+			}
+		}
+
+		if global.get().DecompressRequests {
+			if err := decompressBody(r); err != nil {
+				if e := handleError(rw, err); e != nil {
+					logger().Printf("failed to write error response: %v", e)
+				}
+				return
+			}
+		}
+
+		if limit := global.get().MaxBodySize; limit > 0 && r.Body != nil && r.Body != http.NoBody {
+			// Reject a declared oversized body before reading any of it, so a client
+			// waiting on "Expect: 100-continue" never sends it
+			if r.ContentLength > limit {
+				if e := handleError(rw, NewBodyTooLargeError(limit)); e != nil {
+					logger().Printf("failed to write error response: %v", e)
+				}
+				return
+			}
+			r.Body = http.MaxBytesReader(rw, r.Body, limit)
+		}
+
+		var body []byte
+		buffered := r.Body != nil && (bodyExtractors > 1 || global.get().BufferRequestBody)
+		if buffered {
+			limitBodyTime(r)
+			var err error
+			if body, err = io.ReadAll(r.Body); err != nil {
+				if e := handleError(rw, bodyReadError(err)); e != nil {
+					logger().Printf("failed to write error response: %v", e)
+				}
+				return
+			}
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
 
 		for i, paramType := range paramTypes {
 			switch {
@@ -582,6 +1845,10 @@ func H(fn any) http.HandlerFunc {
 				paramVal := reflect.New(paramType).Elem()
 				extractor := paramVal.Addr().Interface().(Extractor)
 
+				if be, ok := extractor.(BodyExtractor); buffered && ok && be.ReadsBody() {
+					r.Body = io.NopCloser(bytes.NewReader(body))
+				}
+
 				if ks, ok := extractor.(KeySetter); ok {
 					if keyIdx >= len(pathKeys) {
 						log.Panicf("H: pattern %q has insufficient path parameters", r.Pattern)
@@ -599,30 +1866,55 @@ func H(fn any) http.HandlerFunc {
 				}
 				args[i] = paramVal
 
-			case paramType.Implements(responseWriterType) && paramType.Kind() == reflect.Interface:
+			case paramType.Implements(responseWriterType) && paramType.Kind() == reflect.Interface,
+				paramType == mintResponseWriterType:
 				args[i] = reflect.ValueOf(rw)
 
 			case paramType == httpRequestType:
 				args[i] = reflect.ValueOf(r)
 
+			case paramType == contextType:
+				args[i] = reflect.ValueOf(r.Context())
+
 			default:
 				log.Panicf("H: unsupported parameter type %s", paramType.String())
 			}
 		}
 
+		if buffered {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		if before := global.get().BeforeHandler; before != nil {
+			values := make([]any, len(args))
+			for i, arg := range args {
+				values[i] = arg.Interface()
+			}
+			if err := before(r, values); err != nil {
+				if e := handleError(rw, err); e != nil {
+					logger().Printf("failed to write error response: %v", e)
+				}
+				return
+			}
+		}
+
 		results := fnVal.Call(args)
 
 		if len(results) == 0 {
+			writeVoidStatus(rw)
+			return
+		}
+		if passedOn(r, results) {
 			return
 		}
 
 		if len(results) == 1 {
-			if isNilValue(results[0]) {
+			if isNilValue(results[0]) && !encodesAsNull(results[0]) {
 				return
 			}
 
 			rv := results[0].Interface()
-			if handler, ok := rv.(http.Handler); ok {
+			if handler, ok := asHandler(rv); ok {
 				handler.ServeHTTP(rw, r)
 				return
 			}
@@ -634,7 +1926,7 @@ func H(fn any) http.HandlerFunc {
 		}
 
 		if len(results) == 2 {
-			if isNilValue(results[0]) && isNilValue(results[1]) {
+			if isNilValue(results[0]) && !encodesAsNull(results[0]) && isNilValue(results[1]) {
 				return
 			}
 
@@ -649,6 +1941,106 @@ func H(fn any) http.HandlerFunc {
 	}
 }
 
+// writeVoidStatus sends Config.VoidStatus for a handler without return values,
+// unless the handler already wrote a response or set a status itself
+func writeVoidStatus(rw *ResponseWriter) {
+	code := global.get().VoidStatus
+	if code == 0 || rw.headerWritten || rw.pendingStatus != 0 {
+		return
+	}
+	if !bodyAllowed(code) {
+		rw.Header().Del("Content-Type")
+	}
+	rw.WriteHeader(code)
+}
+
+// checkContentType rejects requests carrying a body whose media type is not in allowed.
+// Requests without a body, and any request when allowed is empty, pass
+func checkContentType(r *http.Request, allowed []string) error {
+	if len(allowed) == 0 || r.ContentLength == 0 || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		for _, a := range allowed {
+			if mediaTypeMatches(a, mediaType) {
+				return nil
+			}
+		}
+	}
+
+	return NewUnsupportedMediaTypeError(contentType)
+}
+
+// mediaTypeMatches reports whether mediaType matches pattern,
+// which may use a wildcard subtype ("text/*") or be "*/*"
+func mediaTypeMatches(pattern, mediaType string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pattern == "*/*" || pattern == mediaType {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(mediaType, prefix+"/")
+	}
+	return false
+}
+
+// asHandler reports whether v can serve the request itself, either as an
+// http.Handler (including http.HandlerFunc) or as a plain handler function
+func asHandler(v any) (http.Handler, bool) {
+	switch h := v.(type) {
+	case http.Handler:
+		return h, true
+	case func(http.ResponseWriter, *http.Request):
+		return http.HandlerFunc(h), true
+	default:
+		return nil, false
+	}
+}
+
+// Delegate returns a handler that adds headers to the response, as a Result would,
+// and then lets h serve the request. Return it from a handler to wrap a third-party
+// handler such as http.FileServer. h can still override the headers it sets itself
+//
+//	return m.Delegate(assets, http.Header{"Cache-Control": {"public, max-age=3600"}})
+func Delegate(h http.Handler, headers http.Header) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteHeaders(w, headers)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// OptionsHandler returns a handler answering OPTIONS requests with 204 No Content
+// and an Allow header listing the given methods. OPTIONS itself is always included,
+// and so is HEAD along with GET, since http.ServeMux routes HEAD requests to GET
+// patterns and http.Server drops the body of the response
+func OptionsHandler(methods ...string) http.HandlerFunc {
+	allowed := make([]string, 0, len(methods)+2)
+	seen := make(map[string]bool, len(methods)+2)
+	add := func(method string) {
+		if method == "" || seen[method] {
+			return
+		}
+		seen[method] = true
+		allowed = append(allowed, method)
+	}
+	for _, method := range append(methods[:len(methods):len(methods)], http.MethodOptions) {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		add(method)
+		if method == http.MethodGet {
+			add(http.MethodHead)
+		}
+	}
+	allow := strings.Join(allowed, ", ")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
 func WriteHeaders(w http.ResponseWriter, headers http.Header) {
 	for key, values := range headers {
 		for _, value := range values {
@@ -665,6 +2057,38 @@ func NewBodyReadError(err error) error {
 	}
 }
 
+func NewBodyReadTimeoutError() error {
+	return &ExtractError{
+		Type:    ErrTypeBodyTimeout,
+		Message: "timed out reading request body",
+		Err:     errBodyReadTimeout,
+	}
+}
+
+func NewBodyTooLargeError(limit int64) error {
+	return &ExtractError{
+		Type:    ErrTypeBodyTooLarge,
+		Value:   strconv.FormatInt(limit, 10),
+		Message: fmt.Sprintf("request body exceeds %d bytes", limit),
+	}
+}
+
+func NewTooManyFieldsError(count, limit int) error {
+	return &ExtractError{
+		Type:    ErrTypeTooManyFields,
+		Value:   strconv.Itoa(count),
+		Message: fmt.Sprintf("form has %d fields, limit is %d", count, limit),
+	}
+}
+
+func NewQueryTooLongError(length, limit int) error {
+	return &ExtractError{
+		Type:    ErrTypeQueryTooLong,
+		Value:   strconv.Itoa(length),
+		Message: fmt.Sprintf("query string is %d bytes, limit is %d", length, limit),
+	}
+}
+
 func NewEmptyBodyError() error {
 	return &ExtractError{
 		Type:    ErrTypeEmptyBody,
@@ -690,6 +2114,20 @@ func NewPathConversionError(field, value, targetType string, err error) error {
 	}
 }
 
+// NewPathParamsError reports path parameters that MultiPath could not decode
+func NewPathParamsError(err error) error {
+	message := "invalid path parameters"
+	var me schema.MultiError
+	if errors.As(err, &me) {
+		message = "invalid path parameters: " + schemaErrorsMessage(me)
+	}
+	return &ExtractError{
+		Type:    ErrTypePathConversion,
+		Message: message,
+		Err:     err,
+	}
+}
+
 func NewMissingPathError(field string) error {
 	return &ExtractError{
 		Type:    ErrTypeMissingPath,
@@ -698,6 +2136,135 @@ func NewMissingPathError(field string) error {
 	}
 }
 
+func NewNDJSONLineError(line int, err error) error {
+	return &ExtractError{
+		Type:    ErrTypeNDJSONLine,
+		Field:   strconv.Itoa(line),
+		Message: fmt.Sprintf("invalid JSON on line %d", line),
+		Err:     err,
+	}
+}
+
+func NewUnsupportedMediaTypeError(contentType string) error {
+	return &ExtractError{
+		Type:    ErrTypeMediaType,
+		Value:   contentType,
+		Message: fmt.Sprintf("unsupported content type: %q", contentType),
+	}
+}
+
+func NewUnsupportedEncodingError(encoding string) error {
+	return &ExtractError{
+		Type:    ErrTypeMediaType,
+		Value:   encoding,
+		Message: fmt.Sprintf("unsupported content encoding: %q", encoding),
+	}
+}
+
+func NewContentEncodingError(err error) error {
+	return &ExtractError{
+		Type:    ErrTypeEncoding,
+		Message: "malformed compressed request body",
+		Err:     err,
+	}
+}
+
+// NewAuthSchemeError reports a missing Authorization header when scheme is empty,
+// and an unsupported scheme otherwise
+func NewAuthSchemeError(scheme string) error {
+	if scheme == "" {
+		return &ExtractError{
+			Type:    ErrTypeAuthScheme,
+			Message: "missing Authorization header",
+		}
+	}
+	return &ExtractError{
+		Type:    ErrTypeAuthScheme,
+		Value:   scheme,
+		Message: fmt.Sprintf("unsupported authorization scheme: %q", scheme),
+	}
+}
+
+func NewHeaderError(err error) error {
+	message := "invalid request headers"
+	var me schema.MultiError
+	if errors.As(err, &me) {
+		message = schemaErrorsMessage(me)
+	}
+	return &ExtractError{
+		Type:    ErrTypeHeader,
+		Message: message,
+		Err:     err,
+	}
+}
+
+func NewCookieError(err error) error {
+	message := "invalid cookies"
+	var me schema.MultiError
+	if errors.As(err, &me) {
+		message = schemaErrorsMessage(me)
+	}
+	return &ExtractError{
+		Type:    ErrTypeCookie,
+		Message: message,
+		Err:     err,
+	}
+}
+
+func NewMissingCookieError(name string) error {
+	return &ExtractError{
+		Type:    ErrTypeMissingCookie,
+		Field:   name,
+		Message: fmt.Sprintf("missing required cookie: %s", name),
+	}
+}
+
+// NewXMLError reports an XML body that failed to decode, telling malformed
+// documents apart from values of the wrong type
+func NewXMLError(err error) error {
+	var syntaxErr *xml.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &ExtractError{
+			Type:    ErrTypeXMLSyntax,
+			Message: "invalid XML syntax",
+			Err:     err,
+		}
+	}
+	return &ExtractError{
+		Type:    ErrTypeXMLType,
+		Message: fmt.Sprintf("invalid XML value: %v", err),
+		Err:     err,
+	}
+}
+
+func NewMultipartError(err error) error {
+	message := "invalid multipart form data"
+	if errors.Is(err, http.ErrNotMultipart) {
+		message = "expected a multipart/form-data request body"
+	}
+	return &ExtractError{
+		Type:    ErrTypeMultipart,
+		Message: message,
+		Err:     err,
+	}
+}
+
+func NewMissingFileError(field string) error {
+	return &ExtractError{
+		Type:    ErrTypeMissingFile,
+		Field:   field,
+		Message: fmt.Sprintf("missing required file: %s", field),
+	}
+}
+
+func NewUnsupportedVersionError(version string) error {
+	return &ExtractError{
+		Type:    ErrTypeVersion,
+		Value:   version,
+		Message: fmt.Sprintf("unsupported API version: %s", version),
+	}
+}
+
 func NewValidationError(err error) error {
 	return &ExtractError{
 		Type:    ErrTypeValidation,
@@ -706,69 +2273,327 @@ func NewValidationError(err error) error {
 	}
 }
 
-// formatValidationError formats validation errors into user-friendly messages
-func formatValidationError(err error) string {
-	var ve validator.ValidationErrors
-	if !errors.As(err, &ve) {
-		return err.Error()
+// decodeValues decodes src into target using the schema decoder.
+//
+// Keys in bracket notation are accepted alongside the dotted paths the schema
+// decoder understands, so "items[0][name]" and "items[0].name" both mean "items.0.name".
+// Top-level fields of two kinds are decoded here rather than by the schema decoder:
+//   - time.Time fields, so each one can pick its own layout via the `time_format` tag,
+//     falling back to Config.TimeLayouts
+//   - map[string]V fields, filled from keys like "meta[color]" or "meta.color"
+//
+// Errors are reported under the key as the client sent it.
+func decodeValues(decoder *schema.Decoder, target any, src map[string][]string) error {
+	return decodeTagged(decoder, "schema", target, src)
+}
+
+// decodeTagged is decodeValues for a decoder whose alias tag is tag
+func decodeTagged(decoder *schema.Decoder, tag string, target any, src map[string][]string) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return decoder.Decode(target, src)
+	}
+	v = v.Elem()
+	t := v.Type()
+
+	rest := make(map[string][]string, len(src))
+	original := make(map[string]string)
+	for key, values := range src {
+		normalized := normalizeKey(key)
+		if normalized != key {
+			original[normalized] = key
+		}
+		rest[normalized] = append(rest[normalized], values...)
+	}
+
+	errs := schema.MultiError{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		isTime := fieldType == timeType
+		isMap := field.Type.Kind() == reflect.Map && field.Type.Key().Kind() == reflect.String
+		if !isTime && !isMap {
+			continue
+		}
+
+		key, opts, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = field.Name
+		}
+
+		if isMap {
+			decodeMapField(v.Field(i), key, rest, errs)
+			continue
+		}
+
+		// The schema decoder must not see this key, or it would try (and fail)
+		// to parse the value itself
+		values := rest[key]
+		delete(rest, key)
+
+		if len(values) == 0 || values[0] == "" {
+			if strings.Contains(opts, "required") {
+				errs[key] = schema.EmptyFieldError{Key: key}
+			}
+			continue
+		}
+
+		layouts := timeLayouts()
+		if layout := field.Tag.Get("time_format"); layout != "" {
+			layouts = []string{layout}
+		}
+
+		parsed, err := parseTime(values[0], layouts)
+		if err != nil {
+			errs[key] = schema.ConversionError{Key: key, Type: field.Type, Index: -1, Err: err}
+			continue
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Ptr {
+			fv.Set(reflect.New(timeType))
+			fv = fv.Elem()
+		}
+		fv.Set(reflect.ValueOf(parsed))
+	}
+
+	if err := decoder.Decode(target, rest); err != nil {
+		var me schema.MultiError
+		if !errors.As(err, &me) {
+			return err
+		}
+		for k, e := range me {
+			errs[k] = e
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	for normalized, key := range original {
+		if e, ok := errs[normalized]; ok {
+			delete(errs, normalized)
+			errs[key] = e
+		}
+	}
+	return errs
+}
+
+// normalizeKey rewrites bracket notation into the schema decoder's dotted paths,
+// e.g. "items[0][name]" becomes "items.0.name"
+func normalizeKey(key string) string {
+	if !strings.Contains(key, "[") {
+		return key
+	}
+	key = strings.ReplaceAll(key, "][", ".")
+	key = strings.ReplaceAll(key, "[", ".")
+	key = strings.ReplaceAll(key, "]", "")
+	return key
+}
+
+// decodeMapField moves every "prefix.name" entry out of src into the map field fv,
+// converting values to the map's element type. Slice elements take all values,
+// others take the first one
+func decodeMapField(fv reflect.Value, prefix string, src map[string][]string, errs schema.MultiError) {
+	mapType := fv.Type()
+	elemType := mapType.Elem()
+
+	for key, values := range src {
+		name, ok := strings.CutPrefix(key, prefix+".")
+		if !ok || name == "" {
+			continue
+		}
+		delete(src, key)
+
+		var elem reflect.Value
+		var err error
+		if elemType.Kind() == reflect.Slice {
+			elem = reflect.MakeSlice(elemType, 0, len(values))
+			for _, value := range values {
+				var item reflect.Value
+				if item, err = convertString(value, elemType.Elem()); err != nil {
+					break
+				}
+				elem = reflect.Append(elem, item)
+			}
+		} else if len(values) > 0 {
+			elem, err = convertString(values[0], elemType)
+		} else {
+			elem = reflect.Zero(elemType)
+		}
+
+		if err != nil {
+			errs[key] = schema.ConversionError{Key: key, Type: elemType, Index: -1, Err: err}
+			continue
+		}
+
+		if fv.IsNil() {
+			fv.Set(reflect.MakeMap(mapType))
+		}
+		fv.SetMapIndex(reflect.ValueOf(name).Convert(mapType.Key()), elem)
+	}
+}
+
+// convertString parses value into a new value of type t, which must have a basic kind
+func convertString(value string, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return v, err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetFloat(f)
+	default:
+		return v, fmt.Errorf("unsupported type %s", t)
+	}
+
+	return v, nil
+}
+
+// defaultsChecker is implemented by the extractors that honor `default` struct tags
+type defaultsChecker interface {
+	checkDefaults()
+}
+
+// fieldDefault is a top-level struct field with a `default` tag
+type fieldDefault struct {
+	index int
+	key   string
+	value string
+}
+
+// defaultsCache holds the []fieldDefault of each struct type seen by structDefaults
+var defaultsCache sync.Map
+
+// structDefaults lists the fields of the struct t (or *t) with a `default` tag,
+// keyed by their `schema` names. It panics if a default does not parse as its field's type.
+// Slice fields are skipped, as the schema decoder fills them from "a|b" defaults itself
+func structDefaults(t reflect.Type) []fieldDefault {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if cached, ok := defaultsCache.Load(t); ok {
+		return cached.([]fieldDefault)
 	}
 
-	if len(ve) == 0 {
-		return "validation failed"
+	var defaults []fieldDefault
+	if t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			value, ok := field.Tag.Lookup("default")
+			if !ok || !field.IsExported() || field.Type.Kind() == reflect.Slice {
+				continue
+			}
+			key, _, _ := strings.Cut(field.Tag.Get("schema"), ",")
+			if key == "-" {
+				continue
+			}
+			if key == "" {
+				key = field.Name
+			}
+			if _, err := parseDefault(value, field.Type); err != nil {
+				log.Panicf("H: invalid default %q for field %s of %s: %v", value, field.Name, t, err)
+			}
+			defaults = append(defaults, fieldDefault{index: i, key: key, value: value})
+		}
 	}
 
-	messages := make([]string, 0, len(ve))
-	for _, fe := range ve {
-		field := fe.Field()
-		if field == "" {
-			field = fe.StructField()
+	defaultsCache.Store(t, defaults)
+	return defaults
+}
+
+// parseDefault converts a default value to t the way Path converts its values
+func parseDefault(value string, t reflect.Type) (reflect.Value, error) {
+	if t.Kind() == reflect.Ptr {
+		v, err := parseDefault(value, t.Elem())
+		if err != nil {
+			return v, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(v)
+		return ptr, nil
+	}
+	if convert := pathConverter(t); convert != nil {
+		val, err := convert(value)
+		if err != nil {
+			return reflect.Value{}, err
 		}
+		return reflect.ValueOf(val), nil
+	}
+	return convertString(value, t)
+}
 
-		msg := formatFieldError(field, fe)
-		messages = append(messages, msg)
+// applyDefaults sets the fields of target with a `default` tag to their default
+// when src has no value for them, or only an empty one. The schema decoder fills
+// any zero field from the tag as well, so a zero the client did send, such as
+// "0" or "false", is put back
+func applyDefaults(target any, src map[string][]string) {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return
 	}
+	v = v.Elem()
 
-	return strings.Join(messages, "; ")
+	for _, d := range structDefaults(v.Type()) {
+		field := v.Field(d.index)
+		if sent := src[d.key]; len(sent) > 0 && sent[0] != "" {
+			if parsed, err := parseDefault(sent[0], field.Type()); err == nil && parsed.IsZero() {
+				field.SetZero()
+			}
+			continue
+		}
+		if field.IsZero() {
+			if val, err := parseDefault(d.value, field.Type()); err == nil {
+				field.Set(val)
+			}
+		}
+	}
 }
 
-// formatFieldError formats a single field validation error
-func formatFieldError(field string, fe validator.FieldError) string {
-	switch fe.Tag() {
-	case "required":
-		return fmt.Sprintf("%s is required", field)
-	case "email":
-		return fmt.Sprintf("%s must be a valid email address", field)
-	case "min":
-		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
-	case "max":
-		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
-	case "len":
-		return fmt.Sprintf("%s must be %s characters long", field, fe.Param())
-	case "gt":
-		return fmt.Sprintf("%s must be greater than %s", field, fe.Param())
-	case "gte":
-		return fmt.Sprintf("%s must be greater than or equal to %s", field, fe.Param())
-	case "lt":
-		return fmt.Sprintf("%s must be less than %s", field, fe.Param())
-	case "lte":
-		return fmt.Sprintf("%s must be less than or equal to %s", field, fe.Param())
-	case "oneof":
-		return fmt.Sprintf("%s must be one of [%s]", field, fe.Param())
-	case "url":
-		return fmt.Sprintf("%s must be a valid URL", field)
-	case "uri":
-		return fmt.Sprintf("%s must be a valid URI", field)
-	case "alpha":
-		return fmt.Sprintf("%s must contain only letters", field)
-	case "alphanum":
-		return fmt.Sprintf("%s must contain only letters and numbers", field)
-	case "numeric":
-		return fmt.Sprintf("%s must be numeric", field)
-	case "uuid":
-		return fmt.Sprintf("%s must be a valid UUID", field)
-	default:
-		return fmt.Sprintf("%s failed validation (%s)", field, fe.Tag())
+// parseTime parses value with the first layout that accepts it
+func parseTime(value string, layouts []string) (time.Time, error) {
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
 	}
+	return time.Time{}, err
 }
 
 func getPointer(val reflect.Value) any {
@@ -797,6 +2622,16 @@ func isNilValue(v reflect.Value) bool {
 	}
 }
 
+// encodesAsNull reports whether v is a nil data pointer that Config.NilPointerAsNull
+// sends as null. Nil errors, handlers and readers still mean "no response"
+func encodesAsNull(v reflect.Value) bool {
+	if !global.get().NilPointerAsNull || v.Kind() != reflect.Ptr || !v.IsNil() {
+		return false
+	}
+	t := v.Type()
+	return !t.Implements(errorType) && !t.Implements(handlerType) && !t.Implements(readerType)
+}
+
 func handleOneResult(w http.ResponseWriter, data any) error {
 	switch v := data.(type) {
 	case resultMarker:
@@ -820,6 +2655,19 @@ func handleCommonTypes(w http.ResponseWriter, data any) error {
 		return nil
 	}
 
+	if rv := reflect.ValueOf(data); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		if !encodesAsNull(rv) {
+			return nil
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		return jsonEncode(w, transformResponse(w, data))
+	}
+
+	if sc, ok := data.(statusCarrier); ok {
+		code, inner := sc.status()
+		return handleResult(w, Result[any]{Code: code, Data: inner})
+	}
+
 	if responder, ok := data.(Responder); ok {
 		responder.Respond(w)
 		return nil
@@ -831,8 +2679,21 @@ func handleCommonTypes(w http.ResponseWriter, data any) error {
 		_, err := fmt.Fprint(w, v)
 		return err
 	case StatusCode:
+		if v == NoResponse {
+			return nil
+		}
+		if !bodyAllowed(int(v)) {
+			w.Header().Del("Content-Type")
+		}
 		w.WriteHeader(int(v))
 		return nil
+	case RawJSON:
+		if global.get().ValidateResponses && !json.Valid(v) {
+			logger().Printf("Warning: RawJSON response is not valid JSON")
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, err := w.Write(v)
+		return err
 	case []byte:
 		w.Header().Set("Content-Type", "application/octet-stream")
 		_, err := w.Write(v)
@@ -844,10 +2705,148 @@ func handleCommonTypes(w http.ResponseWriter, data any) error {
 	case io.Reader:
 		_, err := io.Copy(w, v)
 		return err
+	case OrderedMap, *OrderedMap:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		return writeJSON(w, transformResponse(w, v))
 	default:
+		if rv := reflect.ValueOf(data); rv.Kind() == reflect.Slice && rv.Type().Elem().Implements(resultMarkerType) {
+			data = batchResults(rv)
+		}
+		validateResponse(data)
+		if mediaType := negotiateXML(w); mediaType != "" {
+			return writeXML(w, mediaType, transformResponse(w, data))
+		}
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		return writeJSON(w, transformResponse(w, data))
+	}
+}
+
+// xmlOffers are the media types offered when XML responses are enabled, JSON first
+// so it wins ties and requests without an Accept header
+var xmlOffers = []string{"application/json", "application/xml", "text/xml"}
+
+// negotiateXML returns the XML media type to respond with if Config.XMLMarshalFunc
+// is set and the request prefers XML to JSON, or "" to respond with JSON
+func negotiateXML(w http.ResponseWriter) string {
+	if global.get().XMLMarshalFunc == nil {
+		return ""
+	}
+	r := requestOf(w)
+	if r == nil {
+		return ""
+	}
+
+	w.Header().Add("Vary", "Accept")
+	if mediaType := BestMatch(r.Header.Get("Accept"), xmlOffers); mediaType != "application/json" {
+		return mediaType
+	}
+	return ""
+}
+
+// writeXML encodes an XML response body with Config.XMLMarshalFunc
+func writeXML(w http.ResponseWriter, mediaType string, data any) error {
+	body, err := global.get().XMLMarshalFunc(data)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+// writeJSON encodes a JSON response body, choosing between streaming and a
+// buffered write with Content-Length according to Config.JSONStreamThreshold
+func writeJSON(w http.ResponseWriter, data any) error {
+	threshold := global.get().JSONStreamThreshold
+	if threshold <= 0 {
 		return jsonEncode(w, data)
 	}
+
+	if rv := reflect.ValueOf(data); (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Len() > threshold {
+		JSONStream[any]{Items: func(yield func(any) bool) {
+			for i := range rv.Len() {
+				if !yield(rv.Index(i).Interface()) {
+					return
+				}
+			}
+		}}.Respond(w)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := jsonEncode(&buf, data); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// transformResponse applies Config.ProjectResponse and then Config.ResponseTransform
+// to data, if set. The status is the one already written to w, or 200
+func transformResponse(w http.ResponseWriter, data any) any {
+	if project := global.get().ProjectResponse; project != nil {
+		if r := requestOf(w); r != nil {
+			data = project(r, data)
+		}
+	}
+
+	transform := global.get().ResponseTransform
+	if transform == nil {
+		return data
+	}
+
+	status := http.StatusOK
+	switch rw := w.(type) {
+	case *deferredStatus:
+		status = rw.code
+	case *ResponseWriter:
+		if rw.headerWritten {
+			status = rw.statusCode
+		} else if rw.pendingStatus != 0 {
+			status = rw.pendingStatus
+		}
+	}
+	return transform(status, data)
+}
+
+// requestOf returns the request w answers, if w is, or wraps, the ResponseWriter of H
+func requestOf(w http.ResponseWriter) *http.Request {
+	for {
+		switch v := w.(type) {
+		case *ResponseWriter:
+			return v.request
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// batchResults flattens a slice of Result values into a JSON array.
+// Successful elements are encoded as their data, failed ones as their HTTPError envelope,
+// whose code is taken from Result.Code when set.
+// The response itself is always 200; errors are only embedded per element
+func batchResults(rv reflect.Value) []any {
+	items := make([]any, rv.Len())
+	for i := range items {
+		result := rv.Index(i).Interface().(resultMarker).toResult()
+		if result.Err == nil || result.errBody {
+			items[i] = result.Data
+			continue
+		}
+
+		envelope := *ToHTTPError(result.Err)
+		if result.Code != 0 {
+			envelope.Code = result.Code
+		}
+		items[i] = envelope
+	}
+	return items
 }
 
 func handleResult(w http.ResponseWriter, result Result[any]) error {
@@ -855,18 +2854,115 @@ func handleResult(w http.ResponseWriter, result Result[any]) error {
 		WriteHeaders(w, result.Headers)
 	}
 
-	if result.Code != 0 {
+	if !bodyAllowed(result.Code) {
+		// The status cannot carry an error, so the error is sent with its own
+		if result.Err != nil {
+			return handleError(w, result.Err)
+		}
+		w.Header().Del("Content-Type")
 		w.WriteHeader(result.Code)
+		return nil
+	}
+
+	if result.Err != nil && result.errBody {
+		httpErr := *ToHTTPError(result.Err)
+		if result.Code == 0 {
+			result.Code = httpErr.Code
+		}
+		httpErr.Code = result.Code
+		writeErrorHeaders(w, result.Err, &httpErr)
+		logServerError(w, &httpErr)
+		result.Err = nil
+	}
+
+	if result.Code == 0 {
+		if result.Err != nil {
+			return handleError(w, result.Err)
+		}
+		return handleCommonTypes(w, result.Data)
 	}
 
+	ds := &deferredStatus{ResponseWriter: w, code: result.Code}
+	defer ds.flushStatus()
+
 	if result.Err != nil {
-		return handleError(w, result.Err)
+		return handleError(ds, result.Err)
+	}
+	return handleCommonTypes(ds, result.Data)
+}
+
+// Status pairs response data with the status code to send it with.
+// It is rendered like the data itself, so unlike Result it can be the
+// first of two return values
+type Status[T any] struct {
+	Code int
+	Data T
+}
+
+// WithStatus returns data to be sent with the given status code
+func WithStatus[T any](code int, data T) Status[T] {
+	return Status[T]{Code: code, Data: data}
+}
+
+func (s Status[T]) status() (int, any) {
+	return s.Code, s.Data
+}
+
+// statusCarrier is implemented by Status, whatever its type parameter
+type statusCarrier interface {
+	status() (int, any)
+}
+
+// deferredStatus holds back a status code until the body is first written,
+// so headers set while rendering the body, such as Content-Type, are still sent.
+// The status is fixed: a later WriteHeader with another code sends this one
+type deferredStatus struct {
+	http.ResponseWriter
+	code    int
+	written bool
+}
+
+func (ds *deferredStatus) WriteHeader(int) {
+	ds.flushStatus()
+}
+
+func (ds *deferredStatus) Write(b []byte) (int, error) {
+	ds.flushStatus()
+	return ds.ResponseWriter.Write(b)
+}
+
+func (ds *deferredStatus) Flush() {
+	ds.flushStatus()
+	if err := http.NewResponseController(ds.ResponseWriter).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logger().Printf("failed to flush response: %v", err)
+	}
+}
+
+func (ds *deferredStatus) Unwrap() http.ResponseWriter {
+	return ds.ResponseWriter
+}
+
+// flushStatus writes the held status code, if it has not been written yet
+func (ds *deferredStatus) flushStatus() {
+	if !ds.written {
+		ds.written = true
+		ds.ResponseWriter.WriteHeader(ds.code)
 	}
+}
 
-	return handleCommonTypes(w, result.Data)
+// bodyAllowed reports whether a response with the given status may carry a body.
+// 204 No Content and 304 Not Modified must not, per RFC 9110
+func bodyAllowed(code int) bool {
+	return code != http.StatusNoContent && code != http.StatusNotModified
 }
 
 func handleError(w http.ResponseWriter, err error) error {
+	// An error that renders itself takes precedence over the error handler
+	if responder, ok := err.(Responder); ok {
+		responder.Respond(w)
+		return nil
+	}
+
 	if errorHandler() != nil {
 		errorHandler()(w, err)
 		return nil
@@ -877,29 +2973,130 @@ func handleError(w http.ResponseWriter, err error) error {
 		statusWritten = rw.headerWritten
 	}
 
-	httpErr := toHTTPError(err)
+	httpErr := ToHTTPError(err)
 	if httpErr == nil {
 		return nil
 	}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	page := renderErrorPage(w, httpErr)
+
+	if page != nil {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	writeErrorHeaders(w, err, httpErr)
+	logServerError(w, httpErr)
+
+	if encode := errorEncoder(); encode != nil && page == nil {
+		if !statusWritten {
+			ds := &deferredStatus{ResponseWriter: w, code: httpErr.Code}
+			defer ds.flushStatus()
+			w = ds
+		}
+		return encode(w, httpErr)
+	}
 
 	if !statusWritten {
 		w.WriteHeader(httpErr.Code)
 	}
 
-	if httpErr.Code >= 500 {
-		log.Println(httpErr.Error())
+	if page != nil {
+		_, err := w.Write(page)
+		return err
 	}
-
 	return jsonEncode(w, httpErr)
 }
 
-func toHTTPError(err error) *HTTPError {
+// renderErrorPage renders Config.ErrorTemplate for httpErr if the client prefers
+// HTML to JSON. It returns nil to send JSON instead, also when rendering fails
+func renderErrorPage(w http.ResponseWriter, httpErr *HTTPError) []byte {
+	tmpl := global.get().ErrorTemplate
+	if tmpl == nil {
+		return nil
+	}
+	r := requestOf(w)
+	if r == nil {
+		return nil
+	}
+
+	w.Header().Add("Vary", "Accept")
+	if BestMatch(r.Header.Get("Accept"), []string{"application/json", "text/html"}) != "text/html" {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, httpErr); err != nil {
+		logger().Printf("failed to render error template: %v", err)
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// writeErrorHeaders sets the headers that accompany an error response
+func writeErrorHeaders(w http.ResponseWriter, err error, httpErr *HTTPError) {
+	if httpErr.Code == http.StatusUnauthorized {
+		setAuthChallenge(w, err)
+	}
+	if httpErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", retryAfterSeconds(httpErr.RetryAfter))
+	}
+	if global.get().GRPCWebErrors {
+		writeGRPCWebStatus(w, httpErr)
+	}
+}
+
+// logServerError logs 5xx errors, prefixed with the request ID and the name of
+// the handler if there are any
+func logServerError(w http.ResponseWriter, httpErr *HTTPError) {
+	if httpErr.Code < 500 {
+		return
+	}
+	msg := httpErr.Error()
+	if rw, ok := w.(*ResponseWriter); ok && rw.handlerName != "" {
+		msg = rw.handlerName + ": " + msg
+	}
+	if id := w.Header().Get(RequestIDHeader); id != "" {
+		logger().Printf("[%s] %s", id, msg)
+	} else {
+		logger().Print(msg)
+	}
+}
+
+// retryAfterSeconds formats d for the Retry-After header, rounding up to whole seconds
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
+
+// setAuthChallenge adds the WWW-Authenticate header required on 401 responses
+// for AuthHeader failures, naming the accepted schemes, unless one is already set
+func setAuthChallenge(w http.ResponseWriter, err error) {
+	var extractErr *ExtractError
+	if !errors.As(err, &extractErr) || extractErr.Type != ErrTypeAuthScheme || w.Header().Get("WWW-Authenticate") != "" {
+		return
+	}
+	for _, scheme := range authSchemes() {
+		w.Header().Add("WWW-Authenticate", scheme)
+	}
+}
+
+// ToHTTPError converts any error into the HTTPError mint would respond with.
+// Custom error handlers can use it to reuse the built-in mapping and then adjust the result.
+// It returns nil for a nil error
+func ToHTTPError(err error) *HTTPError {
 	if err == nil {
 		return nil
 	}
 
+	// A panic never exposes its value to the client, even if it is an HTTPError
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		return &HTTPError{
+			Code: 500,
+			Err:  "internal_error",
+		}
+	}
+
 	var httpErr *HTTPError
 	if errors.As(err, &httpErr) {
 		return httpErr
@@ -912,6 +3109,12 @@ func toHTTPError(err error) *HTTPError {
 
 	var extractErr *ExtractError
 	if errors.As(err, &extractErr) {
+		if mapper := global.get().ExtractErrorMapper; mapper != nil {
+			if httpErr := mapper(extractErr); httpErr != nil {
+				return httpErr
+			}
+		}
+
 		switch extractErr.Type {
 		case ErrTypeBodyRead:
 			return &HTTPError{
@@ -943,6 +3146,102 @@ func toHTTPError(err error) *HTTPError {
 				Err:     "missing_path_parameter",
 				Message: extractErr.Message,
 			}
+		case ErrTypeNDJSONLine:
+			return &HTTPError{
+				Code:    400,
+				Err:     "invalid_ndjson",
+				Message: extractErr.Message,
+			}
+		case ErrTypeMediaType:
+			return &HTTPError{
+				Code:    415,
+				Err:     "unsupported_media_type",
+				Message: extractErr.Message,
+			}
+		case ErrTypeBodyTimeout:
+			return &HTTPError{
+				Code:    408,
+				Err:     "request_timeout",
+				Message: extractErr.Message,
+			}
+		case ErrTypeBodyTooLarge:
+			return &HTTPError{
+				Code:    413,
+				Err:     "payload_too_large",
+				Message: extractErr.Message,
+			}
+		case ErrTypeTooManyFields:
+			return &HTTPError{
+				Code:    400,
+				Err:     "too_many_fields",
+				Message: extractErr.Message,
+			}
+		case ErrTypeQueryTooLong:
+			return &HTTPError{
+				Code:    414,
+				Err:     "uri_too_long",
+				Message: extractErr.Message,
+			}
+		case ErrTypeEncoding:
+			return &HTTPError{
+				Code:    400,
+				Err:     "invalid_encoding",
+				Message: extractErr.Message,
+			}
+		case ErrTypeAuthScheme:
+			return &HTTPError{
+				Code:    401,
+				Err:     "unauthorized",
+				Message: extractErr.Message,
+			}
+		case ErrTypeHeader:
+			return &HTTPError{
+				Code:    400,
+				Err:     "invalid_header",
+				Message: extractErr.Message,
+			}
+		case ErrTypeCookie:
+			return &HTTPError{
+				Code:    400,
+				Err:     "invalid_cookie",
+				Message: extractErr.Message,
+			}
+		case ErrTypeMissingCookie:
+			return &HTTPError{
+				Code:    400,
+				Err:     "missing_cookie",
+				Message: extractErr.Message,
+			}
+		case ErrTypeMultipart:
+			return &HTTPError{
+				Code:    400,
+				Err:     "invalid_multipart",
+				Message: extractErr.Message,
+			}
+		case ErrTypeMissingFile:
+			return &HTTPError{
+				Code:    400,
+				Err:     "missing_file",
+				Message: extractErr.Message,
+			}
+		case ErrTypeXMLSyntax:
+			return &HTTPError{
+				Code:    400,
+				Err:     "invalid_xml_syntax",
+				Message: extractErr.Message,
+			}
+		case ErrTypeXMLType:
+			return &HTTPError{
+				Code:    400,
+				Err:     "invalid_xml_type",
+				Message: extractErr.Message,
+			}
+		case ErrTypeVersion:
+			return &HTTPError{
+				Code:    406,
+				Err:     "not_acceptable",
+				Message: extractErr.Message,
+			}
 		case ErrTypeValidation:
 			return &HTTPError{
 				Code:    400,
@@ -960,10 +3259,14 @@ func toHTTPError(err error) *HTTPError {
 
 	switch e := err.(type) {
 	case *json.UnmarshalTypeError:
+		message := fmt.Sprintf("field %q expects %s but got %s", e.Field, e.Type.String(), e.Value)
+		if e.Field == "" {
+			message = fmt.Sprintf("expected %s but got %s", e.Type.String(), e.Value)
+		}
 		return &HTTPError{
 			Code:    400,
 			Err:     "invalid_json_type",
-			Message: fmt.Sprintf("field %q expects %s but got %s", e.Field, e.Type.String(), e.Value),
+			Message: message,
 		}
 	case *json.SyntaxError:
 		return &HTTPError{
@@ -973,14 +3276,10 @@ func toHTTPError(err error) *HTTPError {
 		}
 
 	case schema.MultiError:
-		messages := make([]string, 0, len(e))
-		for field, fieldErr := range e {
-			messages = append(messages, fmt.Sprintf("%s: %s", field, fieldErr.Error()))
-		}
 		return &HTTPError{
 			Code:    400,
 			Err:     "validation_failed",
-			Message: strings.Join(messages, "; "),
+			Message: schemaErrorsMessage(e),
 		}
 	case *schema.ConversionError:
 		return &HTTPError{
@@ -996,8 +3295,15 @@ func toHTTPError(err error) *HTTPError {
 		}
 
 	default:
-		errMsg := err.Error()
-		code := inferStatusCode(errMsg)
+		var code int
+		var sc statusCoder
+		if errors.As(err, &sc) && sc.StatusCode() >= 400 && sc.StatusCode() < 600 {
+			code = sc.StatusCode()
+		} else if inferrer := statusInferrer(); inferrer != nil {
+			code = inferrer(err)
+		} else {
+			code = inferStatusCode(err.Error())
+		}
 		return &HTTPError{
 			Code: code,
 			Err:  inferErrorType(code),
@@ -1005,6 +3311,38 @@ func toHTTPError(err error) *HTTPError {
 	}
 }
 
+// statusCoder is implemented by errors that carry their own HTTP status
+type statusCoder interface {
+	StatusCode() int
+}
+
+// schemaErrorsMessage joins the messages for all errors, ordered by field
+func schemaErrorsMessage(errs schema.MultiError) string {
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, 0, len(errs))
+	for _, field := range fields {
+		messages = append(messages, schemaErrorMessage(field, errs[field]))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// schemaErrorMessage describes a single schema decoding error for the given field
+func schemaErrorMessage(field string, err error) string {
+	switch err.(type) {
+	case schema.ConversionError, *schema.ConversionError:
+		return fmt.Sprintf("%s: invalid value", field)
+	case schema.EmptyFieldError, *schema.EmptyFieldError:
+		return fmt.Sprintf("%s is required", field)
+	default:
+		return fmt.Sprintf("%s: %s", field, err.Error())
+	}
+}
+
 func inferStatusCode(msg string) int {
 	lower := strings.ToLower(msg)
 	switch {
@@ -1036,10 +3374,30 @@ func inferErrorType(code int) string {
 	case 408:
 		return "timeout"
 	default:
+		// Other client errors are named after their status text, e.g. 409 → "conflict"
+		if code >= 400 && code < 500 {
+			if text := http.StatusText(code); text != "" {
+				return strings.Map(func(r rune) rune {
+					switch {
+					case r == ' ' || r == '-':
+						return '_'
+					case r >= 'A' && r <= 'Z':
+						return r + ('a' - 'A')
+					case r >= 'a' && r <= 'z':
+						return r
+					default:
+						return -1
+					}
+				}, text)
+			}
+		}
 		return "internal_error"
 	}
 }
 
+// extractPatternNames returns the wildcard names of a ServeMux pattern in order.
+// Duplicate names need no check here: ServeMux rejects such patterns when they
+// are registered, panicking in Handle and HandleFunc
 func extractPatternNames(pattern string) []string {
 	var names []string
 	inParam := false
@@ -1061,7 +3419,9 @@ func extractPatternNames(pattern string) []string {
 			}
 			inParam = false
 			depth--
-			if currentName != "" {
+			// "{name...}" is a wildcard named name, and "{$}" matches the end of the path only
+			currentName = strings.TrimSuffix(currentName, "...")
+			if currentName != "" && currentName != "$" {
 				names = append(names, currentName)
 			}
 		} else if inParam {
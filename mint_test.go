@@ -2,20 +2,24 @@ package m
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/schema"
 )
 
@@ -111,6 +115,119 @@ func TestJSONExtractor(t *testing.T) {
 			t.Errorf("expected Name=Bob, got %v", j.Value.Name)
 		}
 	})
+
+	t.Run("raw body", func(t *testing.T) {
+		body := []byte(`{ "name": "Alice",  "email": "alice@example.com", "age": 25 }`)
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+
+		var j JSON[signedEvent]
+		if err := j.Extract(req); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		if !bytes.Equal(j.Raw(), body) {
+			t.Errorf("expected Raw to return %q, got %q", body, j.Raw())
+		}
+		if !bytes.Equal(j.Value.payload, body) || j.Value.Name != "Alice" {
+			t.Errorf("unexpected value %+v", j.Value)
+		}
+	})
+
+	t.Run("raw body in a handler", func(t *testing.T) {
+		Reset()
+		body := `{"name":"Alice","email":"alice@example.com","age":25}`
+		handler := H(func(event JSON[User]) string { return string(event.Raw()) })
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		handler(rec, req)
+		if rec.Code != 200 || rec.Body.String() != body {
+			t.Errorf("unexpected response %d %q", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+// signedEvent keeps the bytes it was decoded from, like a webhook payload
+// type whose UnmarshalJSON needs them for a signature check
+type signedEvent struct {
+	User
+	payload []byte
+}
+
+func (e *signedEvent) UnmarshalJSON(data []byte) error {
+	e.payload = append([]byte(nil), data...)
+	return json.Unmarshal(data, &e.User)
+}
+
+func panickingHandler() string {
+	panic("boom")
+}
+
+// ========== NDJSON Extractor Tests ==========
+
+func TestNDJSONExtractor(t *testing.T) {
+	t.Run("multi-line valid input", func(t *testing.T) {
+		body := "{\"name\":\"Alice\",\"age\":25}\n\n{\"name\":\"Bob\",\"age\":30}\n{\"name\":\"Carol\"}"
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+
+		var n NDJSON[User]
+		if err := n.Extract(req); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		if len(n.Value) != 3 {
+			t.Fatalf("expected 3 items, got %d", len(n.Value))
+		}
+		if n.Value[0].Name != "Alice" || n.Value[1].Age != 30 || n.Value[2].Name != "Carol" {
+			t.Errorf("unexpected items: %+v", n.Value)
+		}
+	})
+
+	t.Run("malformed middle line", func(t *testing.T) {
+		body := "{\"name\":\"Alice\"}\n{\"name\":\n{\"name\":\"Carol\"}\n"
+		handler := H(func(n NDJSON[User]) int {
+			return len(n.Value)
+		})
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		handler(rec, req)
+		if rec.Code != 400 {
+			t.Errorf("expected status 400, got %d", rec.Code)
+		}
+		var httpErr HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+		if httpErr.Err != "invalid_ndjson" || !strings.Contains(httpErr.Message, "line 2") {
+			t.Errorf("unexpected error: %+v", httpErr)
+		}
+	})
+
+	t.Run("validates each element", func(t *testing.T) {
+		requireValidator(t)
+		Reset()
+		type Item struct {
+			Name string `json:"name" validate:"required"`
+		}
+		body := "{\"name\":\"a\"}\n{\"name\":\"\"}\n"
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+
+		var n NDJSON[Item]
+		err := n.Extract(req)
+		var extractErr *ExtractError
+		if !errors.As(err, &extractErr) || extractErr.Type != ErrTypeValidation {
+			t.Fatalf("expected validation error, got %v", err)
+		}
+		if !strings.Contains(extractErr.Message, "line 2") {
+			t.Errorf("expected line number in message, got %s", extractErr.Message)
+		}
+	})
+
+	t.Run("empty body", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader("\n\n"))
+		var n NDJSON[User]
+		var extractErr *ExtractError
+		if err := n.Extract(req); !errors.As(err, &extractErr) || extractErr.Type != ErrTypeEmptyBody {
+			t.Errorf("expected EmptyBodyError, got %v", err)
+		}
+	})
 }
 
 // ========== Query Extractor Tests ==========
@@ -154,6 +271,116 @@ func TestQueryExtractor(t *testing.T) {
 			t.Fatal("expected error for invalid type")
 		}
 	})
+
+	t.Run("slice values", func(t *testing.T) {
+		type filter struct {
+			Tags []string `schema:"tag"`
+			IDs  []int    `schema:"id"`
+			Q    string   `schema:"q"`
+		}
+		extract := func(query string) filter {
+			t.Helper()
+			var q Query[filter]
+			if err := q.Extract(httptest.NewRequest("GET", "/?"+query, nil)); err != nil {
+				t.Fatalf("Extract(%q) failed: %v", query, err)
+			}
+			return q.Value
+		}
+
+		Reset()
+		if got := extract("tag=a&tag=b&tag=c"); !slices.Equal(got.Tags, []string{"a", "b", "c"}) {
+			t.Errorf("expected repeated keys to populate the slice, got %q", got.Tags)
+		}
+		if got := extract("tag=a,b"); !slices.Equal(got.Tags, []string{"a,b"}) {
+			t.Errorf("expected no splitting without a separator, got %q", got.Tags)
+		}
+
+		Configure(WithQuerySliceSeparator(","))
+		defer Reset()
+
+		tests := []struct {
+			query string
+			tags  []string
+		}{
+			{"tag=a,b,c", []string{"a", "b", "c"}},
+			{"tag=a,b&tag=c", []string{"a", "b", "c"}},
+			{"tag=a,,b,", []string{"a", "b"}},
+			{"tag=", nil},
+			{"tag=,", nil},
+			{"", nil},
+		}
+		for _, tt := range tests {
+			if got := extract(tt.query); !slices.Equal(got.Tags, tt.tags) {
+				t.Errorf("%q: expected %q, got %q", tt.query, tt.tags, got.Tags)
+			}
+		}
+
+		got := extract("id=1,2&q=hello,world")
+		if !slices.Equal(got.IDs, []int{1, 2}) {
+			t.Errorf("expected split ints, got %v", got.IDs)
+		}
+		if got.Q != "hello,world" {
+			t.Errorf("expected non-slice fields to be left alone, got %q", got.Q)
+		}
+	})
+
+	t.Run("query over max length", func(t *testing.T) {
+		Reset()
+		Configure(WithMaxQueryLength(20))
+		defer Reset()
+
+		handler := H(func(q Query[QueryParams]) QueryParams { return q.Value })
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/?sort="+strings.Repeat("a", 30), nil))
+		if rec.Code != http.StatusRequestURITooLong {
+			t.Fatalf("expected status 414, got %d", rec.Code)
+		}
+		var httpErr HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+		if httpErr.Err != "uri_too_long" {
+			t.Errorf("expected Err=uri_too_long, got %s", httpErr.Err)
+		}
+
+		rec = httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/?page=2&limit=10", nil))
+		if rec.Code != 200 {
+			t.Errorf("expected status 200 within the limit, got %d", rec.Code)
+		}
+	})
+
+	t.Run("bracket notation maps", func(t *testing.T) {
+		type Filter struct {
+			Meta   map[string]string `schema:"meta"`
+			Counts map[string]int    `schema:"counts"`
+		}
+
+		req := httptest.NewRequest("GET", "/?meta[color]=red&meta%5Bsize%5D=large&counts[a]=1&counts.b=2", nil)
+		var q Query[Filter]
+		if err := q.Extract(req); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		if !reflect.DeepEqual(q.Value.Meta, map[string]string{"color": "red", "size": "large"}) {
+			t.Errorf("unexpected meta: %v", q.Value.Meta)
+		}
+		if !reflect.DeepEqual(q.Value.Counts, map[string]int{"a": 1, "b": 2}) {
+			t.Errorf("unexpected counts: %v", q.Value.Counts)
+		}
+	})
+
+	t.Run("bracket notation map conversion error", func(t *testing.T) {
+		type Filter struct {
+			Counts map[string]int `schema:"counts"`
+		}
+
+		req := httptest.NewRequest("GET", "/?counts[a]=many", nil)
+		var q Query[Filter]
+		err := q.Extract(req)
+		httpErr := ToHTTPError(err)
+		if httpErr.Code != 400 || httpErr.Message != "counts[a]: invalid value" {
+			t.Errorf("unexpected error: %+v", httpErr)
+		}
+	})
 }
 
 // ========== Form Extractor Tests ==========
@@ -189,6 +416,488 @@ func TestFormExtractor(t *testing.T) {
 			t.Fatalf("Extract failed: %v", err)
 		}
 	})
+
+	t.Run("too many fields", func(t *testing.T) {
+		Reset()
+		Configure(WithMaxFormFields(2))
+		defer Reset()
+
+		handler := H(func(f Form[FormData]) string { return f.Value.Username })
+
+		rec := httptest.NewRecorder()
+		handler(rec, postForm("/", url.Values{"username": {"john"}, "password": {"secret"}}))
+		if rec.Code != 200 {
+			t.Errorf("expected 200 at the limit, got %d", rec.Code)
+		}
+
+		rec = httptest.NewRecorder()
+		handler(rec, postForm("/?extra=1", url.Values{"username": {"john"}, "password": {"secret"}}))
+		if rec.Code != 400 {
+			t.Fatalf("expected 400 over the limit, got %d", rec.Code)
+		}
+		var body map[string]any
+		parseJSONResponse(t, rec.Body.Bytes(), &body)
+		if body["error"] != "too_many_fields" {
+			t.Errorf("expected too_many_fields, got %v", body)
+		}
+	})
+}
+
+// ========== Default Value Tests ==========
+
+type pageParams struct {
+	Limit   int           `schema:"limit" default:"10"`
+	Sort    string        `schema:"sort" default:"name"`
+	Desc    bool          `schema:"desc" default:"true"`
+	Ratio   float64       `schema:"ratio" default:"0.5"`
+	Timeout time.Duration `schema:"timeout" default:"30s"`
+	Offset  *int          `schema:"offset" default:"5"`
+	Cursor  string        `schema:"cursor"`
+}
+
+func TestDefaultTags(t *testing.T) {
+	extract := func(query string) pageParams {
+		t.Helper()
+		var q Query[pageParams]
+		if err := q.Extract(httptest.NewRequest("GET", "/?"+query, nil)); err != nil {
+			t.Fatalf("Extract(%q) failed: %v", query, err)
+		}
+		return q.Value
+	}
+
+	t.Run("absent params take their defaults", func(t *testing.T) {
+		Reset()
+		got := extract("")
+		if got.Limit != 10 || got.Sort != "name" || !got.Desc || got.Ratio != 0.5 || got.Timeout != 30*time.Second {
+			t.Errorf("unexpected defaults %+v", got)
+		}
+		if got.Offset == nil || *got.Offset != 5 {
+			t.Errorf("expected the pointer default to be set, got %v", got.Offset)
+		}
+		if got.Cursor != "" {
+			t.Errorf("expected a field without default to stay empty, got %q", got.Cursor)
+		}
+	})
+
+	t.Run("sent params win", func(t *testing.T) {
+		Reset()
+		got := extract("limit=25&sort=age&timeout=1m")
+		if got.Limit != 25 || got.Sort != "age" || got.Timeout != time.Minute {
+			t.Errorf("expected the sent values, got %+v", got)
+		}
+	})
+
+	t.Run("explicit zero values are kept", func(t *testing.T) {
+		Reset()
+		got := extract("limit=0&desc=false&ratio=0&offset=0")
+		if got.Limit != 0 || got.Desc || got.Ratio != 0 {
+			t.Errorf("expected explicit zeros, got %+v", got)
+		}
+		if got.Offset == nil || *got.Offset != 0 {
+			t.Errorf("expected an explicit zero offset, got %v", got.Offset)
+		}
+	})
+
+	t.Run("empty values count as absent", func(t *testing.T) {
+		Reset()
+		got := extract("limit=&sort=")
+		if got.Limit != 10 || got.Sort != "name" {
+			t.Errorf("expected defaults for empty values, got %+v", got)
+		}
+	})
+
+	t.Run("form", func(t *testing.T) {
+		Reset()
+		handler := H(func(f Form[pageParams]) pageParams { return f.Value })
+		rec := httptest.NewRecorder()
+		handler(rec, postForm("/", url.Values{"sort": {"email"}}))
+
+		var got pageParams
+		parseJSONResponse(t, rec.Body.Bytes(), &got)
+		if got.Limit != 10 || got.Sort != "email" {
+			t.Errorf("unexpected form values %+v", got)
+		}
+	})
+
+	t.Run("malformed default panics in H", func(t *testing.T) {
+		Reset()
+		type badParams struct {
+			Limit int `schema:"limit" default:"ten"`
+		}
+		defer func() {
+			if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), `invalid default "ten"`) {
+				t.Errorf("expected a panic for the malformed default, got %v", r)
+			}
+		}()
+		H(func(q Query[badParams]) int { return q.Value.Limit })
+	})
+}
+
+// ========== Duration Decoding Tests ==========
+
+func TestDurationDecoding(t *testing.T) {
+	type CacheParams struct {
+		TTL   time.Duration  `schema:"ttl"`
+		Grace *time.Duration `schema:"grace"`
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  time.Duration
+	}{
+		{"ttl=5m", 5 * time.Minute},
+		{"ttl=1h30m", 90 * time.Minute},
+	} {
+		t.Run("query "+tc.query, func(t *testing.T) {
+			Reset()
+			var q Query[CacheParams]
+			if err := q.Extract(httptest.NewRequest("GET", "/?"+tc.query+"&grace=30s", nil)); err != nil {
+				t.Fatalf("Extract failed: %v", err)
+			}
+			if q.Value.TTL != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, q.Value.TTL)
+			}
+			if q.Value.Grace == nil || *q.Value.Grace != 30*time.Second {
+				t.Errorf("unexpected grace: %v", q.Value.Grace)
+			}
+		})
+	}
+
+	t.Run("invalid duration in query", func(t *testing.T) {
+		Reset()
+		handler := H(func(q Query[CacheParams]) CacheParams { return q.Value })
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/?ttl=5+minutes", nil))
+		if rec.Code != 400 {
+			t.Errorf("expected status 400, got %d", rec.Code)
+		}
+		var httpErr HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+		if httpErr.Message != "ttl: invalid value" {
+			t.Errorf("unexpected message: %s", httpErr.Message)
+		}
+	})
+
+	t.Run("form duration", func(t *testing.T) {
+		Reset()
+		var f Form[CacheParams]
+		if err := f.Extract(postForm("/", url.Values{"ttl": {"1h30m"}})); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		if f.Value.TTL != 90*time.Minute {
+			t.Errorf("unexpected ttl: %v", f.Value.TTL)
+		}
+	})
+
+	t.Run("path duration", func(t *testing.T) {
+		Reset()
+		req := createRequestWithPattern("GET", "/sleep/1h30m", "/sleep/{d}")
+		req.SetPathValue("d", "1h30m")
+		p := Path[time.Duration]{Key: "d"}
+		if err := p.Extract(req); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		if p.Value != 90*time.Minute {
+			t.Errorf("unexpected value: %v", p.Value)
+		}
+
+		req.SetPathValue("d", "soon")
+		err := p.Extract(req)
+		var extractErr *ExtractError
+		if !errors.As(err, &extractErr) || extractErr.Type != ErrTypePathConversion {
+			t.Fatalf("expected PathConversionError, got %v", err)
+		}
+		if !strings.Contains(extractErr.Message, "time.Duration") {
+			t.Errorf("unexpected message: %s", extractErr.Message)
+		}
+	})
+
+	t.Run("path time", func(t *testing.T) {
+		Reset()
+		extract := func(value string) (time.Time, error) {
+			req := createRequestWithPattern("GET", "/reports/"+value, "/reports/{day}")
+			req.SetPathValue("day", value)
+			p := Path[time.Time]{Key: "day"}
+			err := p.Extract(req)
+			return p.Value, err
+		}
+
+		day, err := extract("2024-01-15")
+		if err != nil || !day.Equal(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("unexpected date %v (%v)", day, err)
+		}
+		ts, err := extract("2024-01-15T10:30:00Z")
+		if err != nil || !ts.Equal(time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)) {
+			t.Errorf("unexpected timestamp %v (%v)", ts, err)
+		}
+
+		_, err = extract("15.01.2024")
+		var extractErr *ExtractError
+		if !errors.As(err, &extractErr) || extractErr.Type != ErrTypePathConversion {
+			t.Fatalf("expected PathConversionError, got %v", err)
+		}
+		if !strings.Contains(extractErr.Message, "time.Time") {
+			t.Errorf("unexpected message: %s", extractErr.Message)
+		}
+
+		Configure(WithPathTimeLayouts("02.01.2006"))
+		defer Reset()
+		if day, err := extract("15.01.2024"); err != nil || day.Day() != 15 {
+			t.Errorf("expected the custom layout to apply, got %v (%v)", day, err)
+		}
+		if _, err := extract("2024-01-15"); err == nil {
+			t.Error("expected the custom layouts to replace the defaults")
+		}
+	})
+}
+
+// ========== Path Converter Tests ==========
+
+type Priority int
+
+func TestPathConverters(t *testing.T) {
+	t.Run("named type without converter", func(t *testing.T) {
+		Reset()
+		req := createRequestWithPattern("GET", "/tasks/3", "/tasks/{p}")
+		req.SetPathValue("p", "3")
+		p := Path[Priority]{Key: "p"}
+		if err := p.Extract(req); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		if p.Value != 3 {
+			t.Errorf("expected 3, got %d", p.Value)
+		}
+	})
+
+	t.Run("registered converter takes precedence", func(t *testing.T) {
+		Reset()
+		Configure(WithPathConverter(func(s string) (Priority, error) {
+			switch s {
+			case "low":
+				return 1, nil
+			case "high":
+				return 9, nil
+			}
+			return 0, fmt.Errorf("unknown priority %q", s)
+		}))
+		defer Reset()
+
+		handler := H(func(p Path[Priority]) int { return int(p.Value) })
+
+		rec := httptest.NewRecorder()
+		req := createRequestWithPattern("GET", "/tasks/high", "/tasks/{p}")
+		req.SetPathValue("p", "high")
+		handler(rec, req)
+		if rec.Body.String() != "9" {
+			t.Errorf("unexpected body: %q", rec.Body.String())
+		}
+
+		rec = httptest.NewRecorder()
+		req = createRequestWithPattern("GET", "/tasks/3", "/tasks/{p}")
+		req.SetPathValue("p", "3")
+		handler(rec, req)
+		if rec.Code != 400 {
+			t.Errorf("expected status 400, got %d", rec.Code)
+		}
+	})
+
+	t.Run("enum converter", func(t *testing.T) {
+		Reset()
+		Configure(WithPathConverter(EnumConverter(map[string]Priority{"low": 1, "normal": 5, "high": 9})))
+		defer Reset()
+
+		handler := H(func(p Path[Priority]) int { return int(p.Value) })
+
+		for name, expected := range map[string]string{"low": "1", "normal": "5", "high": "9"} {
+			rec := httptest.NewRecorder()
+			req := createRequestWithPattern("GET", "/tasks/"+name, "/tasks/{p}")
+			req.SetPathValue("p", name)
+			handler(rec, req)
+			if rec.Code != 200 || rec.Body.String() != expected {
+				t.Errorf("%s: expected %s, got %d %q", name, expected, rec.Code, rec.Body.String())
+			}
+		}
+
+		for _, name := range []string{"urgent", "5", "LOW"} {
+			rec := httptest.NewRecorder()
+			req := createRequestWithPattern("GET", "/tasks/"+name, "/tasks/{p}")
+			req.SetPathValue("p", name)
+			handler(rec, req)
+			if rec.Code != 400 {
+				t.Errorf("%s: expected status 400, got %d", name, rec.Code)
+			}
+		}
+
+		_, err := EnumConverter(map[string]Priority{"low": 1, "high": 9})("urgent")
+		if err == nil || err.Error() != `unknown value "urgent", expected one of: high, low` {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+// ========== Indexed Form Field Tests ==========
+
+type LineItem struct {
+	Name string `schema:"name"`
+	Qty  int    `schema:"qty"`
+}
+
+type OrderForm struct {
+	Customer string            `schema:"customer"`
+	Items    []LineItem        `schema:"items"`
+	Meta     map[string]string `schema:"meta"`
+	Counts   map[string]int    `schema:"counts"`
+}
+
+func postForm(target string, values url.Values) *http.Request {
+	req := httptest.NewRequest("POST", target, strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestIndexedFormFields(t *testing.T) {
+	t.Run("slice of structs and maps", func(t *testing.T) {
+		Reset()
+		handler := H(func(f Form[OrderForm]) OrderForm {
+			return f.Value
+		})
+
+		values := url.Values{}
+		values.Set("customer", "acme")
+		values.Set("items[0].name", "bolt")
+		values.Set("items[0].qty", "10")
+		values.Set("items[1][name]", "nut")
+		values.Set("items.1.qty", "20")
+		values.Set("meta[color]", "red")
+		values.Set("meta[size]", "large")
+		values.Set("counts[a]", "1")
+		values.Set("counts.b", "2")
+		values.Set("meta", "ignored")
+
+		rec := httptest.NewRecorder()
+		handler(rec, postForm("/orders", values))
+		if rec.Code != 200 {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+
+		var order OrderForm
+		parseJSONResponse(t, rec.Body.Bytes(), &order)
+		want := []LineItem{{Name: "bolt", Qty: 10}, {Name: "nut", Qty: 20}}
+		if !reflect.DeepEqual(order.Items, want) {
+			t.Errorf("unexpected items: %+v", order.Items)
+		}
+		if !reflect.DeepEqual(order.Meta, map[string]string{"color": "red", "size": "large"}) {
+			t.Errorf("unexpected meta: %v", order.Meta)
+		}
+		if !reflect.DeepEqual(order.Counts, map[string]int{"a": 1, "b": 2}) {
+			t.Errorf("unexpected counts: %v", order.Counts)
+		}
+	})
+
+	t.Run("conversion error reports indexed path", func(t *testing.T) {
+		Reset()
+		handler := H(func(f Form[OrderForm]) OrderForm {
+			return f.Value
+		})
+
+		values := url.Values{}
+		values.Set("items[0].qty", "1")
+		values.Set("items[1].qty", "many")
+		values.Set("counts[x]", "lots")
+
+		rec := httptest.NewRecorder()
+		handler(rec, postForm("/orders", values))
+		if rec.Code != 400 {
+			t.Fatalf("expected status 400, got %d", rec.Code)
+		}
+
+		var httpErr HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+		if httpErr.Message != "counts[x]: invalid value; items[1].qty: invalid value" {
+			t.Errorf("unexpected message: %s", httpErr.Message)
+		}
+	})
+}
+
+// ========== Time Decoding Tests ==========
+
+func TestTimeDecoding(t *testing.T) {
+	type Report struct {
+		Start time.Time  `schema:"start" time_format:"2006-01-02"`
+		At    time.Time  `schema:"at"`
+		Until *time.Time `schema:"until" time_format:"2006-01-02 15:04"`
+		Name  string     `schema:"name"`
+	}
+
+	t.Run("date-only and datetime fields in one struct", func(t *testing.T) {
+		Reset()
+		req := httptest.NewRequest("GET", "/?start=2024-01-15&at=2024-01-15T10:30:00Z&until=2024-02-01+08:00&name=q1", nil)
+		var q Query[Report]
+		if err := q.Extract(req); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		if !q.Value.Start.Equal(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("unexpected Start: %v", q.Value.Start)
+		}
+		if !q.Value.At.Equal(time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)) {
+			t.Errorf("unexpected At: %v", q.Value.At)
+		}
+		if q.Value.Until == nil || !q.Value.Until.Equal(time.Date(2024, 2, 1, 8, 0, 0, 0, time.UTC)) {
+			t.Errorf("unexpected Until: %v", q.Value.Until)
+		}
+		if q.Value.Name != "q1" {
+			t.Errorf("expected Name=q1, got %s", q.Value.Name)
+		}
+	})
+
+	t.Run("tag overrides global layouts", func(t *testing.T) {
+		Reset()
+		req := httptest.NewRequest("GET", "/?start=2024-01-15T10:30:00Z", nil)
+		var q Query[Report]
+		err := q.Extract(req)
+		if err == nil {
+			t.Fatal("expected error for datetime in date-only field")
+		}
+		httpErr := ToHTTPError(err)
+		if httpErr.Code != 400 || !strings.Contains(httpErr.Message, "start") {
+			t.Errorf("unexpected error: %+v", httpErr)
+		}
+	})
+
+	t.Run("global layouts", func(t *testing.T) {
+		Reset()
+		Configure(WithTimeLayouts("02/01/2006"))
+		defer Reset()
+
+		req := httptest.NewRequest("GET", "/?at=15/01/2024", nil)
+		var q Query[Report]
+		if err := q.Extract(req); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		if !q.Value.At.Equal(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("unexpected At: %v", q.Value.At)
+		}
+	})
+
+	t.Run("form fields", func(t *testing.T) {
+		Reset()
+		formData := url.Values{}
+		formData.Set("start", "2024-03-01")
+		formData.Set("at", "2024-03-01T12:00:00Z")
+		req := httptest.NewRequest("POST", "/", strings.NewReader(formData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		var f Form[Report]
+		if err := f.Extract(req); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		if f.Value.Start.Day() != 1 || f.Value.At.Hour() != 12 {
+			t.Errorf("unexpected values: %+v", f.Value)
+		}
+		if f.Value.Until != nil {
+			t.Errorf("expected Until to stay nil, got %v", f.Value.Until)
+		}
+	})
 }
 
 // ========== Path Extractor Tests ==========
@@ -539,45 +1248,403 @@ func TestH_WithParameters(t *testing.T) {
 		if rec.Code != 201 {
 			t.Errorf("expected status 201, got %d", rec.Code)
 		}
-		if rec.Header().Get("X-Custom") != "test" {
-			t.Errorf("expected X-Custom header")
+		if rec.Header().Get("X-Custom") != "test" {
+			t.Errorf("expected X-Custom header")
+		}
+		if rec.Body.String() != "custom response" {
+			t.Errorf("unexpected body: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("with *http.Request", func(t *testing.T) {
+		handler := H(func(r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		})
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/test", nil)
+		handler(rec, req)
+		if rec.Body.String() != "POST /test" {
+			t.Errorf("unexpected response: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("with context.Context", func(t *testing.T) {
+		type ctxKey struct{}
+		handler := H(func(ctx context.Context, id Path[int]) string {
+			tenant, _ := ctx.Value(ctxKey{}).(string)
+			return fmt.Sprintf("%s %d %s", tenant, id.Value, RequestID(ctx))
+		})
+		rec := httptest.NewRecorder()
+		req := createRequestWithPattern("GET", "/items/7", "/items/{id}")
+		req.SetPathValue("id", "7")
+		req = req.WithContext(context.WithValue(req.Context(), ctxKey{}, "acme"))
+		handler(rec, req)
+		if expected := "acme 7 " + rec.Header().Get(RequestIDHeader); rec.Body.String() != expected {
+			t.Errorf("expected %q, got %q", expected, rec.Body.String())
+		}
+	})
+
+	t.Run("multiple parameters", func(t *testing.T) {
+		handler := H(func(id Path[int], q Query[QueryParams]) map[string]any {
+			return map[string]any{
+				"id":   id.Value,
+				"page": q.Value.Page,
+			}
+		})
+		rec := httptest.NewRecorder()
+		req := createRequestWithPattern("GET", "/items/5?page=2", "/items/{id}")
+		req.SetPathValue("id", "5")
+		handler(rec, req)
+		var result map[string]any
+		parseJSONResponse(t, rec.Body.Bytes(), &result)
+		if int(result["id"].(float64)) != 5 {
+			t.Errorf("expected id=5")
+		}
+		if int(result["page"].(float64)) != 2 {
+			t.Errorf("expected page=2")
+		}
+	})
+}
+
+// signatureExtractor reads the raw body, like a webhook signature check would
+type signatureExtractor struct {
+	Body []byte
+}
+
+func (s *signatureExtractor) ReadsBody() bool { return true }
+
+func (s *signatureExtractor) Extract(r *http.Request) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return NewBodyReadError(err)
+	}
+	s.Body = body
+	return nil
+}
+
+func TestH_BodyReuse(t *testing.T) {
+	t.Run("two body-consuming extractors", func(t *testing.T) {
+		Reset()
+		type Age struct {
+			Age int `json:"age"`
+		}
+		handler := H(func(sig signatureExtractor, user JSON[User], age JSON[Age]) map[string]any {
+			return map[string]any{
+				"raw":  string(sig.Body),
+				"name": user.Value.Name,
+				"age":  age.Value.Age,
+			}
+		})
+		body := `{"name":"Alice","age":25}`
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+
+		var result map[string]any
+		parseJSONResponse(t, rec.Body.Bytes(), &result)
+		if result["raw"] != body {
+			t.Errorf("unexpected raw body: %v", result["raw"])
+		}
+		if result["name"] != "Alice" || result["age"] != float64(25) {
+			t.Errorf("unexpected result: %v", result)
+		}
+	})
+
+	t.Run("body readable by handler after extraction", func(t *testing.T) {
+		Reset()
+		handler := H(func(sig signatureExtractor, user JSON[User], r *http.Request) string {
+			rest, _ := io.ReadAll(r.Body)
+			return string(rest)
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"Bob"}`)))
+		if rec.Body.String() != `{"name":"Bob"}` {
+			t.Errorf("unexpected body: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("buffering forced by config", func(t *testing.T) {
+		Reset()
+		Configure(WithBodyBuffering(true))
+		defer Reset()
+
+		handler := H(func(user JSON[User], r *http.Request) string {
+			rest, _ := io.ReadAll(r.Body)
+			return user.Value.Name + " " + string(rest)
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"Carol"}`)))
+		if rec.Body.String() != `Carol {"name":"Carol"}` {
+			t.Errorf("unexpected body: %s", rec.Body.String())
+		}
+	})
+}
+
+func TestAllowedContentTypes(t *testing.T) {
+	newRequest := func(method, contentType, body string) *http.Request {
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		return req
+	}
+
+	Reset()
+	Configure(WithAllowedContentTypes("application/json", "text/*"))
+	defer Reset()
+
+	handler := H(func(user JSON[User]) User {
+		return user.Value
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		expected    int
+	}{
+		{"allowed type", "POST", "application/json", `{"name":"Alice"}`, 200},
+		{"allowed type with params", "PUT", "Application/JSON; charset=utf-8", `{"name":"Alice"}`, 200},
+		{"wildcard subtype", "POST", "text/plain", `{"name":"Alice"}`, 200},
+		{"disallowed type", "POST", "application/xml", `<user/>`, 415},
+		{"missing type", "POST", "", `{"name":"Alice"}`, 415},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler(rec, newRequest(tt.method, tt.contentType, tt.body))
+			if rec.Code != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+
+	t.Run("requests without body pass", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		H(func() string { return "ok" })(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != 200 {
+			t.Errorf("expected status 200, got %d", rec.Code)
+		}
+	})
+
+	t.Run("error envelope", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler(rec, newRequest("POST", "application/xml", "<user/>"))
+		var httpErr HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+		if httpErr.Err != "unsupported_media_type" || !strings.Contains(httpErr.Message, "application/xml") {
+			t.Errorf("unexpected error: %+v", httpErr)
+		}
+	})
+}
+
+func TestEnforceContentType(t *testing.T) {
+	newRequest := func(contentType, body string) *http.Request {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		return req
+	}
+
+	jsonHandler := H(func(user JSON[User]) User { return user.Value })
+	formHandler := H(func(f Form[FormData]) FormData { return f.Value })
+
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		contentType string
+		body        string
+		expected    int
+	}{
+		{"JSON matched", jsonHandler, "application/json; charset=utf-8", `{"name":"Alice"}`, 200},
+		{"JSON mismatched", jsonHandler, "text/plain", `{"name":"Alice"}`, 415},
+		{"JSON missing type", jsonHandler, "", `{"name":"Alice"}`, 415},
+		{"form matched", formHandler, "application/x-www-form-urlencoded", "username=alice", 200},
+		{"form mismatched", formHandler, "application/json", `{"username":"alice"}`, 415},
+	}
+
+	Reset()
+	Configure(WithContentTypeEnforcement(true))
+	defer Reset()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler(rec, newRequest(tt.contentType, tt.body))
+			if rec.Code != tt.expected {
+				t.Errorf("expected status %d, got %d: %s", tt.expected, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		jsonHandler(rec, newRequest("text/plain", `{"name":"Alice"}`))
+		if rec.Code != 200 {
+			t.Errorf("expected status 200, got %d", rec.Code)
+		}
+	})
+}
+
+func TestH_PanicError(t *testing.T) {
+	t.Run("panic surfaces PanicError to custom handler", func(t *testing.T) {
+		Reset()
+		var captured error
+		Configure(
+			WithRecovery(true),
+			WithErrorHandler(func(w http.ResponseWriter, err error) {
+				captured = err
+				w.WriteHeader(503)
+			}),
+		)
+		defer Reset()
+
+		handler := H(func() string {
+			panic("boom")
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+
+		var panicErr *PanicError
+		if !errors.As(captured, &panicErr) {
+			t.Fatalf("expected PanicError, got %v", captured)
+		}
+		if panicErr.Value != "boom" {
+			t.Errorf("unexpected panic value: %v", panicErr.Value)
+		}
+		if len(panicErr.Stack) == 0 {
+			t.Error("expected stack trace")
+		}
+		if rec.Code != 503 {
+			t.Errorf("expected status 503, got %d", rec.Code)
+		}
+	})
+
+	t.Run("panic with error value", func(t *testing.T) {
+		Reset()
+		Configure(WithRecovery(true))
+		defer Reset()
+
+		sentinel := &HTTPError{Code: 400, Err: "bad_request"}
+		handler := H(func() error {
+			panic(sentinel)
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Code != 500 {
+			t.Errorf("expected status 500, got %d", rec.Code)
+		}
+		if !errors.Is(&PanicError{Value: sentinel}, sentinel) {
+			t.Error("expected PanicError to unwrap to the panic value")
+		}
+	})
+
+	t.Run("panic is logged and answered with 500", func(t *testing.T) {
+		Reset()
+		var buf bytes.Buffer
+		Configure(WithRecovery(true), WithLogger(log.New(&buf, "", 0)))
+		defer Reset()
+
+		handler := H(func() string { panic("boom") })
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/users", nil))
+
+		if rec.Code != 500 {
+			t.Errorf("expected status 500, got %d", rec.Code)
+		}
+		var body HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &body)
+		if body.Err != "internal_error" {
+			t.Errorf("expected internal_error, got %+v", body)
+		}
+		logged := buf.String()
+		if !strings.Contains(logged, "GET /users: panic: boom") || !strings.Contains(logged, "goroutine") {
+			t.Errorf("expected the panic and its stack to be logged, got %q", logged)
+		}
+		if !strings.Contains(logged, rec.Header().Get(RequestIDHeader)) {
+			t.Errorf("expected the request ID in the log, got %q", logged)
+		}
+	})
+
+	t.Run("handler name is logged", func(t *testing.T) {
+		Reset()
+		var buf bytes.Buffer
+		Configure(WithRecovery(true), WithLogger(log.New(&buf, "", 0)))
+		defer Reset()
+
+		H(panickingHandler)(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+		if !strings.Contains(buf.String(), "github.com/cymoo/mint.panickingHandler GET /users: panic: boom") {
+			t.Errorf("expected the function name in the log, got %q", buf.String())
 		}
-		if rec.Body.String() != "custom response" {
-			t.Errorf("unexpected body: %s", rec.Body.String())
+
+		buf.Reset()
+		H(Named("listUsers", panickingHandler))(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+		if !strings.Contains(buf.String(), "listUsers GET /users: panic: boom") {
+			t.Errorf("expected the given name in the log, got %q", buf.String())
 		}
 	})
 
-	t.Run("with *http.Request", func(t *testing.T) {
-		handler := H(func(r *http.Request) string {
-			return r.Method + " " + r.URL.Path
-		})
+	t.Run("server errors use the configured logger", func(t *testing.T) {
+		Reset()
+		var buf bytes.Buffer
+		Configure(WithLogger(log.New(&buf, "", 0)))
+		defer Reset()
+
 		rec := httptest.NewRecorder()
-		req := httptest.NewRequest("POST", "/test", nil)
-		handler(rec, req)
-		if rec.Body.String() != "POST /test" {
-			t.Errorf("unexpected response: %s", rec.Body.String())
+		H(Named("listUsers", func() error { return errors.New("db down") }))(rec, httptest.NewRequest("GET", "/users", nil))
+		if rec.Code != 500 {
+			t.Fatalf("expected status 500, got %d", rec.Code)
+		}
+		if logged := buf.String(); !strings.Contains(logged, "listUsers: ") || !strings.Contains(logged, rec.Header().Get(RequestIDHeader)) {
+			t.Errorf("expected the error with the handler name and request ID in the log, got %q", logged)
 		}
 	})
 
-	t.Run("multiple parameters", func(t *testing.T) {
-		handler := H(func(id Path[int], q Query[QueryParams]) map[string]any {
-			return map[string]any{
-				"id":   id.Value,
-				"page": q.Value.Page,
-			}
+	t.Run("panic after the response started", func(t *testing.T) {
+		Reset()
+		var buf bytes.Buffer
+		handled := false
+		Configure(
+			WithRecovery(true),
+			WithLogger(log.New(&buf, "", 0)),
+			WithErrorHandler(func(w http.ResponseWriter, err error) { handled = true }),
+		)
+		defer Reset()
+
+		handler := H(func(w http.ResponseWriter) {
+			w.Write([]byte("partial"))
+			panic("boom")
 		})
 		rec := httptest.NewRecorder()
-		req := createRequestWithPattern("GET", "/items/5?page=2", "/items/{id}")
-		req.SetPathValue("id", "5")
-		handler(rec, req)
-		var result map[string]any
-		parseJSONResponse(t, rec.Body.Bytes(), &result)
-		if int(result["id"].(float64)) != 5 {
-			t.Errorf("expected id=5")
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Code != 200 || rec.Body.String() != "partial" {
+			t.Errorf("expected the partial response to stand, got %d %q", rec.Code, rec.Body.String())
 		}
-		if int(result["page"].(float64)) != 2 {
-			t.Errorf("expected page=2")
+		if handled {
+			t.Error("expected the error handler not to run after the response started")
+		}
+		if strings.Contains(buf.String(), "multiple calls to WriteHeader") {
+			t.Errorf("expected no second WriteHeader, got %q", buf.String())
 		}
+		if !strings.Contains(buf.String(), "panic: boom") {
+			t.Errorf("expected the panic to be logged, got %q", buf.String())
+		}
+	})
+
+	t.Run("panics propagate without recovery", func(t *testing.T) {
+		Reset()
+		handler := H(func() { panic("boom") })
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic to propagate")
+			}
+		}()
+		handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
 	})
 }
 
@@ -717,24 +1784,477 @@ func TestH_ResultType(t *testing.T) {
 			return r
 		})
 		rec := httptest.NewRecorder()
-		req := httptest.NewRequest("GET", "/", nil)
-		handler(rec, req)
-		if rec.Header().Get("X-Custom-Header") != "value" {
-			t.Errorf("expected X-Custom-Header")
+		req := httptest.NewRequest("GET", "/", nil)
+		handler(rec, req)
+		if rec.Header().Get("X-Custom-Header") != "value" {
+			t.Errorf("expected X-Custom-Header")
+		}
+	})
+
+	t.Run("Result with chained headers", func(t *testing.T) {
+		handler := H(func() Result[User] {
+			return OK(User{Name: "Erin"}).
+				WithStatus(201).
+				WithHeader("X-One", "1").
+				WithHeader("X-Two", "2").
+				WithHeader("X-One", "uno").
+				WithCookie(&http.Cookie{Name: "session", Value: "abc"}).
+				WithCookie(&http.Cookie{Name: "theme", Value: "dark"})
+		})
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/", nil)
+		handler(rec, req)
+		if rec.Code != 201 {
+			t.Errorf("expected status 201, got %d", rec.Code)
+		}
+		if rec.Header().Get("X-One") != "uno" || rec.Header().Get("X-Two") != "2" {
+			t.Errorf("unexpected headers: %v", rec.Header())
+		}
+		cookies := rec.Result().Cookies()
+		if len(cookies) != 2 || cookies[0].Value != "abc" || cookies[1].Value != "dark" {
+			t.Errorf("unexpected cookies: %v", cookies)
+		}
+		var user User
+		parseJSONResponse(t, rec.Body.Bytes(), &user)
+		if user.Name != "Erin" {
+			t.Errorf("expected Name=Erin, got %s", user.Name)
+		}
+	})
+
+	t.Run("Result with custom status code", func(t *testing.T) {
+		handler := H(func() Result[User] {
+			r := OK(User{Name: "Dave"})
+			r.Code = 201
+			return r
+		})
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/", nil)
+		handler(rec, req)
+		if rec.Code != 201 {
+			t.Errorf("expected status 201, got %d", rec.Code)
+		}
+	})
+
+	t.Run("Result with rate limit", func(t *testing.T) {
+		reset := time.Unix(1700000000, 0)
+		handler := H(func(r *http.Request) Result[User] {
+			if r.URL.Path == "/limited" {
+				return Err[User](429, errors.New("too many requests")).
+					WithRateLimit(RateLimit{Limit: 100, Remaining: 0, Reset: reset})
+			}
+			return OK(User{Name: "Frank"}).WithRateLimit(RateLimit{Limit: 100, Remaining: 42})
+		})
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Header().Get("X-RateLimit-Limit") != "100" || rec.Header().Get("X-RateLimit-Remaining") != "42" {
+			t.Errorf("unexpected rate-limit headers: %v", rec.Header())
+		}
+		if _, ok := rec.Header()["X-Ratelimit-Reset"]; ok {
+			t.Error("expected no reset header for a zero reset time")
+		}
+
+		rec = httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/limited", nil))
+		if rec.Code != 429 {
+			t.Errorf("expected status 429, got %d", rec.Code)
+		}
+		if rec.Header().Get("X-RateLimit-Remaining") != "0" || rec.Header().Get("X-RateLimit-Reset") != "1700000000" {
+			t.Errorf("unexpected rate-limit headers: %v", rec.Header())
+		}
+	})
+}
+
+func TestAccepted(t *testing.T) {
+	type Job struct {
+		ID    string `json:"id"`
+		State string `json:"state"`
+	}
+
+	Reset()
+	handler := H(func(r *http.Request) Result[Job] {
+		return Accepted("/jobs/42", Job{ID: "42", State: "queued"}).WithHeader("Retry-After", "5")
+	})
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("POST", "/exports", nil))
+
+	if rec.Code != 202 {
+		t.Errorf("expected status 202, got %d", rec.Code)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/jobs/42" {
+		t.Errorf("expected Location /jobs/42, got %q", loc)
+	}
+	if rec.Header().Get("Retry-After") != "5" {
+		t.Error("expected builder headers to be kept")
+	}
+	var job Job
+	parseJSONResponse(t, rec.Body.Bytes(), &job)
+	if job.ID != "42" || job.State != "queued" {
+		t.Errorf("unexpected job: %+v", job)
+	}
+}
+
+func TestErrWithBody(t *testing.T) {
+	type FieldError struct {
+		Field  string `json:"field"`
+		Reason string `json:"reason"`
+	}
+	type Report struct {
+		Errors []FieldError `json:"errors"`
+	}
+	report := Report{Errors: []FieldError{{Field: "email", Reason: "taken"}, {Field: "age", Reason: "too young"}}}
+
+	t.Run("custom body with the given status", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		H(func() Result[Report] {
+			return ErrWithBody(422, errors.New("invalid signup"), report)
+		})(rec, httptest.NewRequest("POST", "/", nil))
+
+		if rec.Code != 422 {
+			t.Errorf("expected status 422, got %d", rec.Code)
+		}
+		var got Report
+		parseJSONResponse(t, rec.Body.Bytes(), &got)
+		if len(got.Errors) != 2 || got.Errors[0].Field != "email" {
+			t.Errorf("expected the report as body, got %s", rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("unexpected Content-Type %q", ct)
+		}
+	})
+
+	t.Run("status from the error", func(t *testing.T) {
+		Reset()
+		quota := &HTTPError{Code: 429, Err: "too_many_requests", RetryAfter: 10 * time.Second}
+		rec := httptest.NewRecorder()
+		H(func() Result[map[string]int] {
+			return ErrWithBody(0, quota, map[string]int{"limit": 100})
+		})(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Code != 429 || rec.Header().Get("Retry-After") != "10" {
+			t.Errorf("expected 429 with Retry-After, got %d %v", rec.Code, rec.Header())
+		}
+		if strings.TrimSpace(rec.Body.String()) != `{"limit":100}` {
+			t.Errorf("unexpected body: %s", rec.Body.String())
+		}
+		if quota.Code != 429 {
+			t.Error("the error must not be modified")
+		}
+	})
+
+	t.Run("plain Err still sends the envelope", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		H(func() Result[Report] {
+			return Result[Report]{Code: 422, Data: report, Err: errors.New("invalid signup")}
+		})(rec, httptest.NewRequest("POST", "/", nil))
+
+		var httpErr HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+		if rec.Code != 422 || httpErr.Err == "" || strings.Contains(rec.Body.String(), "email") {
+			t.Errorf("unexpected response: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("batch element", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		H(func() []Result[Report] {
+			return []Result[Report]{OK(Report{}), ErrWithBody(422, errors.New("invalid"), report)}
+		})(rec, httptest.NewRequest("GET", "/", nil))
+
+		var got []Report
+		parseJSONResponse(t, rec.Body.Bytes(), &got)
+		if len(got) != 2 || len(got[1].Errors) != 2 {
+			t.Errorf("unexpected batch: %s", rec.Body.String())
+		}
+	})
+}
+
+func TestH_Status(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  any
+		expected int
+		body     string
+	}{
+		{"created", func() Status[User] { return WithStatus(201, User{Name: "Ann"}) }, 201, `{"name":"Ann","email":"","age":0}`},
+		{"accepted text", func() Status[string] { return WithStatus(202, "queued") }, 202, "queued"},
+		{"conflict", func() Status[map[string]int] { return WithStatus(409, map[string]int{"version": 3}) }, 409, `{"version":3}`},
+		{"no content", func() Status[User] { return WithStatus(204, User{Name: "Ann"}) }, 204, ""},
+		{"with error return", func() (Status[User], error) { return WithStatus(201, User{Name: "Bo"}), nil }, 201, `{"name":"Bo","email":"","age":0}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Reset()
+			rec := httptest.NewRecorder()
+			H(tt.handler)(rec, httptest.NewRequest("POST", "/", nil))
+			if rec.Code != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, rec.Code)
+			}
+			if rec.Body.String() != tt.body {
+				t.Errorf("expected body %q, got %q", tt.body, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("error return wins", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		H(func() (Status[User], error) {
+			return Status[User]{}, errors.New("user not found")
+		})(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != 404 {
+			t.Errorf("expected status 404, got %d", rec.Code)
+		}
+	})
+
+	// Headers set while rendering must not be lost to an earlier WriteHeader
+	t.Run("content type reaches the client", func(t *testing.T) {
+		Reset()
+		mux := http.NewServeMux()
+		mux.HandleFunc("/status", H(func() Status[User] { return WithStatus(201, User{}) }))
+		mux.HandleFunc("/result", H(func() Result[User] { return Result[User]{Code: 201} }))
+		mux.HandleFunc("/error", H(func() Result[User] { return Err[User](409, errors.New("taken")) }))
+		srv := httptest.NewServer(mux)
+		defer srv.Close()
+
+		for _, path := range []string{"/status", "/result", "/error"} {
+			resp, err := srv.Client().Get(srv.URL + path)
+			if err != nil {
+				t.Fatalf("%s: request failed: %v", path, err)
+			}
+			resp.Body.Close()
+			if ct := resp.Header.Get("Content-Type"); ct != "application/json; charset=utf-8" {
+				t.Errorf("%s: unexpected Content-Type %q (status %d)", path, ct, resp.StatusCode)
+			}
+		}
+	})
+}
+
+func TestH_NoContent(t *testing.T) {
+	for _, code := range []int{http.StatusNoContent, http.StatusNotModified} {
+		t.Run(fmt.Sprintf("Result %d with data", code), func(t *testing.T) {
+			handler := H(func() Result[User] {
+				return OK(User{Name: "ignored"}).
+					WithStatus(code).
+					WithHeader("Content-Type", "application/json").
+					WithHeader("ETag", `"v1"`)
+			})
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest("GET", "/", nil))
+			if rec.Code != code {
+				t.Errorf("expected status %d, got %d", code, rec.Code)
+			}
+			if rec.Body.Len() != 0 {
+				t.Errorf("expected no body, got %s", rec.Body.String())
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "" {
+				t.Errorf("expected no content type, got %s", ct)
+			}
+			if rec.Header().Get("ETag") != `"v1"` {
+				t.Errorf("expected other headers to be kept")
+			}
+		})
+	}
+
+	t.Run("Result 204 with error", func(t *testing.T) {
+		Reset()
+		var handled error
+		Configure(WithErrorHandler(func(w http.ResponseWriter, err error) {
+			handled = err
+			w.WriteHeader(http.StatusConflict)
+		}))
+		defer Reset()
+
+		handler := H(func() Result[string] {
+			return Err[string](204, errors.New("still referenced"))
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("DELETE", "/", nil))
+		if handled == nil || handled.Error() != "still referenced" {
+			t.Errorf("expected the error to reach the error handler, got %v", handled)
+		}
+		if rec.Code != http.StatusConflict {
+			t.Errorf("expected the error's status, got %d", rec.Code)
+		}
+	})
+
+	t.Run("Result 304 with error uses the error's status", func(t *testing.T) {
+		Reset()
+		handler := H(func() Result[string] {
+			return Err[string](304, NotFound("gone"))
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+		var body HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &body)
+		if rec.Code != 404 || body.Err != "not_found" {
+			t.Errorf("unexpected response: %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("StatusCode 204", func(t *testing.T) {
+		handler := H(func(w http.ResponseWriter) StatusCode {
+			w.Header().Set("Content-Type", "text/plain")
+			return StatusCode(204)
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("DELETE", "/", nil))
+		if rec.Code != 204 || rec.Header().Get("Content-Type") != "" {
+			t.Errorf("unexpected response: %d %v", rec.Code, rec.Header())
+		}
+	})
+}
+
+func TestVoidStatus(t *testing.T) {
+	t.Run("200 by default", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		H(func() {})(rec, httptest.NewRequest("POST", "/", nil))
+		if rec.Code != 200 {
+			t.Errorf("expected status 200, got %d", rec.Code)
+		}
+	})
+
+	t.Run("configured status", func(t *testing.T) {
+		Reset()
+		Configure(WithVoidStatus(http.StatusNoContent))
+		defer Reset()
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"Alice","email":"alice@example.com","age":30}`))
+		req.Header.Set("Content-Type", "application/json")
+		H(func(body JSON[User]) {})(rec, req)
+		if rec.Code != 204 || rec.Body.Len() != 0 {
+			t.Errorf("expected an empty 204, got %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("handler writing itself wins", func(t *testing.T) {
+		Reset()
+		Configure(WithVoidStatus(http.StatusNoContent))
+		defer Reset()
+
+		rec := httptest.NewRecorder()
+		H(func(w http.ResponseWriter) { w.Write([]byte("done")) })(rec, httptest.NewRequest("POST", "/", nil))
+		if rec.Code != 200 || rec.Body.String() != "done" {
+			t.Errorf("expected the handler's response, got %d %q", rec.Code, rec.Body.String())
+		}
+
+		rec = httptest.NewRecorder()
+		H(func(w http.ResponseWriter) { w.(*ResponseWriter).SetStatus(http.StatusAccepted) })(rec, httptest.NewRequest("POST", "/", nil))
+		if rec.Code != 202 {
+			t.Errorf("expected the status set by the handler, got %d", rec.Code)
+		}
+	})
+
+	t.Run("only handlers without return values", func(t *testing.T) {
+		Reset()
+		Configure(WithVoidStatus(http.StatusNoContent))
+		defer Reset()
+
+		rec := httptest.NewRecorder()
+		H(func() error { return nil })(rec, httptest.NewRequest("POST", "/", nil))
+		if rec.Code != 200 {
+			t.Errorf("expected status 200 for a nil error, got %d", rec.Code)
+		}
+	})
+}
+
+func TestOrderedMap(t *testing.T) {
+	t.Run("keys in insertion order", func(t *testing.T) {
+		handler := H(func() OrderedMap {
+			om := OrderedMap{{Key: "zeta", Value: 1}, {Key: "alpha", Value: "a"}}
+			om.Set("mid", []int{1, 2})
+			om.Set("zeta", 26)
+			return om
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+			t.Errorf("unexpected content type: %s", rec.Header().Get("Content-Type"))
+		}
+		if body := strings.TrimSpace(rec.Body.String()); body != `{"zeta":26,"alpha":"a","mid":[1,2]}` {
+			t.Errorf("unexpected body: %s", body)
+		}
+	})
+
+	t.Run("nested inside other values", func(t *testing.T) {
+		data, err := json.Marshal(map[string]any{
+			"config": OrderedMap{{Key: "b", Value: true}, {Key: "a", Value: nil}},
+		})
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		if string(data) != `{"config":{"b":true,"a":null}}` {
+			t.Errorf("unexpected JSON: %s", data)
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		om := OrderedMap{{Key: "a", Value: 1}}
+		if v, ok := om.Get("a"); !ok || v != 1 {
+			t.Errorf("unexpected value: %v %v", v, ok)
+		}
+		if _, ok := om.Get("b"); ok {
+			t.Error("expected missing key")
+		}
+	})
+}
+
+func TestH_ResultBatch(t *testing.T) {
+	t.Run("mixed success and error batch", func(t *testing.T) {
+		handler := H(func() []Result[any] {
+			return []Result[any]{
+				OK[any](User{Name: "Alice"}),
+				Err[any](404, errors.New("user 2 not found")),
+				OK[any]("plain"),
+				Err[any](0, &HTTPError{Code: 409, Err: "conflict", Message: "duplicate"}),
+			}
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("POST", "/rpc", nil))
+
+		if rec.Code != 200 {
+			t.Errorf("expected status 200, got %d", rec.Code)
+		}
+		var items []map[string]any
+		var raw []json.RawMessage
+		parseJSONResponse(t, rec.Body.Bytes(), &raw)
+		if len(raw) != 4 {
+			t.Fatalf("expected 4 elements, got %d", len(raw))
+		}
+		for _, i := range []int{0, 1, 3} {
+			var item map[string]any
+			parseJSONResponse(t, raw[i], &item)
+			items = append(items, item)
+		}
+		if items[0]["name"] != "Alice" {
+			t.Errorf("unexpected first element: %v", items[0])
+		}
+		if items[1]["code"] != float64(404) || items[1]["error"] != "not_found" {
+			t.Errorf("unexpected error element: %v", items[1])
+		}
+		if string(raw[2]) != `"plain"` {
+			t.Errorf("unexpected third element: %s", raw[2])
+		}
+		if items[2]["code"] != float64(409) || items[2]["message"] != "duplicate" {
+			t.Errorf("unexpected HTTPError element: %v", items[2])
 		}
 	})
 
-	t.Run("Result with custom status code", func(t *testing.T) {
-		handler := H(func() Result[User] {
-			r := OK(User{Name: "Dave"})
-			r.Code = 201
-			return r
+	t.Run("typed results", func(t *testing.T) {
+		handler := H(func() []Result[int] {
+			return []Result[int]{OK(1), Err[int](400, errors.New("invalid input")), OK(3)}
 		})
 		rec := httptest.NewRecorder()
-		req := httptest.NewRequest("POST", "/", nil)
-		handler(rec, req)
-		if rec.Code != 201 {
-			t.Errorf("expected status 201, got %d", rec.Code)
+		handler(rec, httptest.NewRequest("POST", "/", nil))
+		if body := strings.TrimSpace(rec.Body.String()); body != `[1,{"code":400,"error":"bad_request"},3]` {
+			t.Errorf("unexpected body: %s", body)
 		}
 	})
 }
@@ -759,6 +2279,74 @@ func TestH_HTTPHandler(t *testing.T) {
 			t.Errorf("unexpected body: %s", rec.Body.String())
 		}
 	})
+
+	t.Run("return http.HandlerFunc", func(t *testing.T) {
+		handler := H(func(r *http.Request) http.HandlerFunc {
+			if r.URL.Query().Get("delegate") == "" {
+				return nil
+			}
+			return func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(203)
+				w.Write([]byte("delegated"))
+			}
+		})
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/?delegate=1", nil))
+		if rec.Code != 203 || rec.Body.String() != "delegated" {
+			t.Errorf("unexpected response: %d %s", rec.Code, rec.Body.String())
+		}
+
+		rec = httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != 200 || rec.Body.Len() != 0 {
+			t.Errorf("expected empty 200 for nil HandlerFunc, got %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("delegate with headers", func(t *testing.T) {
+		var seen http.Header
+		inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = w.Header().Clone()
+			w.Header().Set("Content-Type", "text/css")
+			w.Write([]byte("body{}"))
+		})
+
+		handler := H(func() http.Handler {
+			return Delegate(inner, http.Header{
+				"Cache-Control": {"public, max-age=3600"},
+				"Content-Type":  {"text/plain"},
+			})
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/app.css", nil))
+
+		if seen.Get("Cache-Control") != "public, max-age=3600" {
+			t.Errorf("expected headers to be set before delegating, saw %v", seen)
+		}
+		if cc := rec.Header().Get("Cache-Control"); cc != "public, max-age=3600" {
+			t.Errorf("unexpected Cache-Control %q", cc)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "text/css" {
+			t.Errorf("expected the delegate to override Content-Type, got %q", ct)
+		}
+		if rec.Body.String() != "body{}" {
+			t.Errorf("unexpected body: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("return plain handler function", func(t *testing.T) {
+		handler := H(func() func(http.ResponseWriter, *http.Request) {
+			return func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("plain"))
+			}
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Body.String() != "plain" {
+			t.Errorf("unexpected body: %s", rec.Body.String())
+		}
+	})
 }
 
 // ========== Error Conversion Tests ==========
@@ -804,7 +2392,7 @@ func TestExtractError(t *testing.T) {
 
 func TestToHTTPError(t *testing.T) {
 	t.Run("nil error", func(t *testing.T) {
-		result := toHTTPError(nil)
+		result := ToHTTPError(nil)
 		if result != nil {
 			t.Error("expected nil for nil error")
 		}
@@ -812,7 +2400,7 @@ func TestToHTTPError(t *testing.T) {
 
 	t.Run("HTTPError pointer", func(t *testing.T) {
 		httpErr := &HTTPError{Code: 400, Err: "bad_request"}
-		result := toHTTPError(httpErr)
+		result := ToHTTPError(httpErr)
 		if result.Code != 400 {
 			t.Errorf("expected Code=400, got %d", result.Code)
 		}
@@ -820,7 +2408,7 @@ func TestToHTTPError(t *testing.T) {
 
 	t.Run("HTTPError value", func(t *testing.T) {
 		httpErr := HTTPError{Code: 403, Err: "forbidden"}
-		result := toHTTPError(httpErr)
+		result := ToHTTPError(httpErr)
 		if result.Code != 403 {
 			t.Errorf("expected Code=403, got %d", result.Code)
 		}
@@ -828,7 +2416,7 @@ func TestToHTTPError(t *testing.T) {
 
 	t.Run("ExtractError - body read", func(t *testing.T) {
 		err := NewBodyReadError(errors.New("read failed"))
-		result := toHTTPError(err)
+		result := ToHTTPError(err)
 		if result.Code != 500 {
 			t.Errorf("expected Code=500, got %d", result.Code)
 		}
@@ -836,7 +2424,7 @@ func TestToHTTPError(t *testing.T) {
 
 	t.Run("ExtractError - empty body", func(t *testing.T) {
 		err := NewEmptyBodyError()
-		result := toHTTPError(err)
+		result := ToHTTPError(err)
 		if result.Code != 400 {
 			t.Errorf("expected Code=400, got %d", result.Code)
 		}
@@ -847,7 +2435,7 @@ func TestToHTTPError(t *testing.T) {
 
 	t.Run("ExtractError - form parse", func(t *testing.T) {
 		err := NewFormParseError(errors.New("parse failed"))
-		result := toHTTPError(err)
+		result := ToHTTPError(err)
 		if result.Code != 400 {
 			t.Errorf("expected Code=400, got %d", result.Code)
 		}
@@ -858,7 +2446,7 @@ func TestToHTTPError(t *testing.T) {
 
 	t.Run("ExtractError - path conversion", func(t *testing.T) {
 		err := NewPathConversionError("id", "abc", "int", errors.New("parse failed"))
-		result := toHTTPError(err)
+		result := ToHTTPError(err)
 		if result.Code != 400 {
 			t.Errorf("expected Code=400, got %d", result.Code)
 		}
@@ -869,7 +2457,7 @@ func TestToHTTPError(t *testing.T) {
 
 	t.Run("ExtractError - missing path", func(t *testing.T) {
 		err := NewMissingPathError("id")
-		result := toHTTPError(err)
+		result := ToHTTPError(err)
 		if result.Code != 400 {
 			t.Errorf("expected Code=400, got %d", result.Code)
 		}
@@ -884,7 +2472,7 @@ func TestToHTTPError(t *testing.T) {
 			Type:  reflect.TypeOf(0),
 			Value: "string",
 		}
-		result := toHTTPError(err)
+		result := ToHTTPError(err)
 		if result.Code != 400 {
 			t.Errorf("expected Code=400, got %d", result.Code)
 		}
@@ -895,7 +2483,7 @@ func TestToHTTPError(t *testing.T) {
 
 	t.Run("json.SyntaxError", func(t *testing.T) {
 		err := &json.SyntaxError{}
-		result := toHTTPError(err)
+		result := ToHTTPError(err)
 		if result.Code != 400 {
 			t.Errorf("expected Code=400, got %d", result.Code)
 		}
@@ -909,7 +2497,7 @@ func TestToHTTPError(t *testing.T) {
 			"field1": errors.New("error1"),
 			"field2": errors.New("error2"),
 		}
-		result := toHTTPError(multiErr)
+		result := ToHTTPError(multiErr)
 		if result.Code != 400 {
 			t.Errorf("expected Code=400, got %d", result.Code)
 		}
@@ -920,7 +2508,7 @@ func TestToHTTPError(t *testing.T) {
 
 	t.Run("schema.ConversionError", func(t *testing.T) {
 		err := &schema.ConversionError{Key: "field"}
-		result := toHTTPError(err)
+		result := ToHTTPError(err)
 		if result.Code != 400 {
 			t.Errorf("expected Code=400, got %d", result.Code)
 		}
@@ -931,7 +2519,7 @@ func TestToHTTPError(t *testing.T) {
 
 	t.Run("schema.UnknownKeyError", func(t *testing.T) {
 		err := &schema.UnknownKeyError{Key: "unknown"}
-		result := toHTTPError(err)
+		result := ToHTTPError(err)
 		if result.Code != 400 {
 			t.Errorf("expected Code=400, got %d", result.Code)
 		}
@@ -941,6 +2529,36 @@ func TestToHTTPError(t *testing.T) {
 	})
 }
 
+func TestToHTTPErrorInCustomHandler(t *testing.T) {
+	Reset()
+	Configure(WithErrorHandler(func(w http.ResponseWriter, err error) {
+		httpErr := ToHTTPError(err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(httpErr.Code)
+		json.NewEncoder(w).Encode(map[string]any{
+			"status": httpErr.Code,
+			"reason": httpErr.Err,
+			"detail": httpErr.Message,
+		})
+	}))
+	defer Reset()
+
+	handler := H(func(user JSON[User]) User {
+		return user.Value
+	})
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("POST", "/", strings.NewReader("")))
+
+	if rec.Code != 400 {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+	var body map[string]any
+	parseJSONResponse(t, rec.Body.Bytes(), &body)
+	if body["reason"] != "empty_body" || body["detail"] != "request body is required" {
+		t.Errorf("unexpected body: %v", body)
+	}
+}
+
 func TestInferStatusCode(t *testing.T) {
 	tests := []struct {
 		msg          string
@@ -970,6 +2588,256 @@ func TestInferStatusCode(t *testing.T) {
 	}
 }
 
+func TestStatusInferrer(t *testing.T) {
+	errConflict := errors.New("version mismatch")
+
+	Reset()
+	Configure(WithStatusInferrer(func(err error) int {
+		if errors.Is(err, errConflict) {
+			return http.StatusConflict
+		}
+		return inferStatusCode(err.Error())
+	}))
+	defer Reset()
+
+	handler := H(func(r *http.Request) error {
+		if r.URL.Path == "/conflict" {
+			return fmt.Errorf("saving order: %w", errConflict)
+		}
+		return errors.New("order not found")
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("PUT", "/conflict", nil))
+	if rec.Code != 409 {
+		t.Errorf("expected status 409, got %d", rec.Code)
+	}
+	var httpErr HTTPError
+	parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+	if httpErr.Err != "conflict" {
+		t.Errorf("expected Err=conflict, got %s", httpErr.Err)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("PUT", "/other", nil))
+	if rec.Code != 404 {
+		t.Errorf("expected status 404 from fallback, got %d", rec.Code)
+	}
+}
+
+// quotaError carries its own status code
+type quotaError struct {
+	code int
+}
+
+func (e quotaError) Error() string   { return "quota exceeded" }
+func (e quotaError) StatusCode() int { return e.code }
+
+func TestErrorStatusCode(t *testing.T) {
+	t.Run("status from StatusCode method", func(t *testing.T) {
+		Reset()
+		httpErr := ToHTTPError(quotaError{code: http.StatusTooManyRequests})
+		if httpErr.Code != 429 || httpErr.Err != "too_many_requests" {
+			t.Errorf("unexpected error: %+v", httpErr)
+		}
+	})
+
+	t.Run("wrapped error", func(t *testing.T) {
+		Reset()
+		handler := H(func() error {
+			return fmt.Errorf("sending message: %w", quotaError{code: http.StatusPaymentRequired})
+		})
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("POST", "/", nil))
+		if rec.Code != 402 {
+			t.Errorf("expected status 402, got %d", rec.Code)
+		}
+	})
+
+	t.Run("takes precedence over inference", func(t *testing.T) {
+		Reset()
+		Configure(WithStatusInferrer(func(err error) int { return 500 }))
+		defer Reset()
+
+		if code := ToHTTPError(quotaError{code: 403}).Code; code != 403 {
+			t.Errorf("expected status 403, got %d", code)
+		}
+	})
+
+	t.Run("non-error code falls back to inference", func(t *testing.T) {
+		Reset()
+		if code := ToHTTPError(quotaError{code: 200}).Code; code != 500 {
+			t.Errorf("expected status 500, got %d", code)
+		}
+	})
+}
+
+func TestHTTPErrorShorthands(t *testing.T) {
+	tests := []struct {
+		err     *HTTPError
+		code    int
+		errType string
+	}{
+		{BadRequest("bad input"), 400, "bad_request"},
+		{Unauthorized("bad input"), 401, "unauthorized"},
+		{Forbidden("bad input"), 403, "forbidden"},
+		{NotFound("bad input"), 404, "not_found"},
+		{Conflict("bad input"), 409, "conflict"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.errType, func(t *testing.T) {
+			Reset()
+			rec := httptest.NewRecorder()
+			H(func() (User, error) { return User{}, tt.err })(rec, httptest.NewRequest("GET", "/", nil))
+
+			if rec.Code != tt.code {
+				t.Errorf("expected status %d, got %d", tt.code, rec.Code)
+			}
+			var got HTTPError
+			parseJSONResponse(t, rec.Body.Bytes(), &got)
+			expected := HTTPError{Code: tt.code, Err: tt.errType, Message: "bad input"}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected %+v, got %+v", expected, got)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorDetails(t *testing.T) {
+	details := map[string]any{"field": "email", "reason": "taken"}
+
+	t.Run("serialized in the envelope", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		H(func() (User, error) {
+			return User{}, Conflict("email already registered").WithDetails(details)
+		})(rec, httptest.NewRequest("POST", "/users", nil))
+
+		if rec.Code != 409 {
+			t.Errorf("expected status 409, got %d", rec.Code)
+		}
+		var got HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &got)
+		if !reflect.DeepEqual(got.Details, details) {
+			t.Errorf("expected details %v, got %v", details, got.Details)
+		}
+	})
+
+	t.Run("preserved through wrapping", func(t *testing.T) {
+		err := fmt.Errorf("creating user: %w", &HTTPError{Code: 422, Err: "invalid_user", Details: details})
+		if got := ToHTTPError(err); !reflect.DeepEqual(got.Details, details) {
+			t.Errorf("expected details %v, got %v", details, got.Details)
+		}
+	})
+
+	t.Run("omitted when empty", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		H(func() (User, error) { return User{}, NotFound("no such user") })(rec, httptest.NewRequest("GET", "/", nil))
+		if strings.Contains(rec.Body.String(), "details") {
+			t.Errorf("expected no details field, got %s", rec.Body.String())
+		}
+	})
+
+	t.Run("WithDetails copies", func(t *testing.T) {
+		base := NotFound("missing")
+		withDetails := base.WithDetails(details)
+		if base.Details != nil || withDetails.Message != "missing" {
+			t.Errorf("expected a copy, got base %+v and %+v", base, withDetails)
+		}
+	})
+}
+
+func TestErrorRetryAfter(t *testing.T) {
+	unavailable := &HTTPError{
+		Code:       http.StatusServiceUnavailable,
+		Err:        "service_unavailable",
+		Message:    "database is restarting",
+		RetryAfter: 30 * time.Second,
+	}
+
+	tests := []struct {
+		name   string
+		err    error
+		header string
+	}{
+		{"direct", unavailable, "30"},
+		{"wrapped", fmt.Errorf("loading user: %w", unavailable), "30"},
+		{"value", HTTPError{Code: 429, Err: "too_many_requests", RetryAfter: 1500 * time.Millisecond}, "2"},
+		{"no hint", &HTTPError{Code: 503, Err: "service_unavailable"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Reset()
+			rec := httptest.NewRecorder()
+			H(func() error { return tt.err })(rec, httptest.NewRequest("GET", "/", nil))
+
+			if got := rec.Header().Get("Retry-After"); got != tt.header {
+				t.Errorf("expected Retry-After %q, got %q", tt.header, got)
+			}
+			if strings.Contains(rec.Body.String(), "retry") {
+				t.Errorf("retry hint should not be in the body: %s", rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("503 response", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		H(func() (User, error) { return User{}, unavailable })(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != 503 {
+			t.Errorf("expected status 503, got %d", rec.Code)
+		}
+		var httpErr HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+		if httpErr.Message != "database is restarting" {
+			t.Errorf("unexpected error: %+v", httpErr)
+		}
+	})
+}
+
+func TestExtractErrorMapper(t *testing.T) {
+	Reset()
+	Configure(WithExtractErrorMapper(func(err *ExtractError) *HTTPError {
+		if err.Type == ErrTypeEmptyBody {
+			return &HTTPError{Code: http.StatusUnprocessableEntity, Err: "missing_body", Message: err.Message}
+		}
+		return nil
+	}))
+	defer Reset()
+
+	handler := H(func(user JSON[User]) User { return user.Value })
+
+	t.Run("overrides mapping", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("POST", "/", strings.NewReader("")))
+		if rec.Code != 422 {
+			t.Errorf("expected status 422, got %d", rec.Code)
+		}
+		var httpErr HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+		if httpErr.Err != "missing_body" {
+			t.Errorf("expected Err=missing_body, got %s", httpErr.Err)
+		}
+	})
+
+	t.Run("nil falls back to built-in mapping", func(t *testing.T) {
+		httpErr := ToHTTPError(NewMissingPathError("id"))
+		if httpErr.Code != 400 || httpErr.Err != "missing_path_parameter" {
+			t.Errorf("unexpected error: %+v", httpErr)
+		}
+	})
+
+	t.Run("other errors are not passed to the mapper", func(t *testing.T) {
+		if code := ToHTTPError(errors.New("order not found")).Code; code != 404 {
+			t.Errorf("expected status 404, got %d", code)
+		}
+	})
+}
+
 func TestInferErrorType(t *testing.T) {
 	tests := []struct {
 		code         int
@@ -980,6 +2848,9 @@ func TestInferErrorType(t *testing.T) {
 		{403, "forbidden"},
 		{404, "not_found"},
 		{408, "timeout"},
+		{409, "conflict"},
+		{418, "im_a_teapot"},
+		{422, "unprocessable_entity"},
 		{500, "internal_error"},
 		{503, "internal_error"},
 	}
@@ -1026,6 +2897,21 @@ func TestExtractPatternNames(t *testing.T) {
 	}
 }
 
+func TestDuplicatePathParameters(t *testing.T) {
+	// Path parameters rely on ServeMux rejecting duplicate wildcard names at registration
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("expected ServeMux to reject the pattern")
+		}
+		if msg := fmt.Sprint(r); !strings.Contains(msg, `duplicate wildcard name "id"`) {
+			t.Errorf("unexpected panic message: %s", msg)
+		}
+	}()
+	Reset()
+	http.NewServeMux().HandleFunc("GET /a/{id}/b/{id}", H(func(a Path[int], b Path[int]) int { return a.Value + b.Value }))
+}
+
 // ========== ResponseWriter Tests ==========
 
 func TestResponseWriter(t *testing.T) {
@@ -1069,24 +2955,193 @@ type CustomResponder struct {
 	body       string
 }
 
-func (cr CustomResponder) Respond(w http.ResponseWriter) {
-	w.WriteHeader(cr.statusCode)
-	w.Write([]byte(cr.body))
-}
+func (cr CustomResponder) Respond(w http.ResponseWriter) {
+	w.WriteHeader(cr.statusCode)
+	w.Write([]byte(cr.body))
+}
+
+func TestResponseWriterSetStatus(t *testing.T) {
+	t.Run("status is written with the body", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		rw := &ResponseWriter{ResponseWriter: rec}
+		rw.SetStatus(201)
+		if rw.headerWritten {
+			t.Fatal("SetStatus should not write the header")
+		}
+		rw.Header().Set("X-Late", "yes")
+		rw.Write([]byte("ok"))
+		if rec.Code != 201 || rec.Header().Get("X-Late") != "yes" {
+			t.Errorf("expected 201 with X-Late, got %d %v", rec.Code, rec.Header())
+		}
+	})
+
+	// A real server, since the recorder keeps headers changed after WriteHeader
+	t.Run("headers set after SetStatus reach the client", func(t *testing.T) {
+		Reset()
+		mux := http.NewServeMux()
+		mux.HandleFunc("/json", H(func(w *ResponseWriter) User {
+			w.SetStatus(201)
+			w.Header().Set("Location", "/users/1")
+			return User{Name: "Ann"}
+		}))
+		mux.HandleFunc("/empty", H(func(w http.ResponseWriter) {
+			w.(*ResponseWriter).SetStatus(202)
+			w.Header().Set("X-Job", "7")
+		}))
+		mux.HandleFunc("/error", H(func(w *ResponseWriter) (User, error) {
+			w.SetStatus(201)
+			return User{}, errors.New("user not found")
+		}))
+		srv := httptest.NewServer(mux)
+		defer srv.Close()
+
+		get := func(path string) *http.Response {
+			resp, err := srv.Client().Get(srv.URL + path)
+			if err != nil {
+				t.Fatalf("%s: request failed: %v", path, err)
+			}
+			resp.Body.Close()
+			return resp
+		}
+
+		resp := get("/json")
+		if resp.StatusCode != 201 {
+			t.Errorf("expected status 201, got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("Location") != "/users/1" || resp.Header.Get("Content-Type") != "application/json; charset=utf-8" {
+			t.Errorf("headers lost: %v", resp.Header)
+		}
+
+		resp = get("/empty")
+		if resp.StatusCode != 202 || resp.Header.Get("X-Job") != "7" {
+			t.Errorf("expected 202 with X-Job, got %d %v", resp.StatusCode, resp.Header)
+		}
+
+		if resp = get("/error"); resp.StatusCode != 404 {
+			t.Errorf("expected the error status 404, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("ignored after the header is written", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		rw := &ResponseWriter{ResponseWriter: rec}
+		rw.WriteHeader(200)
+		rw.SetStatus(500)
+		rw.commitStatus()
+		if rec.Code != 200 {
+			t.Errorf("expected status 200, got %d", rec.Code)
+		}
+	})
+}
+
+func TestResponseWriterFlush(t *testing.T) {
+	t.Run("handler flushes intermediate output", func(t *testing.T) {
+		handler := H(func(w http.ResponseWriter) {
+			flusher, ok := w.(http.Flusher)
+			if !ok {
+				t.Fatal("expected writer to implement http.Flusher")
+			}
+			w.Write([]byte("step 1\n"))
+			flusher.Flush()
+			w.Write([]byte("step 2\n"))
+			flusher.Flush()
+			w.Write([]byte("done"))
+		})
+
+		rec := newFlushRecorder()
+		handler(rec, httptest.NewRequest("GET", "/progress", nil))
+
+		snapshots := rec.snapshots()
+		if len(snapshots) != 2 {
+			t.Fatalf("expected 2 flushes, got %d", len(snapshots))
+		}
+		if snapshots[0] != "step 1\n" || snapshots[1] != "step 1\nstep 2\n" {
+			t.Errorf("unexpected flushed output: %q", snapshots)
+		}
+		if rec.Body.String() != "step 1\nstep 2\ndone" {
+			t.Errorf("unexpected body: %q", rec.Body.String())
+		}
+	})
+
+	t.Run("flush commits the status", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		rw := &ResponseWriter{ResponseWriter: rec}
+		rw.Flush()
+		if !rw.headerWritten || !rec.Flushed || rec.Code != 200 {
+			t.Errorf("unexpected state: written=%v flushed=%v code=%d", rw.headerWritten, rec.Flushed, rec.Code)
+		}
+	})
+
+	t.Run("flush without flusher support", func(t *testing.T) {
+		rw := &ResponseWriter{ResponseWriter: struct{ http.ResponseWriter }{httptest.NewRecorder()}}
+		rw.Flush() // must not panic
+	})
+}
+
+func TestResponderInterface(t *testing.T) {
+	handler := H(func() CustomResponder {
+		return CustomResponder{statusCode: 418, body: "I'm a teapot"}
+	})
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	handler(rec, req)
+	if rec.Code != 418 {
+		t.Errorf("expected status 418, got %d", rec.Code)
+	}
+	if rec.Body.String() != "I'm a teapot" {
+		t.Errorf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+// throttleError is both an error and a Responder
+type throttleError struct{}
+
+func (throttleError) Error() string { return "quota exceeded" }
+
+func (throttleError) Respond(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write([]byte("slow down"))
+}
+
+func TestErrorResponder(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler any
+	}{
+		{"as error", func() error { return throttleError{} }},
+		{"as concrete type", func() throttleError { return throttleError{} }},
+		{"as second value", func() (User, error) { return User{}, throttleError{} }},
+		{"in result", func() Result[User] { return Result[User]{Err: throttleError{}} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Reset()
+			rec := httptest.NewRecorder()
+			H(tt.handler)(rec, httptest.NewRequest("GET", "/", nil))
+			if rec.Code != 429 || rec.Body.String() != "slow down" {
+				t.Errorf("expected the error to respond itself, got %d %q", rec.Code, rec.Body.String())
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "text/plain" {
+				t.Errorf("unexpected content type %q", ct)
+			}
+		})
+	}
+
+	t.Run("takes precedence over the error handler", func(t *testing.T) {
+		Reset()
+		defer Reset()
+		Configure(WithErrorHandler(func(w http.ResponseWriter, err error) {
+			w.WriteHeader(500)
+		}))
 
-func TestResponderInterface(t *testing.T) {
-	handler := H(func() CustomResponder {
-		return CustomResponder{statusCode: 418, body: "I'm a teapot"}
+		rec := httptest.NewRecorder()
+		H(func() error { return throttleError{} })(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != 429 {
+			t.Errorf("expected status 429, got %d", rec.Code)
+		}
 	})
-	rec := httptest.NewRecorder()
-	req := httptest.NewRequest("GET", "/", nil)
-	handler(rec, req)
-	if rec.Code != 418 {
-		t.Errorf("expected status 418, got %d", rec.Code)
-	}
-	if rec.Body.String() != "I'm a teapot" {
-		t.Errorf("unexpected body: %s", rec.Body.String())
-	}
 }
 
 // ========== WriteHeaders Tests ==========
@@ -1111,6 +3166,89 @@ func TestWriteHeaders(t *testing.T) {
 
 // ========== Helper Function Tests ==========
 
+func TestOptionsHandler(t *testing.T) {
+	t.Run("allow header lists methods", func(t *testing.T) {
+		handler := OptionsHandler("GET", "post", "GET", "OPTIONS")
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("OPTIONS", "/users", nil))
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("expected status 204, got %d", rec.Code)
+		}
+		if allow := rec.Header().Get("Allow"); allow != "GET, HEAD, POST, OPTIONS" {
+			t.Errorf("unexpected Allow header: %q", allow)
+		}
+		if rec.Body.Len() != 0 {
+			t.Errorf("expected empty body, got %s", rec.Body.String())
+		}
+	})
+
+	t.Run("registered on a mux", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /users", H(func() string { return "users" }))
+		mux.HandleFunc("OPTIONS /users", OptionsHandler("GET"))
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("OPTIONS", "/users", nil))
+		if allow := rec.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS" {
+			t.Errorf("unexpected Allow header: %q", allow)
+		}
+	})
+
+	t.Run("HEAD is not listed twice", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		OptionsHandler("head", "GET")(rec, httptest.NewRequest("OPTIONS", "/users", nil))
+		if allow := rec.Header().Get("Allow"); allow != "HEAD, GET, OPTIONS" {
+			t.Errorf("unexpected Allow header: %q", allow)
+		}
+	})
+
+	t.Run("GET route answers HEAD and OPTIONS", func(t *testing.T) {
+		Reset()
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /users/{id}", H(func(id Path[int]) User {
+			return User{Name: "Alice", Email: "alice@example.com", Age: id.Value}
+		}))
+		mux.HandleFunc("OPTIONS /users/{id}", OptionsHandler("GET"))
+
+		srv := httptest.NewServer(mux)
+		defer srv.Close()
+
+		get, err := http.Get(srv.URL + "/users/30")
+		if err != nil {
+			t.Fatal(err)
+		}
+		getBody, _ := io.ReadAll(get.Body)
+		get.Body.Close()
+
+		head, err := http.Head(srv.URL + "/users/30")
+		if err != nil {
+			t.Fatal(err)
+		}
+		headBody, _ := io.ReadAll(head.Body)
+		head.Body.Close()
+
+		if head.StatusCode != 200 || len(headBody) != 0 {
+			t.Errorf("expected an empty 200 for HEAD, got %d %q", head.StatusCode, headBody)
+		}
+		if head.Header.Get("Content-Type") != get.Header.Get("Content-Type") {
+			t.Errorf("expected HEAD to carry the GET headers, got %v", head.Header)
+		}
+		if len(getBody) == 0 {
+			t.Error("expected GET to have a body")
+		}
+
+		req, _ := http.NewRequest("OPTIONS", srv.URL+"/users/30", nil)
+		options, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		options.Body.Close()
+		if options.StatusCode != 204 || options.Header.Get("Allow") != "GET, HEAD, OPTIONS" {
+			t.Errorf("unexpected OPTIONS response %d %q", options.StatusCode, options.Header.Get("Allow"))
+		}
+	})
+}
+
 func TestGetPointer(t *testing.T) {
 	t.Run("non-pointer value", func(t *testing.T) {
 		var x int = 42
@@ -1389,6 +3527,28 @@ func TestEdgeCases(t *testing.T) {
 		}
 	})
 
+	t.Run("NoResponse writes nothing", func(t *testing.T) {
+		var buf bytes.Buffer
+		Reset()
+		Configure(WithLogger(log.New(&buf, "", 0)))
+		defer Reset()
+
+		handler := H(func(w http.ResponseWriter) StatusCode {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("queued"))
+			return NoResponse
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("POST", "/", nil))
+
+		if rec.Code != http.StatusAccepted || rec.Body.String() != "queued" {
+			t.Errorf("expected handler's own response, got %d %q", rec.Code, rec.Body.String())
+		}
+		if buf.Len() != 0 {
+			t.Errorf("expected no warnings, got %q", buf.String())
+		}
+	})
+
 	t.Run("both return values nil", func(t *testing.T) {
 		handler := H(func() (*User, error) {
 			return nil, nil
@@ -1402,6 +3562,73 @@ func TestEdgeCases(t *testing.T) {
 	})
 }
 
+func TestH_NilPointer(t *testing.T) {
+	handlers := map[string]any{
+		"direct":            func() *User { return nil },
+		"with error":        func() (*User, error) { return nil, nil },
+		"result":            func() Result[*User] { return OK[*User](nil) },
+		"status":            func() Status[*User] { return WithStatus[*User](200, nil) },
+		"status with error": func() (Status[*User], error) { return WithStatus[*User](200, nil), nil },
+	}
+
+	for name, fn := range handlers {
+		t.Run(name+" default is empty", func(t *testing.T) {
+			Reset()
+			rec := httptest.NewRecorder()
+			H(fn)(rec, httptest.NewRequest("GET", "/", nil))
+			if rec.Code != 200 || rec.Body.Len() != 0 {
+				t.Errorf("expected empty 200, got %d %q", rec.Code, rec.Body.String())
+			}
+		})
+
+		t.Run(name+" as null", func(t *testing.T) {
+			Reset()
+			Configure(WithNilPointerAsNull(true))
+			defer Reset()
+
+			rec := httptest.NewRecorder()
+			H(fn)(rec, httptest.NewRequest("GET", "/", nil))
+			if rec.Code != 200 || strings.TrimSpace(rec.Body.String()) != "null" {
+				t.Errorf("expected null, got %d %q", rec.Code, rec.Body.String())
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+				t.Errorf("unexpected Content-Type %q", ct)
+			}
+		})
+	}
+
+	t.Run("non-nil pointer encodes the pointee", func(t *testing.T) {
+		Reset()
+		Configure(WithNilPointerAsNull(true))
+		defer Reset()
+
+		for _, fn := range []any{
+			func() *User { return &User{Name: "Ann"} },
+			func() Result[*User] { return OK(&User{Name: "Ann"}) },
+		} {
+			rec := httptest.NewRecorder()
+			H(fn)(rec, httptest.NewRequest("GET", "/", nil))
+			var user User
+			parseJSONResponse(t, rec.Body.Bytes(), &user)
+			if user.Name != "Ann" {
+				t.Errorf("unexpected user: %+v", user)
+			}
+		}
+	})
+
+	t.Run("nil error is still no response", func(t *testing.T) {
+		Reset()
+		Configure(WithNilPointerAsNull(true))
+		defer Reset()
+
+		rec := httptest.NewRecorder()
+		H(func() *PanicError { return nil })(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Body.Len() != 0 {
+			t.Errorf("expected empty body, got %q", rec.Body.String())
+		}
+	})
+}
+
 // ========== Configuration Tests ==========
 
 func TestDefaultConfig(t *testing.T) {
@@ -1495,6 +3722,7 @@ func TestInitialize(t *testing.T) {
 
 func TestConfigure(t *testing.T) {
 	t.Run("configure can be called multiple times", func(t *testing.T) {
+		requireValidator(t)
 		Reset()
 
 		Configure(WithValidation(false))
@@ -1604,137 +3832,609 @@ func TestConfigure(t *testing.T) {
 
 func TestReset(t *testing.T) {
 	t.Run("reset restores defaults", func(t *testing.T) {
+		requireValidator(t)
+		Reset()
+
+		// Change config
+		Configure(WithValidation(false))
+
+		// Reset to defaults
+		Reset()
+
+		// Validation should be enabled by default
+		type Request struct {
+			Email string `json:"email" validate:"required,email"`
+		}
+
+		handler := H(func(body JSON[Request]) Request {
+			return body.Value
+		})
+
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"email":"invalid"}`))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		if rec.Code == 200 {
+			t.Error("expected validation error after reset, got success")
+		}
+	})
+
+	t.Run("reset allows re-initialization", func(t *testing.T) {
+		requireValidator(t)
+		Reset()
+
+		Initialize(WithValidation(false))
+		Reset() // Reset also resets the sync.Once
+
+		// Should be able to Initialize again
+		Initialize(WithValidation(true))
+
+		// Verification through actual usage
+		type Request struct {
+			Email string `json:"email" validate:"required,email"`
+		}
+
+		handler := H(func(body JSON[Request]) Request {
+			return body.Value
+		})
+
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"email":"invalid"}`))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		if rec.Code == 200 {
+			t.Error("expected validation error, got success")
+		}
+	})
+}
+
+func TestCustomSchemaDecoder(t *testing.T) {
+	t.Run("custom schema decoder with alias tag", func(t *testing.T) {
+		Reset()
+
+		decoder := schema.NewDecoder()
+		decoder.SetAliasTag("form")
+		decoder.IgnoreUnknownKeys(true)
+
+		Initialize(
+			WithSchemaDecoder(decoder),
+		)
+
+		type QueryParams struct {
+			PageNum int `form:"page"`
+			Size    int `form:"size"`
+		}
+
+		handler := H(func(q Query[QueryParams]) QueryParams {
+			return q.Value
+		})
+
+		req := httptest.NewRequest("GET", "/?page=5&size=20", nil)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		if rec.Code != 200 {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+
+		var result QueryParams
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+
+		if result.PageNum != 5 || result.Size != 20 {
+			t.Errorf("expected page=5, size=20, got page=%d, size=%d", result.PageNum, result.Size)
+		}
+	})
+
+	t.Run("strict query, lenient form", func(t *testing.T) {
+		Reset()
+		defer Reset()
+
+		strict := schema.NewDecoder()
+		strict.IgnoreUnknownKeys(false)
+		Configure(WithQueryDecoder(strict))
+
+		type Search struct {
+			Q    string `schema:"q"`
+			Page int    `schema:"page"`
+		}
+
+		query := H(func(q Query[Search]) Search { return q.Value })
+		form := H(func(f Form[Search]) Search { return f.Value })
+
+		rec := httptest.NewRecorder()
+		query(rec, httptest.NewRequest("GET", "/?q=go&page=2", nil))
+		if rec.Code != 200 {
+			t.Errorf("expected status 200 for known keys, got %d", rec.Code)
+		}
+
+		rec = httptest.NewRecorder()
+		query(rec, httptest.NewRequest("GET", "/?q=go&pgae=2", nil))
+		if rec.Code != 400 {
+			t.Errorf("expected status 400 for an unknown query key, got %d", rec.Code)
+		}
+
+		rec = httptest.NewRecorder()
+		form(rec, postForm("/", url.Values{"q": {"go"}, "utm_source": {"mail"}}))
+		if rec.Code != 200 {
+			t.Errorf("expected the form to ignore unknown keys, got %d", rec.Code)
+		}
+	})
+
+	t.Run("form decoder replaces the schema decoder", func(t *testing.T) {
+		Reset()
+		defer Reset()
+
+		aliased := schema.NewDecoder()
+		aliased.SetAliasTag("form")
+		aliased.IgnoreUnknownKeys(true)
+		Configure(WithFormDecoder(aliased))
+
+		type Login struct {
+			User string `form:"user" schema:"username"`
+		}
+
+		var fromForm, fromQuery string
+		H(func(f Form[Login]) { fromForm = f.Value.User })(httptest.NewRecorder(), postForm("/", url.Values{"user": {"ann"}}))
+		H(func(q Query[Login]) { fromQuery = q.Value.User })(httptest.NewRecorder(), httptest.NewRequest("GET", "/?username=bob", nil))
+		if fromForm != "ann" || fromQuery != "bob" {
+			t.Errorf("expected form=ann and query=bob, got %q and %q", fromForm, fromQuery)
+		}
+	})
+}
+
+func TestRawJSON(t *testing.T) {
+	t.Run("written unchanged", func(t *testing.T) {
+		Reset()
+		cached := []byte(`{"name": "Alice",  "tags":["a","b"]}`)
+		rec := httptest.NewRecorder()
+		H(func() RawJSON { return cached })(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Code != 200 || !bytes.Equal(rec.Body.Bytes(), cached) {
+			t.Errorf("expected the bytes verbatim, got %d %q", rec.Code, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+	})
+
+	t.Run("with a status", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		H(func() Result[RawJSON] {
+			return Result[RawJSON]{Code: 201, Data: RawJSON(`{"id":1}`)}
+		})(rec, httptest.NewRequest("POST", "/", nil))
+
+		if rec.Code != 201 || rec.Body.String() != `{"id":1}` {
+			t.Errorf("unexpected response %d %q", rec.Code, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+	})
+
+	t.Run("malformed JSON is logged with response validation", func(t *testing.T) {
+		for _, enabled := range []bool{false, true} {
+			Reset()
+			var buf bytes.Buffer
+			Configure(WithLogger(log.New(&buf, "", 0)), WithResponseValidation(enabled))
+
+			rec := httptest.NewRecorder()
+			H(func() RawJSON { return RawJSON(`{"broken"`) })(rec, httptest.NewRequest("GET", "/", nil))
+
+			if rec.Body.String() != `{"broken"` {
+				t.Errorf("expected the bytes to be sent anyway, got %q", rec.Body.String())
+			}
+			if logged := strings.Contains(buf.String(), "not valid JSON"); logged != enabled {
+				t.Errorf("validation %v: expected warning %v, got %q", enabled, enabled, buf.String())
+			}
+		}
+		Reset()
+	})
+}
+
+func TestXMLNegotiation(t *testing.T) {
+	type Book struct {
+		XMLName xml.Name `xml:"book" json:"-"`
+		Title   string   `xml:"title" json:"title"`
+	}
+	handler := H(func() Book { return Book{Title: "Dune"} })
+
+	serve := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/book", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	t.Run("JSON only by default", func(t *testing.T) {
 		Reset()
+		rec := serve("application/xml")
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("expected JSON without XMLMarshal, got %q", ct)
+		}
+	})
+
+	Reset()
+	Configure(WithXMLMarshal(xml.Marshal))
+	defer Reset()
+
+	tests := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"", "application/json; charset=utf-8", `{"title":"Dune"}`},
+		{"application/json", "application/json; charset=utf-8", `{"title":"Dune"}`},
+		{"*/*", "application/json; charset=utf-8", `{"title":"Dune"}`},
+		{"text/html", "application/json; charset=utf-8", `{"title":"Dune"}`},
+		{"application/xml", "application/xml; charset=utf-8", xml.Header + "<book><title>Dune</title></book>"},
+		{"text/xml", "text/xml; charset=utf-8", xml.Header + "<book><title>Dune</title></book>"},
+		{"application/json;q=0.5, application/xml", "application/xml; charset=utf-8", xml.Header + "<book><title>Dune</title></book>"},
+	}
+	for _, tt := range tests {
+		t.Run("accept "+tt.accept, func(t *testing.T) {
+			rec := serve(tt.accept)
+			if ct := rec.Header().Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("expected content type %q, got %q", tt.contentType, ct)
+			}
+			if rec.Body.String() != tt.body {
+				t.Errorf("unexpected body %q", rec.Body.String())
+			}
+			if rec.Header().Get("Vary") != "Accept" {
+				t.Errorf("expected Vary: Accept, got %q", rec.Header().Get("Vary"))
+			}
+		})
+	}
+
+	t.Run("result status is kept", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/book", nil)
+		req.Header.Set("Accept", "application/xml")
+		H(func() Result[Book] { return OK(Book{Title: "Dune"}).WithStatus(201) })(rec, req)
+		if rec.Code != 201 || !strings.HasSuffix(rec.Body.String(), "<book><title>Dune</title></book>") {
+			t.Errorf("unexpected response %d %q", rec.Code, rec.Body.String())
+		}
+	})
+}
 
-		// Change config
-		Configure(WithValidation(false))
+func TestLargeIntsAsStrings(t *testing.T) {
+	data := map[string]any{
+		"safe":     int64(1<<53 - 1),
+		"above":    int64(1<<53 + 1),
+		"negative": int64(-(1<<53 + 1)),
+		"max":      uint64(math.MaxUint64),
+		"float":    1.5,
+		"text":     "id 9007199254740993",
+		"nested":   []any{map[string]int64{"id": 1 << 60}},
+	}
+	handler := H(func() map[string]any { return data })
 
-		// Reset to defaults
+	t.Run("disabled by default", func(t *testing.T) {
 		Reset()
-
-		// Validation should be enabled by default
-		type Request struct {
-			Email string `json:"email" validate:"required,email"`
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+		if !strings.Contains(rec.Body.String(), `"above":9007199254740993`) {
+			t.Errorf("expected a plain number, got %s", rec.Body.String())
 		}
+	})
 
-		handler := H(func(body JSON[Request]) Request {
-			return body.Value
-		})
+	t.Run("quotes integers beyond 2^53", func(t *testing.T) {
+		Reset()
+		Configure(WithLargeIntsAsStrings(true))
+		defer Reset()
 
-		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"email":"invalid"}`))
 		rec := httptest.NewRecorder()
-		handler(rec, req)
+		handler(rec, httptest.NewRequest("GET", "/", nil))
 
-		if rec.Code == 200 {
-			t.Error("expected validation error after reset, got success")
+		expected := `{"above":"9007199254740993","float":1.5,"max":"18446744073709551615",` +
+			`"negative":"-9007199254740993","nested":[{"id":"1152921504606846976"}],` +
+			`"safe":9007199254740991,"text":"id 9007199254740993"}`
+		if body := strings.TrimSpace(rec.Body.String()); body != expected {
+			t.Errorf("unexpected body:\n%s\nexpected:\n%s", body, expected)
 		}
 	})
+}
 
-	t.Run("reset allows re-initialization", func(t *testing.T) {
+func TestQuoteLargeInts(t *testing.T) {
+	tests := []struct {
+		input, expected string
+	}{
+		{`9007199254740991`, `9007199254740991`},
+		{`9007199254740992`, `"9007199254740992"`},
+		{`[1,-9007199254740992,2]`, `[1,"-9007199254740992",2]`},
+		{`{"a":99999999999999999999999}`, `{"a":"99999999999999999999999"}`},
+		{`[1e300,12345678901234567.5]`, `[1e300,12345678901234567.5]`},
+		{`{"k\"9007199254740993":"9007199254740993"}`, `{"k\"9007199254740993":"9007199254740993"}`},
+	}
+	for _, tt := range tests {
+		if got := string(quoteLargeInts([]byte(tt.input))); got != tt.expected {
+			t.Errorf("quoteLargeInts(%s) = %s, expected %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestResponseValidation(t *testing.T) {
+	type Profile struct {
+		Name  string `json:"name" validate:"required"`
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	run := func(enabled bool, fn any) (string, *httptest.ResponseRecorder) {
 		Reset()
+		var buf bytes.Buffer
+		Configure(
+			WithLogger(log.New(&buf, "", 0)),
+			WithResponseValidation(enabled),
+		)
+		defer Reset()
 
-		Initialize(WithValidation(false))
-		Reset() // Reset also resets the sync.Once
+		rec := httptest.NewRecorder()
+		H(fn)(rec, httptest.NewRequest("GET", "/", nil))
+		return buf.String(), rec
+	}
 
-		// Should be able to Initialize again
-		Initialize(WithValidation(true))
+	t.Run("Result data failing validation", func(t *testing.T) {
+		requireValidator(t)
+		logs, rec := run(true, func() Result[Profile] {
+			return OK(Profile{Name: "Alice", Email: "not-an-email"}).WithStatus(201)
+		})
+		if !strings.Contains(logs, "email must be a valid email address") {
+			t.Errorf("expected validation warning, got %q", logs)
+		}
+		if rec.Code != 201 {
+			t.Errorf("expected response to be sent anyway, got %d", rec.Code)
+		}
+	})
 
-		// Verification through actual usage
-		type Request struct {
-			Email string `json:"email" validate:"required,email"`
+	t.Run("Result with pointer data", func(t *testing.T) {
+		requireValidator(t)
+		logs, _ := run(true, func() Result[*Profile] {
+			return OK(&Profile{Email: "alice@example.com"})
+		})
+		if !strings.Contains(logs, "name is required") {
+			t.Errorf("expected validation warning, got %q", logs)
 		}
+	})
 
-		handler := H(func(body JSON[Request]) Request {
-			return body.Value
+	t.Run("bare struct", func(t *testing.T) {
+		requireValidator(t)
+		logs, _ := run(true, func() Profile {
+			return Profile{}
 		})
+		if !strings.Contains(logs, "failed validation") {
+			t.Errorf("expected validation warning, got %q", logs)
+		}
+	})
 
-		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"email":"invalid"}`))
-		rec := httptest.NewRecorder()
-		handler(rec, req)
+	t.Run("valid data", func(t *testing.T) {
+		logs, _ := run(true, func() Result[Profile] {
+			return OK(Profile{Name: "Alice", Email: "alice@example.com"})
+		})
+		if logs != "" {
+			t.Errorf("expected no warning, got %q", logs)
+		}
+	})
 
-		if rec.Code == 200 {
-			t.Error("expected validation error, got success")
+	t.Run("disabled by default", func(t *testing.T) {
+		logs, _ := run(false, func() Result[Profile] {
+			return OK(Profile{})
+		})
+		if logs != "" {
+			t.Errorf("expected no warning, got %q", logs)
 		}
 	})
 }
 
-func TestCustomValidator(t *testing.T) {
-	t.Run("custom validator with custom rule", func(t *testing.T) {
-		Reset()
+func TestResponseTransform(t *testing.T) {
+	type envelope struct {
+		Data json.RawMessage `json:"data"`
+		Meta struct {
+			Status int `json:"status"`
+		} `json:"meta"`
+	}
 
-		v := validator.New()
-		v.RegisterValidation("isalice", func(fl validator.FieldLevel) bool {
-			return fl.Field().String() == "alice"
-		})
+	Reset()
+	Configure(WithResponseTransform(func(status int, data any) any {
+		return map[string]any{"data": data, "meta": map[string]any{"status": status}}
+	}))
+	defer Reset()
 
-		Initialize(
-			WithValidator(v),
-		)
+	t.Run("wraps struct return", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		H(func() User { return User{Name: "Alice"} })(rec, httptest.NewRequest("GET", "/", nil))
 
-		type Request struct {
-			Username string `json:"username" validate:"required,isalice"`
+		var env envelope
+		parseJSONResponse(t, rec.Body.Bytes(), &env)
+		var user User
+		parseJSONResponse(t, env.Data, &user)
+		if user.Name != "Alice" || env.Meta.Status != 200 {
+			t.Errorf("unexpected envelope: %s", rec.Body.String())
 		}
+	})
 
-		handler := H(func(body JSON[Request]) Request {
-			return body.Value
-		})
-
-		// Test valid case
-		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"username":"alice"}`))
+	t.Run("wraps Result data with its status", func(t *testing.T) {
 		rec := httptest.NewRecorder()
-		handler(rec, req)
+		H(func() Result[User] {
+			return Result[User]{Code: 201, Data: User{Name: "Bob"}}
+		})(rec, httptest.NewRequest("POST", "/", nil))
 
-		if rec.Code != 200 {
-			t.Errorf("expected status 200 for valid username, got %d", rec.Code)
+		if rec.Code != 201 {
+			t.Errorf("expected status 201, got %d", rec.Code)
+		}
+		var env envelope
+		parseJSONResponse(t, rec.Body.Bytes(), &env)
+		if env.Meta.Status != 201 {
+			t.Errorf("expected meta status 201, got %d", env.Meta.Status)
+		}
+	})
+
+	t.Run("leaves non-JSON responses and errors alone", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		H(func() string { return "plain" })(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Body.String() != "plain" {
+			t.Errorf("unexpected body: %s", rec.Body.String())
 		}
 
-		// Test invalid case
-		req = httptest.NewRequest("POST", "/", strings.NewReader(`{"username":"bob"}`))
 		rec = httptest.NewRecorder()
-		handler(rec, req)
+		H(func() (User, error) {
+			return User{}, &HTTPError{Code: 404, Err: "not_found"}
+		})(rec, httptest.NewRequest("GET", "/", nil))
 
-		if rec.Code == 200 {
-			t.Error("expected validation error for invalid username")
+		var httpErr HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+		if httpErr.Err != "not_found" {
+			t.Errorf("expected unwrapped error, got %s", rec.Body.String())
 		}
 	})
 }
 
-func TestCustomSchemaDecoder(t *testing.T) {
-	t.Run("custom schema decoder with alias tag", func(t *testing.T) {
-		Reset()
+func TestResponseProjection(t *testing.T) {
+	// Only admins may see email addresses
+	hideEmail := func(r *http.Request, data any) any {
+		if r.Header.Get("X-Role") == "admin" {
+			return data
+		}
+		switch v := data.(type) {
+		case User:
+			v.Email = ""
+			return v
+		case []User:
+			projected := make([]User, len(v))
+			for i, u := range v {
+				u.Email = ""
+				projected[i] = u
+			}
+			return projected
+		}
+		return data
+	}
 
-		decoder := schema.NewDecoder()
-		decoder.SetAliasTag("form")
-		decoder.IgnoreUnknownKeys(true)
+	Reset()
+	Configure(WithResponseProjection(hideEmail))
+	defer Reset()
 
-		Initialize(
-			WithSchemaDecoder(decoder),
-		)
+	alice := User{Name: "Alice", Email: "alice@example.com"}
+	call := func(handler any, role string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Role", role)
+		rec := httptest.NewRecorder()
+		H(handler)(rec, req)
+		return rec
+	}
 
-		type QueryParams struct {
-			PageNum int `form:"page"`
-			Size    int `form:"size"`
-		}
+	tests := []struct {
+		name    string
+		handler any
+	}{
+		{"struct", func() User { return alice }},
+		{"two values", func() (User, error) { return alice, nil }},
+		{"result", func() Result[User] { return Result[User]{Code: 201, Data: alice} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var user User
+			parseJSONResponse(t, call(tt.handler, "member").Body.Bytes(), &user)
+			if user.Name != "Alice" || user.Email != "" {
+				t.Errorf("expected the email to be hidden, got %+v", user)
+			}
 
-		handler := H(func(q Query[QueryParams]) QueryParams {
-			return q.Value
+			parseJSONResponse(t, call(tt.handler, "admin").Body.Bytes(), &user)
+			if user.Email != alice.Email {
+				t.Errorf("expected the email for admins, got %+v", user)
+			}
 		})
+	}
 
-		req := httptest.NewRequest("GET", "/?page=5&size=20", nil)
+	t.Run("slice", func(t *testing.T) {
+		var users []User
+		parseJSONResponse(t, call(func() []User { return []User{alice, alice} }, "member").Body.Bytes(), &users)
+		if len(users) != 2 || users[0].Email != "" || users[1].Email != "" {
+			t.Errorf("expected the emails to be hidden, got %+v", users)
+		}
+	})
+
+	t.Run("runs before the response transform", func(t *testing.T) {
+		Configure(WithResponseTransform(func(status int, data any) any {
+			return map[string]any{"data": data}
+		}))
+		defer Configure(WithResponseTransform(nil))
+
+		var env struct{ Data User }
+		parseJSONResponse(t, call(func() User { return alice }, "member").Body.Bytes(), &env)
+		if env.Data.Name != "Alice" || env.Data.Email != "" {
+			t.Errorf("expected a projected user in the envelope, got %+v", env)
+		}
+	})
+}
+
+func TestBeforeHandler(t *testing.T) {
+	type Transfer struct {
+		Account string `json:"account"`
+		Amount  int    `json:"amount"`
+	}
+
+	called := false
+	handler := H(func(owner Path[string], body JSON[Transfer]) string {
+		called = true
+		return "ok"
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /users/{owner}/transfers", handler)
+
+	// Only the owner of an account may transfer from it
+	Reset()
+	Configure(WithBeforeHandler(func(r *http.Request, args []any) error {
+		owner := args[0].(Path[string])
+		body := args[1].(JSON[Transfer])
+		if body.Value.Account != owner.Value {
+			return &HTTPError{Code: 403, Err: "forbidden", Message: "not your account"}
+		}
+		return nil
+	}))
+	defer Reset()
+
+	send := func(path, body string) *httptest.ResponseRecorder {
+		called = false
 		rec := httptest.NewRecorder()
-		handler(rec, req)
+		mux.ServeHTTP(rec, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		return rec
+	}
 
-		if rec.Code != 200 {
-			t.Fatalf("expected status 200, got %d", rec.Code)
+	t.Run("aborts", func(t *testing.T) {
+		rec := send("/users/alice/transfers", `{"account":"bob","amount":10}`)
+		if rec.Code != 403 {
+			t.Errorf("expected status 403, got %d", rec.Code)
 		}
+		if called {
+			t.Error("handler should not run when the hook fails")
+		}
+	})
 
-		var result QueryParams
-		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
-			t.Fatalf("failed to unmarshal response: %v", err)
+	t.Run("permits", func(t *testing.T) {
+		rec := send("/users/alice/transfers", `{"account":"alice","amount":10}`)
+		if rec.Code != 200 || rec.Body.String() != "ok" {
+			t.Errorf("expected handler response, got %d %s", rec.Code, rec.Body.String())
+		}
+		if !called {
+			t.Error("handler should run when the hook passes")
 		}
+	})
 
-		if result.PageNum != 5 || result.Size != 20 {
-			t.Errorf("expected page=5, size=20, got page=%d, size=%d", result.PageNum, result.Size)
+	t.Run("not run when extraction fails", func(t *testing.T) {
+		hookRan := false
+		Configure(WithBeforeHandler(func(r *http.Request, args []any) error {
+			hookRan = true
+			return nil
+		}))
+
+		rec := send("/users/alice/transfers", `{bad json`)
+		if rec.Code != 400 || hookRan {
+			t.Errorf("expected 400 without running the hook, got %d (hook ran: %v)", rec.Code, hookRan)
 		}
 	})
 }
@@ -1792,6 +4492,155 @@ func TestCustomErrorHandler(t *testing.T) {
 	})
 }
 
+func TestErrorEncoder(t *testing.T) {
+	envelope := func(w http.ResponseWriter, e *HTTPError) error {
+		return json.NewEncoder(w).Encode(map[string]any{"data": nil, "error": e})
+	}
+
+	t.Run("wraps the error", func(t *testing.T) {
+		Reset()
+		Configure(WithErrorEncoder(envelope))
+		defer Reset()
+
+		rec := httptest.NewRecorder()
+		H(func() (User, error) { return User{}, NotFound("no such user") })(rec, httptest.NewRequest("GET", "/users/7", nil))
+
+		if rec.Code != 404 {
+			t.Errorf("expected status 404, got %d", rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		expected := `{"data":null,"error":{"code":404,"error":"not_found","message":"no such user"}}` + "\n"
+		if rec.Body.String() != expected {
+			t.Errorf("expected %q, got %q", expected, rec.Body.String())
+		}
+	})
+
+	t.Run("receives the mapped error", func(t *testing.T) {
+		Reset()
+		var got []*HTTPError
+		Configure(WithErrorEncoder(func(w http.ResponseWriter, e *HTTPError) error {
+			got = append(got, e)
+			return envelope(w, e)
+		}))
+		defer Reset()
+
+		H(func() error { return errors.New("user not found") })(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		H(func(body JSON[User]) User { return body.Value })(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("{")))
+
+		if len(got) != 2 {
+			t.Fatalf("expected the encoder to run twice, got %d", len(got))
+		}
+		if got[0].Code != 404 {
+			t.Errorf("expected the inferred status 404, got %+v", got[0])
+		}
+		if got[1].Code != 400 || got[1].Err != "invalid_json_syntax" {
+			t.Errorf("expected an extraction error, got %+v", got[1])
+		}
+	})
+
+	t.Run("can set headers", func(t *testing.T) {
+		Reset()
+		Configure(WithErrorEncoder(func(w http.ResponseWriter, e *HTTPError) error {
+			w.Header().Set("Content-Type", "application/vnd.api+json")
+			return envelope(w, e)
+		}))
+		defer Reset()
+
+		rec := httptest.NewRecorder()
+		H(func() error { return Conflict("taken") })(rec, httptest.NewRequest("POST", "/", nil))
+		if rec.Code != 409 {
+			t.Errorf("expected status 409, got %d", rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/vnd.api+json" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+	})
+
+	t.Run("error handler takes precedence", func(t *testing.T) {
+		Reset()
+		encoded := false
+		Configure(
+			WithErrorEncoder(func(w http.ResponseWriter, e *HTTPError) error {
+				encoded = true
+				return nil
+			}),
+			WithErrorHandler(func(w http.ResponseWriter, err error) { w.WriteHeader(418) }),
+		)
+		defer Reset()
+
+		rec := httptest.NewRecorder()
+		H(func() error { return NotFound("") })(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != 418 || encoded {
+			t.Errorf("expected the error handler alone to run, got %d (encoded %v)", rec.Code, encoded)
+		}
+	})
+}
+
+func TestErrorTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("error").Parse(`<h1>{{.Code}} {{.Err}}</h1><p>{{.Message}}</p>`))
+	handler := H(func() (User, error) { return User{}, NotFound("no <such> user") })
+
+	serve := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/users/7", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	Reset()
+	Configure(WithErrorTemplate(tmpl))
+	defer Reset()
+
+	t.Run("HTML for browsers", func(t *testing.T) {
+		rec := serve("text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		if rec.Code != 404 {
+			t.Errorf("expected status 404, got %d", rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		if body := rec.Body.String(); body != "<h1>404 not_found</h1><p>no &lt;such&gt; user</p>" {
+			t.Errorf("unexpected body %q", body)
+		}
+		if rec.Header().Get("Vary") != "Accept" {
+			t.Errorf("expected Vary: Accept, got %q", rec.Header().Get("Vary"))
+		}
+	})
+
+	for _, accept := range []string{"", "application/json", "*/*", "application/json, text/html;q=0.5"} {
+		t.Run("JSON for accept "+accept, func(t *testing.T) {
+			rec := serve(accept)
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+				t.Errorf("unexpected content type %q", ct)
+			}
+			var body HTTPError
+			parseJSONResponse(t, rec.Body.Bytes(), &body)
+			if rec.Code != 404 || body.Err != "not_found" {
+				t.Errorf("unexpected response %d %+v", rec.Code, body)
+			}
+		})
+	}
+
+	t.Run("falls back to JSON when rendering fails", func(t *testing.T) {
+		var buf bytes.Buffer
+		broken := template.Must(template.New("error").Parse(`{{.Missing}}`))
+		Configure(WithErrorTemplate(broken), WithLogger(log.New(&buf, "", 0)))
+
+		rec := serve("text/html")
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" || rec.Code != 404 {
+			t.Errorf("expected a JSON 404, got %d %q", rec.Code, ct)
+		}
+		if !strings.Contains(buf.String(), "failed to render error template") {
+			t.Errorf("expected the failure to be logged, got %q", buf.String())
+		}
+	})
+}
+
 func TestConfigThreadSafety(t *testing.T) {
 	t.Run("concurrent configure calls", func(t *testing.T) {
 		Reset()
@@ -1860,6 +4709,7 @@ func TestConfigThreadSafety(t *testing.T) {
 
 func TestConfigWithValidation(t *testing.T) {
 	t.Run("validation enabled by default", func(t *testing.T) {
+		requireValidator(t)
 		Reset()
 
 		type Request struct {
@@ -1881,7 +4731,7 @@ func TestConfigWithValidation(t *testing.T) {
 
 		var errResp map[string]any
 		json.Unmarshal(rec.Body.Bytes(), &errResp)
-		if !strings.Contains(errResp["message"].(string), "email") {
+		if msg, _ := errResp["message"].(string); !strings.Contains(msg, "email") {
 			t.Error("expected email validation error message")
 		}
 	})
@@ -1909,60 +4759,89 @@ func TestConfigWithValidation(t *testing.T) {
 	})
 }
 
-func TestCompleteConfigurationScenario(t *testing.T) {
-	t.Run("full custom configuration", func(t *testing.T) {
-		Reset()
-
-		var logBuf bytes.Buffer
-		customLogger := log.New(&logBuf, "[CUSTOM] ", 0)
-
-		v := validator.New()
-		v.RegisterValidation("positive", func(fl validator.FieldLevel) bool {
-			return fl.Field().Int() > 0
-		})
+func TestValidationReportsAllFields(t *testing.T) {
+	requireValidator(t)
+	type Signup struct {
+		Name  string `json:"name" schema:"name" validate:"required"`
+		Email string `json:"email" schema:"email" validate:"required,email"`
+		Age   int    `json:"age" schema:"age" validate:"gte=18"`
+	}
+	const expected = "name is required; email must be a valid email address; age must be greater than or equal to 18"
 
-		decoder := schema.NewDecoder()
-		decoder.IgnoreUnknownKeys(true)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		req     *http.Request
+	}{
+		{
+			"JSON",
+			H(func(body JSON[Signup]) Signup { return body.Value }),
+			httptest.NewRequest("POST", "/", strings.NewReader(`{"email":"nope","age":12}`)),
+		},
+		{
+			"Query",
+			H(func(q Query[Signup]) Signup { return q.Value }),
+			httptest.NewRequest("GET", "/?email=nope&age=12", nil),
+		},
+		{
+			"Form",
+			H(func(f Form[Signup]) Signup { return f.Value }),
+			postForm("/", url.Values{"email": {"nope"}, "age": {"12"}}),
+		},
+	}
 
-		errorHandlerCalled := false
+	Reset()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler(rec, tt.req)
+			if rec.Code != 400 {
+				t.Fatalf("expected status 400, got %d", rec.Code)
+			}
 
-		Initialize(
-			WithLogger(customLogger),
-			WithValidator(v),
-			WithSchemaDecoder(decoder),
-			WithValidation(true),
-			WithJSONMarshal(func(v any) ([]byte, error) {
-				return json.MarshalIndent(v, "", "  ")
-			}),
-			WithErrorHandler(func(w http.ResponseWriter, err error) {
-				errorHandlerCalled = true
-				w.WriteHeader(400)
-				json.NewEncoder(w).Encode(map[string]string{
-					"error": "custom handler",
-				})
-			}),
-		)
+			var httpErr HTTPError
+			parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+			if httpErr.Message != expected {
+				t.Errorf("unexpected message: %s", httpErr.Message)
+			}
+		})
+	}
 
-		// Test that custom config is used
-		type Request struct {
-			Count int `json:"count" validate:"required,positive"`
+	t.Run("schema tag names Query fields", func(t *testing.T) {
+		type Filter struct {
+			Page int `schema:"page" validate:"min=1"`
 		}
+		var q Query[Filter]
+		err := q.Extract(httptest.NewRequest("GET", "/?page=0", nil))
+		if msg := ToHTTPError(err).Message; msg != "page must be at least 1" {
+			t.Errorf("unexpected message: %s", msg)
+		}
+	})
 
-		handler := H(func(body JSON[Request]) Request {
-			return body.Value
-		})
-
-		// Invalid request (negative number)
-		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"count":-5}`))
-		rec := httptest.NewRecorder()
-		handler(rec, req)
+	t.Run("Query and Form conversion errors are all reported", func(t *testing.T) {
+		var q Query[Signup]
+		err := q.Extract(httptest.NewRequest("GET", "/?age=old&name=x&email=a@b.co", nil))
+		if msg := ToHTTPError(err).Message; msg != "age: invalid value" {
+			t.Errorf("unexpected message: %s", msg)
+		}
 
-		if !errorHandlerCalled {
-			t.Error("custom error handler should have been called")
+		type Numbers struct {
+			A int `schema:"a"`
+			B int `schema:"b"`
 		}
+		var f Form[Numbers]
+		err = f.Extract(postForm("/", url.Values{"a": {"x"}, "b": {"y"}}))
+		if msg := ToHTTPError(err).Message; msg != "a: invalid value; b: invalid value" {
+			t.Errorf("unexpected message: %s", msg)
+		}
+	})
 
-		if rec.Code != 400 {
-			t.Errorf("expected status 400, got %d", rec.Code)
+	t.Run("JSON type errors stop at the first", func(t *testing.T) {
+		var j JSON[Signup]
+		err := j.Extract(httptest.NewRequest("POST", "/", strings.NewReader(`{"name":1,"age":"x"}`)))
+		httpErr := ToHTTPError(err)
+		if httpErr.Err != "invalid_json_type" || !strings.Contains(httpErr.Message, `"name"`) {
+			t.Errorf("unexpected error: %+v", httpErr)
 		}
 	})
 }
@@ -0,0 +1,74 @@
+package m
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+)
+
+// Part is one part of a Multipart response
+type Part struct {
+	Header textproto.MIMEHeader
+	// Body is copied into the part as it is read; it is closed afterwards if it is an io.Closer
+	Body io.Reader
+}
+
+// Multipart is a response that writes its parts as a multipart body, e.g. a file
+// together with its metadata. Subtype defaults to "mixed" and Boundary to a random one.
+// Parts are streamed in order and flushed one by one; if a part fails the body is
+// left unterminated, since the earlier parts have already been sent
+type Multipart struct {
+	Subtype  string
+	Boundary string
+	Parts    []Part
+}
+
+func (mp Multipart) Respond(w http.ResponseWriter) {
+	mw := multipart.NewWriter(w)
+	if mp.Boundary != "" {
+		if err := mw.SetBoundary(mp.Boundary); err != nil {
+			logger().Printf("invalid multipart boundary %q: %v", mp.Boundary, err)
+			if e := handleError(w, &HTTPError{Code: 500, Err: "internal_error"}); e != nil {
+				logger().Printf("failed to write error response: %v", e)
+			}
+			return
+		}
+	}
+
+	subtype := mp.Subtype
+	if subtype == "" {
+		subtype = "mixed"
+	}
+	w.Header().Set("Content-Type", "multipart/"+subtype+"; boundary="+mw.Boundary())
+
+	rc := http.NewResponseController(w)
+	for i, part := range mp.Parts {
+		if err := writePart(mw, part); err != nil {
+			logger().Printf("failed to write multipart part %d: %v", i, err)
+			return
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			logger().Printf("failed to flush multipart response: %v", err)
+			return
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		logger().Printf("failed to write multipart response: %v", err)
+	}
+}
+
+func writePart(mw *multipart.Writer, part Part) error {
+	if c, ok := part.Body.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	pw, err := mw.CreatePart(part.Header)
+	if err != nil || part.Body == nil {
+		return err
+	}
+	_, err = io.Copy(pw, part.Body)
+	return err
+}
@@ -0,0 +1,141 @@
+package m
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// failingReader returns some data and then an error
+type failingReader struct {
+	data string
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if f.data == "" {
+		return 0, errors.New("disk read failed")
+	}
+	n := copy(p, f.data)
+	f.data = f.data[n:]
+	return n, nil
+}
+
+func readParts(t *testing.T, rec *httptest.ResponseRecorder, subtype string) ([]*multipart.Part, []string, error) {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/"+subtype {
+		t.Fatalf("unexpected Content-Type %q", rec.Header().Get("Content-Type"))
+	}
+
+	mr := multipart.NewReader(rec.Body, params["boundary"])
+	var parts []*multipart.Part
+	var bodies []string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return parts, bodies, nil
+		}
+		if err != nil {
+			return parts, bodies, err
+		}
+		body, err := io.ReadAll(p)
+		if err != nil {
+			return parts, bodies, err
+		}
+		parts = append(parts, p)
+		bodies = append(bodies, string(body))
+	}
+}
+
+func TestMultipart(t *testing.T) {
+	t.Run("mixed parts", func(t *testing.T) {
+		Reset()
+		handler := H(func() Multipart {
+			return Multipart{Parts: []Part{
+				{
+					Header: textproto.MIMEHeader{"Content-Type": {"application/json"}},
+					Body:   strings.NewReader(`{"name":"report.csv","size":11}`),
+				},
+				{
+					Header: textproto.MIMEHeader{
+						"Content-Type":        {"text/csv"},
+						"Content-Disposition": {`attachment; filename="report.csv"`},
+					},
+					Body: io.NopCloser(strings.NewReader("a,b\n1,2\n3,4")),
+				},
+			}}
+		})
+
+		rec := newFlushRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+
+		parts, bodies, err := readParts(t, rec.ResponseRecorder, "mixed")
+		if err != nil {
+			t.Fatalf("invalid multipart body: %v", err)
+		}
+		if len(parts) != 2 {
+			t.Fatalf("expected 2 parts, got %d", len(parts))
+		}
+		if parts[0].Header.Get("Content-Type") != "application/json" || bodies[0] != `{"name":"report.csv","size":11}` {
+			t.Errorf("unexpected first part: %v %q", parts[0].Header, bodies[0])
+		}
+		if parts[1].FileName() != "report.csv" || bodies[1] != "a,b\n1,2\n3,4" {
+			t.Errorf("unexpected second part: %v %q", parts[1].Header, bodies[1])
+		}
+		if n := len(rec.snapshots()); n != 2 {
+			t.Errorf("expected a flush per part, got %d", n)
+		}
+	})
+
+	t.Run("subtype and boundary", func(t *testing.T) {
+		Reset()
+		handler := H(func() Multipart {
+			return Multipart{Subtype: "related", Boundary: "frontier", Parts: []Part{{Body: strings.NewReader("x")}}}
+		})
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+		if ct := rec.Header().Get("Content-Type"); ct != "multipart/related; boundary=frontier" {
+			t.Errorf("unexpected Content-Type %q", ct)
+		}
+		if _, bodies, err := readParts(t, rec, "related"); err != nil || len(bodies) != 1 || bodies[0] != "x" {
+			t.Errorf("unexpected parts %q: %v", bodies, err)
+		}
+	})
+
+	t.Run("invalid boundary", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		H(func() Multipart {
+			return Multipart{Boundary: "bad boundary\n"}
+		})(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != 500 {
+			t.Errorf("expected status 500, got %d", rec.Code)
+		}
+	})
+
+	t.Run("failing part leaves the body unterminated", func(t *testing.T) {
+		Reset()
+		rec := httptest.NewRecorder()
+		H(func() Multipart {
+			return Multipart{Parts: []Part{
+				{Body: strings.NewReader("first")},
+				{Body: &failingReader{data: "partial"}},
+				{Body: strings.NewReader("never sent")},
+			}}
+		})(rec, httptest.NewRequest("GET", "/", nil))
+
+		_, bodies, err := readParts(t, rec, "mixed")
+		if err == nil {
+			t.Error("expected the multipart body to be incomplete")
+		}
+		if len(bodies) != 1 || bodies[0] != "first" {
+			t.Errorf("expected only the first part to be complete, got %q", bodies)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package m
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+)
+
+// TypeDiscriminator is the JSON key naming the concrete type of a Polymorphic value
+const TypeDiscriminator = "@type"
+
+// Polymorphic holds a value of interface type I decoded from a JSON object whose
+// "@type" key names the concrete type, as registered with WithPolymorphicType.
+// Use it for struct fields that encoding/json cannot decode on its own:
+//
+//	type Drawing struct {
+//		Shape m.Polymorphic[Shape] `json:"shape"` // {"@type": "circle", "radius": 2}
+//	}
+//
+// When encoded, the "@type" key is written back first
+type Polymorphic[I any] struct {
+	Value I
+}
+
+// WithPolymorphicType registers T as the concrete type of interface I named name.
+// Values are stored as a T if T implements I, and as a *T otherwise
+func WithPolymorphicType[I, T any](name string) Option {
+	iface, concrete := reflect.TypeFor[I](), reflect.TypeFor[T]()
+	if iface.Kind() != reflect.Interface {
+		log.Panicf("WithPolymorphicType: %s is not an interface", iface)
+	}
+	if !concrete.Implements(iface) && !reflect.PointerTo(concrete).Implements(iface) {
+		log.Panicf("WithPolymorphicType: %s does not implement %s", concrete, iface)
+	}
+
+	return func(c *Config) {
+		types := make(map[reflect.Type]map[string]reflect.Type, len(c.PolymorphicTypes)+1)
+		for t, names := range c.PolymorphicTypes {
+			types[t] = names
+		}
+		names := make(map[string]reflect.Type, len(types[iface])+1)
+		for n, t := range types[iface] {
+			names[n] = t
+		}
+		names[name] = concrete
+		types[iface] = names
+		c.PolymorphicTypes = types
+	}
+}
+
+func (p *Polymorphic[I]) UnmarshalJSON(data []byte) error {
+	iface := reflect.TypeFor[I]()
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		var zero I
+		p.Value = zero
+		return nil
+	}
+
+	var head struct {
+		Type *string `json:"@type"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return err
+	}
+	if head.Type == nil {
+		return &json.UnmarshalTypeError{Value: "object without " + TypeDiscriminator, Type: iface}
+	}
+
+	concrete, ok := global.get().PolymorphicTypes[iface][*head.Type]
+	if !ok {
+		return &json.UnmarshalTypeError{Value: fmt.Sprintf("%s %q", TypeDiscriminator, *head.Type), Type: iface}
+	}
+
+	ptr := reflect.New(concrete)
+	if err := jsonUnmarshal(data, ptr.Interface()); err != nil {
+		return err
+	}
+
+	if v, ok := ptr.Elem().Interface().(I); ok {
+		p.Value = v
+	} else {
+		p.Value = ptr.Interface().(I)
+	}
+	return nil
+}
+
+func (p Polymorphic[I]) MarshalJSON() ([]byte, error) {
+	rv := reflect.ValueOf(&p.Value).Elem()
+	if rv.IsNil() {
+		return []byte("null"), nil
+	}
+
+	data, err := json.Marshal(p.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	name, ok := polymorphicName(reflect.TypeFor[I](), rv.Elem().Type())
+	if !ok || len(data) < 2 || data[0] != '{' {
+		return data, nil
+	}
+
+	key, _ := json.Marshal(TypeDiscriminator)
+	value, _ := json.Marshal(name)
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	buf.Write(key)
+	buf.WriteByte(':')
+	buf.Write(value)
+	if !bytes.Equal(data, []byte("{}")) {
+		buf.WriteByte(',')
+	}
+	buf.Write(data[1:])
+	return buf.Bytes(), nil
+}
+
+// polymorphicName finds the name registered for the dynamic type t of interface iface
+func polymorphicName(iface, t reflect.Type) (string, bool) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for name, concrete := range global.get().PolymorphicTypes[iface] {
+		if concrete == t {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,130 @@
+package m
+
+import (
+	"encoding/json"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type Shape interface {
+	Area() float64
+}
+
+type Circle struct {
+	Radius float64 `json:"radius"`
+}
+
+func (c Circle) Area() float64 { return math.Pi * c.Radius * c.Radius }
+
+type Square struct {
+	Side float64 `json:"side"`
+}
+
+func (s *Square) Area() float64 { return s.Side * s.Side }
+
+type Drawing struct {
+	Title  string               `json:"title"`
+	Shapes []Polymorphic[Shape] `json:"shapes"`
+	Main   Polymorphic[Shape]   `json:"main"`
+	Extra  *Polymorphic[Shape]  `json:"extra,omitempty"`
+}
+
+func TestPolymorphic(t *testing.T) {
+	configure := func() {
+		Reset()
+		Configure(
+			WithPolymorphicType[Shape, Circle]("circle"),
+			WithPolymorphicType[Shape, Square]("square"),
+		)
+	}
+
+	t.Run("decodes concrete types behind one interface", func(t *testing.T) {
+		configure()
+		defer Reset()
+
+		body := `{
+			"title": "doodle",
+			"shapes": [{"@type": "circle", "radius": 1}, {"@type": "square", "side": 2}],
+			"main": {"@type": "square", "side": 3}
+		}`
+		var j JSON[Drawing]
+		if err := j.Extract(httptest.NewRequest("POST", "/", strings.NewReader(body))); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+
+		if c, ok := j.Value.Shapes[0].Value.(Circle); !ok || c.Radius != 1 {
+			t.Errorf("expected Circle{1}, got %#v", j.Value.Shapes[0].Value)
+		}
+		if s, ok := j.Value.Shapes[1].Value.(*Square); !ok || s.Side != 2 {
+			t.Errorf("expected *Square{2}, got %#v", j.Value.Shapes[1].Value)
+		}
+		if j.Value.Main.Value.Area() != 9 {
+			t.Errorf("expected area 9, got %v", j.Value.Main.Value.Area())
+		}
+	})
+
+	t.Run("unknown or missing type is a 400", func(t *testing.T) {
+		configure()
+		defer Reset()
+
+		handler := H(func(d JSON[Drawing]) Drawing { return d.Value })
+		for _, body := range []string{
+			`{"main": {"@type": "hexagon", "side": 1}}`,
+			`{"main": {"side": 1}}`,
+		} {
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+			if rec.Code != 400 {
+				t.Errorf("%s: expected status 400, got %d", body, rec.Code)
+			}
+		}
+
+		var j JSON[Drawing]
+		err := j.Extract(httptest.NewRequest("POST", "/", strings.NewReader(`{"main": {"@type": "hexagon"}}`)))
+		if msg := ToHTTPError(err).Message; msg != `expected m.Shape but got @type "hexagon"` {
+			t.Errorf("unexpected message: %s", msg)
+		}
+	})
+
+	t.Run("null leaves the value empty", func(t *testing.T) {
+		configure()
+		defer Reset()
+
+		var d Drawing
+		if err := json.Unmarshal([]byte(`{"main": null}`), &d); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if d.Main.Value != nil {
+			t.Errorf("expected nil value, got %#v", d.Main.Value)
+		}
+	})
+
+	t.Run("encodes the discriminator", func(t *testing.T) {
+		configure()
+		defer Reset()
+
+		d := Drawing{
+			Title:  "out",
+			Shapes: []Polymorphic[Shape]{{Value: Circle{Radius: 2}}, {Value: &Square{}}},
+		}
+		data, err := json.Marshal(d)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		expected := `{"title":"out","shapes":[{"@type":"circle","radius":2},{"@type":"square","side":0}],"main":null}`
+		if string(data) != expected {
+			t.Errorf("expected %s, got %s", expected, data)
+		}
+	})
+
+	t.Run("registering a non-implementing type panics", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic")
+			}
+		}()
+		WithPolymorphicType[Shape, User]("user")
+	})
+}
@@ -0,0 +1,61 @@
+package m
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Problem is an RFC 7807 problem details response. Extensions are merged into
+// the JSON object next to the standard members. An extension named after a
+// standard member (type, title, status, detail or instance) is always dropped,
+// even when that member is empty and so omitted, as the fields alone describe
+// the problem. It is sent as application/problem+json with Status as the
+// response code and status member, or 500 for both if Status is zero
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]any
+}
+
+// status returns the response code p is sent with
+func (p Problem) status() int {
+	if p.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return p.Status
+}
+
+func (p Problem) Respond(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.status())
+
+	if err := jsonEncode(w, p); err != nil {
+		logger().Printf("failed to write problem response: %v", err)
+	}
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+
+	for name, value := range map[string]string{
+		"type":     p.Type,
+		"title":    p.Title,
+		"detail":   p.Detail,
+		"instance": p.Instance,
+	} {
+		if value != "" {
+			members[name] = value
+		} else {
+			delete(members, name)
+		}
+	}
+	members["status"] = p.status()
+
+	return json.Marshal(members)
+}
@@ -0,0 +1,95 @@
+package m
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProblem(t *testing.T) {
+	t.Run("standard members and extensions", func(t *testing.T) {
+		Reset()
+		handler := H(func() Problem {
+			return Problem{
+				Type:     "https://example.com/probs/out-of-credit",
+				Title:    "You do not have enough credit.",
+				Status:   403,
+				Detail:   "Your current balance is 30, but that costs 50.",
+				Instance: "/account/12345/msgs/abc",
+				Extensions: map[string]any{
+					"balance":  30,
+					"accounts": []string{"/account/12345", "/account/67890"},
+				},
+			}
+		})
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Code != 403 {
+			t.Errorf("expected status 403, got %d", rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("unexpected Content-Type: %s", ct)
+		}
+
+		var body map[string]any
+		parseJSONResponse(t, rec.Body.Bytes(), &body)
+		if body["title"] != "You do not have enough credit." || body["status"] != float64(403) {
+			t.Errorf("unexpected standard members: %v", body)
+		}
+		if body["instance"] != "/account/12345/msgs/abc" {
+			t.Errorf("unexpected instance: %v", body["instance"])
+		}
+		if body["balance"] != float64(30) {
+			t.Errorf("expected balance extension, got %v", body["balance"])
+		}
+		if accounts, ok := body["accounts"].([]any); !ok || len(accounts) != 2 {
+			t.Errorf("expected accounts extension, got %v", body["accounts"])
+		}
+	})
+
+	t.Run("standard members win over extensions", func(t *testing.T) {
+		Reset()
+		handler := H(func() *Problem {
+			return &Problem{
+				Title:      "Conflict",
+				Status:     409,
+				Extensions: map[string]any{"title": "overridden", "status": 200, "detail": "stale"},
+			}
+		})
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Code != 409 {
+			t.Errorf("expected status 409, got %d", rec.Code)
+		}
+
+		var body map[string]any
+		parseJSONResponse(t, rec.Body.Bytes(), &body)
+		if body["title"] != "Conflict" || body["status"] != float64(409) {
+			t.Errorf("unexpected members: %v", body)
+		}
+		if _, ok := body["detail"]; ok {
+			t.Errorf("expected empty detail to be omitted, got %v", body["detail"])
+		}
+	})
+
+	t.Run("zero status defaults to 500", func(t *testing.T) {
+		Reset()
+		handler := H(func() Problem { return Problem{Title: "Oops"} })
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Code != 500 {
+			t.Errorf("expected status 500, got %d", rec.Code)
+		}
+
+		var body map[string]any
+		parseJSONResponse(t, rec.Body.Bytes(), &body)
+		if body["status"] != float64(500) {
+			t.Errorf("expected status member 500, got %v", body)
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package m
+
+import "net/http"
+
+// Redirect is a response that redirects the client to URL with Code, which
+// defaults to 302 Found. Codes outside the 3xx range are logged and replaced by 302.
+// Relative URLs are resolved against the request path, as by http.Redirect
+type Redirect struct {
+	URL  string
+	Code int
+}
+
+func (rd Redirect) Respond(w http.ResponseWriter) {
+	code := rd.Code
+	if code == 0 {
+		code = http.StatusFound
+	} else if code < 300 || code > 399 {
+		logger().Printf("Warning: redirect to %q with non-3xx status %d, using 302", rd.URL, code)
+		code = http.StatusFound
+	}
+
+	if r := requestOf(w); r != nil {
+		http.Redirect(w, r, rd.URL, code)
+		return
+	}
+	w.Header().Set("Location", rd.URL)
+	w.WriteHeader(code)
+}
@@ -0,0 +1,63 @@
+package m
+
+import (
+	"bytes"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedirect(t *testing.T) {
+	tests := []struct {
+		name     string
+		redirect Redirect
+		code     int
+		location string
+	}{
+		{"defaults to 302", Redirect{URL: "/login"}, 302, "/login"},
+		{"explicit code", Redirect{URL: "https://example.com/new", Code: 301}, 301, "https://example.com/new"},
+		{"see other", Redirect{URL: "/orders/42", Code: 303}, 303, "/orders/42"},
+		{"relative to the request path", Redirect{URL: "edit"}, 302, "/users/edit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Reset()
+			rec := httptest.NewRecorder()
+			H(func() Redirect { return tt.redirect })(rec, httptest.NewRequest("GET", "/users/7", nil))
+
+			if rec.Code != tt.code {
+				t.Errorf("expected status %d, got %d", tt.code, rec.Code)
+			}
+			if loc := rec.Header().Get("Location"); loc != tt.location {
+				t.Errorf("expected Location %q, got %q", tt.location, loc)
+			}
+		})
+	}
+
+	t.Run("non-3xx code is coerced to 302", func(t *testing.T) {
+		Reset()
+		var buf bytes.Buffer
+		Configure(WithLogger(log.New(&buf, "", 0)))
+		defer Reset()
+
+		rec := httptest.NewRecorder()
+		H(func() Redirect { return Redirect{URL: "/login", Code: 200} })(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Code != 302 || rec.Header().Get("Location") != "/login" {
+			t.Errorf("expected a 302 to /login, got %d %q", rec.Code, rec.Header().Get("Location"))
+		}
+		if !strings.Contains(buf.String(), "non-3xx status 200") {
+			t.Errorf("expected a warning, got %q", buf.String())
+		}
+	})
+
+	t.Run("outside H", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		Redirect{URL: "/login", Code: 307}.Respond(rec)
+		if rec.Code != 307 || rec.Header().Get("Location") != "/login" {
+			t.Errorf("expected a 307 to /login, got %d %q", rec.Code, rec.Header().Get("Location"))
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package m
+
+import (
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Render is a response that executes an HTML template with Data.
+// The template is streamed straight to the client rather than buffered, and
+// flushed every few kilobytes, or when output stalls, so large pages start
+// arriving early.
+// If Name is empty, the template itself is executed
+type Render struct {
+	Template *template.Template
+	Name     string
+	Data     any
+}
+
+func (rd Render) Respond(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	fw := &flushWriter{w: w, rc: http.NewResponseController(w)}
+	defer func() {
+		if err := fw.Close(); err != nil {
+			logger().Printf("failed to flush rendered template: %v", err)
+		}
+	}()
+
+	var err error
+	if rd.Name == "" {
+		err = rd.Template.Execute(fw, rd.Data)
+	} else {
+		err = rd.Template.ExecuteTemplate(fw, rd.Name, rd.Data)
+	}
+
+	// Part of the page may already be sent, so all that is left to do is log
+	if err != nil {
+		logger().Printf("failed to render template: %v", err)
+	}
+}
+
+// Render flushes the page once this much is pending, or once output has been
+// pending for renderFlushInterval, so the client gets it in reasonably sized chunks
+const (
+	renderFlushBytes    = 4 << 10
+	renderFlushInterval = 50 * time.Millisecond
+)
+
+// flushWriter flushes the response periodically, if the writer supports it:
+// when renderFlushBytes are pending, or renderFlushInterval after the first
+// pending write, e.g. while the template waits on slow data. Close flushes the rest
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+
+	mu      sync.Mutex
+	pending int
+	timer   *time.Timer
+	closed  bool
+	// err is a flush error from the timer, reported by the next Write
+	err error
+}
+
+func (fw *flushWriter) Write(b []byte) (int, error) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if fw.err != nil {
+		return 0, fw.err
+	}
+	n, err := fw.w.Write(b)
+	fw.pending += n
+	if err != nil {
+		return n, err
+	}
+
+	if fw.pending >= renderFlushBytes {
+		return n, fw.flush()
+	}
+	if fw.timer == nil {
+		fw.timer = time.AfterFunc(renderFlushInterval, fw.flushPending)
+	}
+	return n, nil
+}
+
+// flushPending runs on the timer, flushing unless the writer was closed meanwhile
+func (fw *flushWriter) flushPending() {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	fw.timer = nil
+	if !fw.closed && fw.pending > 0 {
+		fw.err = fw.flush()
+	}
+}
+
+// flush sends the pending output; fw.mu must be held
+func (fw *flushWriter) flush() error {
+	if fw.timer != nil {
+		fw.timer.Stop()
+		fw.timer = nil
+	}
+	fw.pending = 0
+	if err := fw.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// Close flushes what is still pending and stops the timer.
+// The response must not be touched through fw afterwards
+func (fw *flushWriter) Close() error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	fw.closed = true
+	if fw.pending == 0 {
+		if fw.timer != nil {
+			fw.timer.Stop()
+			fw.timer = nil
+		}
+		return nil
+	}
+	return fw.flush()
+}
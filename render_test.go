@@ -0,0 +1,132 @@
+package m
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flushRecorder is a ResponseRecorder that counts flushes and remembers
+// what had been written at the time of each one
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	mu      sync.Mutex
+	flushed []string
+}
+
+func newFlushRecorder() *flushRecorder {
+	return &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+}
+
+func (fr *flushRecorder) Write(b []byte) (int, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return fr.ResponseRecorder.Write(b)
+}
+
+func (fr *flushRecorder) Flush() {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.flushed = append(fr.flushed, fr.Body.String())
+	fr.ResponseRecorder.Flush()
+}
+
+func (fr *flushRecorder) snapshots() []string {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return append([]string(nil), fr.flushed...)
+}
+
+func TestRender(t *testing.T) {
+	t.Run("renders named template", func(t *testing.T) {
+		tmpl := template.Must(template.New("page").Parse(`{{define "greet"}}<p>Hello, {{.}}</p>{{end}}`))
+		handler := H(func() Render {
+			return Render{Template: tmpl, Name: "greet", Data: "<World>"}
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+			t.Errorf("unexpected content type: %s", rec.Header().Get("Content-Type"))
+		}
+		if rec.Body.String() != "<p>Hello, &lt;World&gt;</p>" {
+			t.Errorf("unexpected body: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("flushes before the template completes", func(t *testing.T) {
+		reached := make(chan struct{})
+		proceed := make(chan struct{})
+		tmpl := template.Must(template.New("page").Funcs(template.FuncMap{
+			"slow": func() string {
+				close(reached)
+				<-proceed
+				return "slow part"
+			},
+		}).Parse(`<h1>{{.}}</h1>{{slow}}<footer>end</footer>`))
+
+		handler := H(func() Render {
+			return Render{Template: tmpl, Data: "Report"}
+		})
+
+		rec := newFlushRecorder()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			handler(rec, httptest.NewRequest("GET", "/", nil))
+		}()
+
+		<-reached
+		// The head is flushed once it has been pending for renderFlushInterval
+		deadline := time.Now().Add(time.Second)
+		for len(rec.snapshots()) == 0 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		snapshots := rec.snapshots()
+		if len(snapshots) == 0 {
+			t.Fatal("expected output to be flushed before the slow part")
+		}
+		if last := snapshots[len(snapshots)-1]; last != "<h1>Report</h1>" {
+			t.Errorf("unexpected flushed output: %q", last)
+		}
+
+		close(proceed)
+		<-done
+		if !strings.HasSuffix(rec.Body.String(), "slow part<footer>end</footer>") {
+			t.Errorf("unexpected body: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("flushes in chunks, and at the end", func(t *testing.T) {
+		tmpl := template.Must(template.New("page").Parse(`<ul>{{range .}}<li>{{.}}</li>{{end}}</ul>`))
+		items := make([]int, 2000)
+		for i := range items {
+			items[i] = i
+		}
+
+		rec := newFlushRecorder()
+		Render{Template: tmpl, Data: items}.Respond(rec)
+
+		snapshots := rec.snapshots()
+		body := rec.Body.String()
+		if n := len(snapshots); n < 2 || n > len(body)/renderFlushBytes+3 {
+			t.Errorf("expected a flush per %d bytes of %d, got %d flushes", renderFlushBytes, len(body), n)
+		}
+		if snapshots[len(snapshots)-1] != body {
+			t.Error("expected the whole page to be flushed at the end")
+		}
+	})
+
+	t.Run("logs execution errors", func(t *testing.T) {
+		tmpl := template.Must(template.New("page").Parse(`ok {{.Missing.Field}}`))
+		rec := httptest.NewRecorder()
+		Render{Template: tmpl, Data: map[string]any{}}.Respond(rec)
+		if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Body.String(), "ok") {
+			t.Errorf("unexpected response: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package m
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+// RequestIDHeader is the header a request ID is read from and echoed in
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps client-supplied IDs; longer ones are replaced
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestID returns the ID H assigned to the request, or "" if there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// withRequestID reuses the client's X-Request-ID or generates a new one,
+// sets it on the response and returns r with the ID in its context
+func withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	generate := global.get().RequestIDGenerator
+	if generate == nil {
+		return r
+	}
+
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" || len(id) > maxRequestIDLength {
+		id = generate()
+	}
+
+	w.Header().Set(RequestIDHeader, id)
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+}
+
+// newRequestID returns a random version 4 UUID
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,101 @@
+package m
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	t.Run("generated and stored in context", func(t *testing.T) {
+		Reset()
+		handler := H(func(r *http.Request) string {
+			return RequestID(r.Context())
+		})
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+
+		id := rec.Header().Get(RequestIDHeader)
+		if !uuidPattern.MatchString(id) {
+			t.Errorf("expected a UUID, got %q", id)
+		}
+		if rec.Body.String() != id {
+			t.Errorf("expected context ID %q, got %q", id, rec.Body.String())
+		}
+	})
+
+	t.Run("reuses client ID", func(t *testing.T) {
+		Reset()
+		handler := H(func(r *http.Request) string {
+			return RequestID(r.Context())
+		})
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(RequestIDHeader, "client-123")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		if got := rec.Header().Get(RequestIDHeader); got != "client-123" {
+			t.Errorf("expected client ID, got %q", got)
+		}
+		if rec.Body.String() != "client-123" {
+			t.Errorf("expected context ID client-123, got %q", rec.Body.String())
+		}
+	})
+
+	t.Run("replaces overlong client ID", func(t *testing.T) {
+		Reset()
+		Configure(WithRequestIDGenerator(func() string { return "generated" }))
+		defer Reset()
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(RequestIDHeader, strings.Repeat("x", 500))
+		rec := httptest.NewRecorder()
+		H(func() string { return "ok" })(rec, req)
+
+		if got := rec.Header().Get(RequestIDHeader); got != "generated" {
+			t.Errorf("expected generated ID, got %q", got)
+		}
+	})
+
+	t.Run("set on error responses", func(t *testing.T) {
+		Reset()
+		Configure(WithRequestIDGenerator(func() string { return "req-1" }))
+		defer Reset()
+
+		var seen string
+		Configure(WithErrorHandler(func(w http.ResponseWriter, err error) {
+			seen = w.Header().Get(RequestIDHeader)
+			w.WriteHeader(500)
+		}))
+
+		rec := httptest.NewRecorder()
+		H(func() error { return errors.New("boom") })(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Header().Get(RequestIDHeader) != "req-1" || seen != "req-1" {
+			t.Errorf("expected request ID on error response, got header %q, handler saw %q",
+				rec.Header().Get(RequestIDHeader), seen)
+		}
+	})
+
+	t.Run("disabled with nil generator", func(t *testing.T) {
+		Reset()
+		Configure(WithRequestIDGenerator(nil))
+		defer Reset()
+
+		rec := httptest.NewRecorder()
+		H(func(r *http.Request) string {
+			return RequestID(r.Context())
+		})(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Header().Get(RequestIDHeader) != "" || rec.Body.String() != "" {
+			t.Errorf("expected no request ID, got %q", rec.Header().Get(RequestIDHeader))
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package m
+
+import (
+	"net/http"
+	"time"
+)
+
+// Default timeouts for servers created by Server. There is no read or write
+// timeout by default, so large uploads and long-running streaming responses
+// are not cut off; Config.BodyReadTimeout bounds body reads instead
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+	defaultMaxHeaderBytes    = 1 << 20
+)
+
+// ServerOption configures an http.Server created by Server
+type ServerOption func(*http.Server)
+
+// Server returns an http.Server for handler listening on addr, with timeouts set.
+// http.ListenAndServe uses none, leaving the server open to slow clients
+// holding connections forever
+func Server(addr string, handler http.Handler, opts ...ServerOption) *http.Server {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: defaultReadHeaderTimeout,
+		IdleTimeout:       defaultIdleTimeout,
+		MaxHeaderBytes:    defaultMaxHeaderBytes,
+	}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	return srv
+}
+
+// WithReadHeaderTimeout sets how long the server waits to read request headers
+func WithReadHeaderTimeout(d time.Duration) ServerOption {
+	return func(s *http.Server) {
+		s.ReadHeaderTimeout = d
+	}
+}
+
+// WithReadTimeout sets how long the server allows for reading an entire request
+func WithReadTimeout(d time.Duration) ServerOption {
+	return func(s *http.Server) {
+		s.ReadTimeout = d
+	}
+}
+
+// WithWriteTimeout sets how long the server allows for writing a response
+func WithWriteTimeout(d time.Duration) ServerOption {
+	return func(s *http.Server) {
+		s.WriteTimeout = d
+	}
+}
+
+// WithIdleTimeout sets how long an idle keep-alive connection is kept open
+func WithIdleTimeout(d time.Duration) ServerOption {
+	return func(s *http.Server) {
+		s.IdleTimeout = d
+	}
+}
+
+// WithMaxHeaderBytes sets the maximum size of request headers
+func WithMaxHeaderBytes(n int) ServerOption {
+	return func(s *http.Server) {
+		s.MaxHeaderBytes = n
+	}
+}
+
+// WithKeepAlives enables/disables HTTP keep-alives
+func WithKeepAlives(enabled bool) ServerOption {
+	return func(s *http.Server) {
+		s.SetKeepAlivesEnabled(enabled)
+	}
+}
@@ -0,0 +1,68 @@
+package m
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	handler := http.NewServeMux()
+
+	t.Run("defaults", func(t *testing.T) {
+		srv := Server(":8080", handler)
+		if srv.Addr != ":8080" || srv.Handler != handler {
+			t.Errorf("unexpected addr or handler: %s", srv.Addr)
+		}
+		if srv.ReadHeaderTimeout != 10*time.Second {
+			t.Errorf("expected ReadHeaderTimeout 10s, got %v", srv.ReadHeaderTimeout)
+		}
+		if srv.ReadTimeout != 0 {
+			t.Errorf("expected no ReadTimeout, got %v", srv.ReadTimeout)
+		}
+		if srv.IdleTimeout != 120*time.Second {
+			t.Errorf("expected IdleTimeout 120s, got %v", srv.IdleTimeout)
+		}
+		if srv.WriteTimeout != 0 {
+			t.Errorf("expected no WriteTimeout, got %v", srv.WriteTimeout)
+		}
+		if srv.MaxHeaderBytes != 1<<20 {
+			t.Errorf("expected MaxHeaderBytes 1MiB, got %d", srv.MaxHeaderBytes)
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		srv := Server(":8080", handler,
+			WithReadHeaderTimeout(2*time.Second),
+			WithReadTimeout(5*time.Second),
+			WithWriteTimeout(15*time.Second),
+			WithIdleTimeout(time.Minute),
+			WithMaxHeaderBytes(4096),
+		)
+		if srv.ReadHeaderTimeout != 2*time.Second || srv.ReadTimeout != 5*time.Second ||
+			srv.WriteTimeout != 15*time.Second || srv.IdleTimeout != time.Minute {
+			t.Errorf("unexpected timeouts: %+v", srv)
+		}
+		if srv.MaxHeaderBytes != 4096 {
+			t.Errorf("expected MaxHeaderBytes 4096, got %d", srv.MaxHeaderBytes)
+		}
+	})
+
+	t.Run("keep-alives disabled", func(t *testing.T) {
+		srv := Server("", H(func() string { return "ok" }), WithKeepAlives(false))
+		ts := httptest.NewUnstartedServer(srv.Handler)
+		ts.Config = srv
+		ts.Start()
+		defer ts.Close()
+
+		resp, err := ts.Client().Get(ts.URL)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if !resp.Close {
+			t.Error("expected the server to close the connection")
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package m
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Fields extracts a sparse fieldset from the "fields" query parameter,
+// e.g. "?fields=id,name" gives Value ["id", "name"]. Value is empty when
+// the parameter is absent, meaning all fields
+type Fields struct {
+	Value []string
+}
+
+func (f *Fields) Extract(r *http.Request) error {
+	f.Value = nil
+	for _, param := range r.URL.Query()["fields"] {
+		for _, name := range strings.Split(param, ",") {
+			if name = strings.TrimSpace(name); name != "" && !slices.Contains(f.Value, name) {
+				f.Value = append(f.Value, name)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply filters data down to the requested fields, see Sparse
+func (f Fields) Apply(data any) (json.RawMessage, error) {
+	return Sparse(data, f.Value)
+}
+
+// Sparse encodes data as JSON, keeping only the given top-level fields, named
+// as they appear in the JSON. Objects keep their original field order, and
+// arrays of objects are filtered element by element. Other values, or an empty
+// fields list, leave the encoding as is.
+// The result can be returned straight from a handler as (json.RawMessage, error)
+func Sparse(data any, fields []string) (json.RawMessage, error) {
+	raw, err := json.Marshal(data)
+	if err != nil || len(fields) == 0 {
+		return raw, err
+	}
+
+	switch firstByte(raw) {
+	case '{':
+		om, err := filterObject(raw, fields)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(om)
+	case '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+		filtered := make([]any, len(items))
+		for i, item := range items {
+			filtered[i] = item
+			if firstByte(item) == '{' {
+				if filtered[i], err = filterObject(item, fields); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return json.Marshal(filtered)
+	}
+	return raw, nil
+}
+
+// filterObject decodes a JSON object into an OrderedMap holding only the given keys
+func filterObject(raw []byte, fields []string) (OrderedMap, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil { // opening brace
+		return nil, err
+	}
+
+	om := OrderedMap{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		if key := tok.(string); slices.Contains(fields, key) {
+			om = append(om, Pair{Key: key, Value: value})
+		}
+	}
+	return om, nil
+}
+
+func firstByte(raw []byte) byte {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return 0
+	}
+	return raw[0]
+}
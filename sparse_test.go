@@ -0,0 +1,84 @@
+package m
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestSparse(t *testing.T) {
+	type Account struct {
+		ID           int    `json:"id"`
+		Name         string `json:"name"`
+		Email        string `json:"email"`
+		PasswordHash string `json:"password_hash"`
+	}
+	alice := Account{ID: 1, Name: "Alice", Email: "alice@example.com", PasswordHash: "x"}
+
+	t.Run("filters struct keeping field order", func(t *testing.T) {
+		Reset()
+		handler := H(func(fields Fields) (json.RawMessage, error) {
+			return fields.Apply(alice)
+		})
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/?fields=name,id", nil))
+		if body := rec.Body.String(); body != `{"id":1,"name":"Alice"}` {
+			t.Errorf("unexpected body: %s", body)
+		}
+	})
+
+	t.Run("filters slice elements", func(t *testing.T) {
+		Reset()
+		handler := H(func(fields Fields) (json.RawMessage, error) {
+			return fields.Apply([]*Account{&alice, {ID: 2, Name: "Bob"}})
+		})
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/?fields=id&fields=email", nil))
+		if body := rec.Body.String(); body != `[{"id":1,"email":"alice@example.com"},{"id":2,"email":""}]` {
+			t.Errorf("unexpected body: %s", body)
+		}
+	})
+
+	t.Run("no fields keeps all of them", func(t *testing.T) {
+		raw, err := Sparse(alice, nil)
+		if err != nil || string(raw) != `{"id":1,"name":"Alice","email":"alice@example.com","password_hash":"x"}` {
+			t.Errorf("unexpected result: %s (err %v)", raw, err)
+		}
+
+		raw, err = Sparse("text", []string{"id"})
+		if err != nil || string(raw) != `"text"` {
+			t.Errorf("expected non-object unchanged, got %s (err %v)", raw, err)
+		}
+	})
+
+	t.Run("unknown fields are dropped", func(t *testing.T) {
+		raw, err := Sparse(alice, []string{"nope"})
+		if err != nil || string(raw) != `{}` {
+			t.Errorf("expected empty object, got %s (err %v)", raw, err)
+		}
+	})
+
+	t.Run("marshal error", func(t *testing.T) {
+		if _, err := Sparse(make(chan int), []string{"id"}); err == nil {
+			t.Error("expected marshal error")
+		}
+	})
+}
+
+func TestFieldsExtractor(t *testing.T) {
+	var f Fields
+	if err := f.Extract(httptest.NewRequest("GET", "/?fields=id,%20name,,id&fields=email", nil)); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if expected := []string{"id", "name", "email"}; !slices.Equal(f.Value, expected) {
+		t.Errorf("expected %v, got %v", expected, f.Value)
+	}
+
+	f = Fields{}
+	if err := f.Extract(httptest.NewRequest("GET", "/", nil)); err != nil || len(f.Value) != 0 {
+		t.Errorf("expected no fields, got %v (err %v)", f.Value, err)
+	}
+}
@@ -0,0 +1,125 @@
+package m
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ErrSSEClosed is returned by SSE.Send once the stream has ended
+var ErrSSEClosed = errors.New("sse: stream closed")
+
+// SSE is a Server-Sent Events response. Return it from a handler and push events
+// with Send from another goroutine; each event is flushed as soon as it is written.
+// The stream ends when Close is called or ctx, normally the request context, is done
+//
+//	mux.HandleFunc("GET /events", m.H(func(r *http.Request) *m.SSE {
+//		sse := m.NewSSE(r.Context())
+//		go func() {
+//			defer sse.Close()
+//			for price := range prices.Subscribe(r.Context()) {
+//				if sse.Send("price", price.String()) != nil {
+//					return
+//				}
+//			}
+//		}()
+//		return sse
+//	}))
+type SSE struct {
+	ctx       context.Context
+	events    chan sseEvent
+	closed    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	doneOnce  sync.Once
+}
+
+type sseEvent struct {
+	name string
+	data string
+}
+
+// NewSSE creates an event stream that ends when ctx is done
+func NewSSE(ctx context.Context) *SSE {
+	return &SSE{
+		ctx:    ctx,
+		events: make(chan sseEvent),
+		closed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Send writes an event to the client and blocks until it is flushed. An empty
+// event name sends an unnamed "message" event, and multi-line data is split into
+// several data lines. It returns ErrSSEClosed or the context error once the stream has ended
+func (s *SSE) Send(event, data string) error {
+	select {
+	case s.events <- sseEvent{name: event, data: data}:
+		return nil
+	case <-s.closed:
+		return ErrSSEClosed
+	case <-s.done:
+		return ErrSSEClosed
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// Close ends the stream after the events already sent
+func (s *SSE) Close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+}
+
+func (s *SSE) Respond(w http.ResponseWriter) {
+	defer s.doneOnce.Do(func() { close(s.done) })
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Ask reverse proxies such as nginx not to buffer the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logger().Printf("failed to flush event stream: %v", err)
+		return
+	}
+
+	for {
+		select {
+		case ev := <-s.events:
+			if _, err := w.Write(ev.encode()); err != nil {
+				logger().Printf("failed to write event stream: %v", err)
+				return
+			}
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				logger().Printf("failed to flush event stream: %v", err)
+				return
+			}
+		case <-s.closed:
+			return
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// encode formats the event in the text/event-stream format
+func (ev sseEvent) encode() []byte {
+	var b strings.Builder
+	if ev.name != "" {
+		b.WriteString("event: ")
+		b.WriteString(ev.name)
+		b.WriteByte('\n')
+	}
+	data := strings.ReplaceAll(ev.data, "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: ")
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
@@ -0,0 +1,131 @@
+package m
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSE(t *testing.T) {
+	t.Run("sends and flushes events", func(t *testing.T) {
+		Reset()
+		handler := H(func(r *http.Request) *SSE {
+			sse := NewSSE(r.Context())
+			go func() {
+				defer sse.Close()
+				sse.Send("", "hello")
+				sse.Send("update", `{"id":1}`)
+				sse.Send("multi", "line 1\nline 2")
+			}()
+			return sse
+		})
+
+		rec := newFlushRecorder()
+		handler(rec, httptest.NewRequest("GET", "/events", nil))
+
+		if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		if cc := rec.Header().Get("Cache-Control"); cc != "no-cache" {
+			t.Errorf("unexpected cache control %q", cc)
+		}
+
+		expected := "data: hello\n\n" +
+			"event: update\ndata: {\"id\":1}\n\n" +
+			"event: multi\ndata: line 1\ndata: line 2\n\n"
+		if rec.Body.String() != expected {
+			t.Errorf("unexpected body:\n%s", rec.Body.String())
+		}
+
+		// One flush for the headers, then one per event
+		snapshots := rec.snapshots()
+		if len(snapshots) != 4 {
+			t.Fatalf("expected 4 flushes, got %d: %q", len(snapshots), snapshots)
+		}
+		if snapshots[1] != "data: hello\n\n" {
+			t.Errorf("expected the first event to be flushed on its own, got %q", snapshots[1])
+		}
+	})
+
+	t.Run("ends when the request is canceled", func(t *testing.T) {
+		Reset()
+		ctx, cancel := context.WithCancel(context.Background())
+		sendErr := make(chan error, 1)
+		handler := H(func(r *http.Request) *SSE {
+			sse := NewSSE(r.Context())
+			go func() {
+				for {
+					if err := sse.Send("tick", "."); err != nil {
+						sendErr <- err
+						return
+					}
+				}
+			}()
+			time.AfterFunc(20*time.Millisecond, cancel)
+			return sse
+		})
+
+		done := make(chan struct{})
+		go func() {
+			handler(newFlushRecorder(), httptest.NewRequest("GET", "/events", nil).WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("stream did not end after the request was canceled")
+		}
+		select {
+		case err := <-sendErr:
+			if !errors.Is(err, context.Canceled) && !errors.Is(err, ErrSSEClosed) {
+				t.Errorf("unexpected send error %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Send did not return after the stream ended")
+		}
+	})
+
+	t.Run("send after close", func(t *testing.T) {
+		sse := NewSSE(context.Background())
+		sse.Close()
+		if err := sse.Send("late", "x"); !errors.Is(err, ErrSSEClosed) {
+			t.Errorf("expected ErrSSEClosed, got %v", err)
+		}
+	})
+
+	t.Run("flushes through the response writer", func(t *testing.T) {
+		Reset()
+		ready := make(chan struct{})
+		handler := H(func(r *http.Request) *SSE {
+			sse := NewSSE(r.Context())
+			go func() {
+				defer sse.Close()
+				sse.Send("first", "1")
+				<-ready
+			}()
+			return sse
+		})
+
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		// The first event must arrive while the stream is still open
+		buf := make([]byte, 64)
+		n, err := resp.Body.Read(buf)
+		if err != nil || !strings.Contains(string(buf[:n]), "event: first") {
+			t.Errorf("expected the first event before the stream ended, got %q (%v)", buf[:n], err)
+		}
+		close(ready)
+	})
+}
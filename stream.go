@@ -0,0 +1,152 @@
+package m
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+)
+
+// defaultStreamFlushEvery is how many elements JSONStream writes between flushes by default
+const defaultStreamFlushEvery = 100
+
+// JSONStream is a response that writes Items as a JSON array one element at a time,
+// so memory use stays bounded however many elements there are. The response is
+// flushed every FlushEvery elements (100 if zero).
+// Streaming stops early if Context is canceled or a write fails; the array is then
+// left unterminated, since part of it has already been sent
+type JSONStream[T any] struct {
+	Context    context.Context
+	Items      iter.Seq[T]
+	FlushEvery int
+}
+
+// StreamChan returns a JSONStream over the values received from ch until it is closed
+func StreamChan[T any](ctx context.Context, ch <-chan T) JSONStream[T] {
+	return JSONStream[T]{
+		Context: ctx,
+		Items: func(yield func(T) bool) {
+			for {
+				select {
+				case v, ok := <-ch:
+					if !ok || !yield(v) {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		},
+	}
+}
+
+func (s JSONStream[T]) Respond(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	ctx := s.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	flushEvery := s.FlushEvery
+	if flushEvery <= 0 {
+		flushEvery = defaultStreamFlushEvery
+	}
+	rc := http.NewResponseController(w)
+
+	if _, err := w.Write([]byte("[")); err != nil {
+		logger().Printf("failed to write JSON stream: %v", err)
+		return
+	}
+
+	n := 0
+	if s.Items != nil {
+		for item := range s.Items {
+			if err := ctx.Err(); err != nil {
+				logger().Printf("JSON stream stopped: %v", err)
+				return
+			}
+
+			if n > 0 {
+				if _, err := w.Write([]byte(",")); err != nil {
+					logger().Printf("failed to write JSON stream: %v", err)
+					return
+				}
+			}
+			if err := jsonEncode(w, item); err != nil {
+				logger().Printf("failed to write JSON stream: %v", err)
+				return
+			}
+
+			n++
+			if n%flushEvery == 0 {
+				if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+					logger().Printf("failed to flush JSON stream: %v", err)
+					return
+				}
+			}
+		}
+	}
+
+	// A source such as StreamChan ends quietly on cancellation; the array is still incomplete
+	if err := ctx.Err(); err != nil {
+		logger().Printf("JSON stream stopped: %v", err)
+		return
+	}
+
+	if _, err := w.Write([]byte("]")); err != nil {
+		logger().Printf("failed to write JSON stream: %v", err)
+	}
+}
+
+// Stream is a response whose body is written by the function itself, for output
+// produced incrementally, e.g. m.Stream(func(w io.Writer) error { ... }).
+// Every write is flushed to the client. In handlers wrapped with H, writes fail
+// with the context error once the request's context is done, so the function
+// can stop when the client goes away. An error returned before anything was
+// written is sent as an error response; after that it can only be logged
+type Stream func(w io.Writer) error
+
+func (s Stream) Respond(w http.ResponseWriter) {
+	ctx := context.Background()
+	if r := requestOf(w); r != nil {
+		ctx = r.Context()
+	}
+
+	sw := &streamWriter{ctx: ctx, w: w, rc: http.NewResponseController(w)}
+	err := s(sw)
+	if err == nil {
+		return
+	}
+	if !sw.written {
+		if e := handleError(w, err); e != nil {
+			logger().Printf("failed to write error response: %v", e)
+		}
+		return
+	}
+	logger().Printf("stream stopped: %v", err)
+}
+
+// streamWriter flushes every write and refuses writes once ctx is done
+type streamWriter struct {
+	ctx     context.Context
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	written bool
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if err := sw.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	sw.written = true
+	n, err := sw.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if err := sw.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return n, err
+	}
+	return n, nil
+}
@@ -0,0 +1,318 @@
+package m
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestJSONStream(t *testing.T) {
+	t.Run("array from channel", func(t *testing.T) {
+		Reset()
+		handler := H(func(r *http.Request) JSONStream[User] {
+			ch := make(chan User)
+			go func() {
+				defer close(ch)
+				for _, name := range []string{"Alice", "Bob", "Carol"} {
+					ch <- User{Name: name}
+				}
+			}()
+			return StreamChan(r.Context(), ch)
+		})
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("unexpected Content-Type: %s", ct)
+		}
+		var users []User
+		if err := json.Unmarshal(rec.Body.Bytes(), &users); err != nil {
+			t.Fatalf("invalid JSON array %q: %v", rec.Body.String(), err)
+		}
+		if len(users) != 3 || users[0].Name != "Alice" || users[2].Name != "Carol" {
+			t.Errorf("unexpected users: %+v", users)
+		}
+	})
+
+	t.Run("empty source", func(t *testing.T) {
+		Reset()
+		ch := make(chan int)
+		close(ch)
+
+		rec := httptest.NewRecorder()
+		StreamChan(context.Background(), ch).Respond(rec)
+		if rec.Body.String() != "[]" {
+			t.Errorf("expected [], got %q", rec.Body.String())
+		}
+
+		rec = httptest.NewRecorder()
+		JSONStream[int]{}.Respond(rec)
+		if rec.Body.String() != "[]" {
+			t.Errorf("expected [] for nil Items, got %q", rec.Body.String())
+		}
+	})
+
+	t.Run("flushes periodically", func(t *testing.T) {
+		Reset()
+		rec := newFlushRecorder()
+		JSONStream[int]{Items: slices.Values([]int{1, 2, 3, 4, 5}), FlushEvery: 2}.Respond(rec)
+
+		if rec.Body.String() != "[1,2,3,4,5]" {
+			t.Errorf("unexpected body: %q", rec.Body.String())
+		}
+		expected := []string{"[1,2", "[1,2,3,4"}
+		if got := rec.snapshots(); !slices.Equal(got, expected) {
+			t.Errorf("expected flushes %q, got %q", expected, got)
+		}
+	})
+
+	t.Run("stops on context cancellation", func(t *testing.T) {
+		Reset()
+		ctx, cancel := context.WithCancel(context.Background())
+		items := func(yield func(int) bool) {
+			for i := 1; ; i++ {
+				if i == 3 {
+					cancel()
+				}
+				if !yield(i) {
+					return
+				}
+			}
+		}
+
+		rec := httptest.NewRecorder()
+		JSONStream[int]{Context: ctx, Items: items}.Respond(rec)
+		if body := rec.Body.String(); body != "[1,2" {
+			t.Errorf("expected stream to stop after cancellation, got %q", body)
+		}
+	})
+
+	t.Run("channel source stops on cancellation", func(t *testing.T) {
+		Reset()
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := make(chan int) // never closed
+		go func() {
+			ch <- 1
+			cancel()
+		}()
+
+		// Respond must return even though ch is never closed
+		rec := httptest.NewRecorder()
+		StreamChan(ctx, ch).Respond(rec)
+		if body := rec.Body.String(); !strings.HasPrefix(body, "[") || strings.HasSuffix(body, "]") {
+			t.Errorf("expected an unterminated array, got %q", body)
+		}
+	})
+
+	t.Run("source ending after cancellation", func(t *testing.T) {
+		Reset()
+		ctx, cancel := context.WithCancel(context.Background())
+		items := func(yield func(int) bool) {
+			_ = yield(1) && yield(2)
+			cancel()
+		}
+
+		rec := httptest.NewRecorder()
+		JSONStream[int]{Context: ctx, Items: items}.Respond(rec)
+		if body := rec.Body.String(); body != "[1,2" {
+			t.Errorf("expected an unterminated array, got %q", body)
+		}
+	})
+
+	t.Run("channel canceled before the first value", func(t *testing.T) {
+		Reset()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		rec := httptest.NewRecorder()
+		StreamChan(ctx, make(chan int)).Respond(rec)
+		if body := rec.Body.String(); body != "[" {
+			t.Errorf("expected an unterminated array, got %q", body)
+		}
+	})
+}
+
+// plainWriter hides the recorder's Flush method, like writers that cannot flush
+type plainWriter struct {
+	rec *httptest.ResponseRecorder
+}
+
+func (p plainWriter) Header() http.Header         { return p.rec.Header() }
+func (p plainWriter) Write(b []byte) (int, error) { return p.rec.Write(b) }
+func (p plainWriter) WriteHeader(code int)        { p.rec.WriteHeader(code) }
+
+func TestJSONStreamWithoutFlusher(t *testing.T) {
+	Reset()
+	items := make([]int, 250)
+	rec := httptest.NewRecorder()
+	JSONStream[int]{Items: slices.Values(items)}.Respond(plainWriter{rec})
+
+	var got []int
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || len(got) != 250 {
+		t.Errorf("expected a complete array of 250, got %d elements (%v)", len(got), err)
+	}
+}
+
+func TestJSONStreamThreshold(t *testing.T) {
+	users := func(n int) []User {
+		list := make([]User, n)
+		for i := range list {
+			list[i] = User{Name: "user", Age: i}
+		}
+		return list
+	}
+
+	get := func(handler any) *flushRecorder {
+		rec := newFlushRecorder()
+		H(handler)(rec, httptest.NewRequest("GET", "/", nil))
+		return rec
+	}
+
+	t.Run("small responses get Content-Length", func(t *testing.T) {
+		Reset()
+		Configure(WithJSONStreamThreshold(10))
+		defer Reset()
+
+		for name, handler := range map[string]any{
+			"struct":       func() User { return User{Name: "Ann"} },
+			"short slice":  func() []User { return users(10) },
+			"ordered map":  func() OrderedMap { return OrderedMap{{Key: "a", Value: 1}} },
+			"result slice": func() Result[[]User] { return OK(users(3)).WithStatus(201) },
+		} {
+			rec := get(handler)
+			if cl := rec.Header().Get("Content-Length"); cl != strconv.Itoa(rec.Body.Len()) {
+				t.Errorf("%s: expected Content-Length %d, got %q", name, rec.Body.Len(), cl)
+			}
+			if !json.Valid(rec.Body.Bytes()) {
+				t.Errorf("%s: invalid JSON %q", name, rec.Body.String())
+			}
+		}
+	})
+
+	t.Run("long slices are streamed", func(t *testing.T) {
+		Reset()
+		Configure(WithJSONStreamThreshold(10))
+		defer Reset()
+
+		rec := get(func() []User { return users(250) })
+		if cl := rec.Header().Get("Content-Length"); cl != "" {
+			t.Errorf("expected no Content-Length, got %q", cl)
+		}
+		if len(rec.snapshots()) < 2 {
+			t.Errorf("expected intermediate flushes, got %d", len(rec.snapshots()))
+		}
+		var got []User
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("invalid JSON array: %v", err)
+		}
+		if !slices.Equal(got, users(250)) {
+			t.Error("streamed array does not match the data")
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		Reset()
+		rec := get(func() []User { return users(250) })
+		if cl := rec.Header().Get("Content-Length"); cl != "" {
+			t.Errorf("expected no Content-Length, got %q", cl)
+		}
+		if n := len(rec.snapshots()); n != 0 {
+			t.Errorf("expected no flushes, got %d", n)
+		}
+	})
+}
+
+func TestStream(t *testing.T) {
+	t.Run("flushes each chunk", func(t *testing.T) {
+		Reset()
+		handler := H(func() Stream {
+			return func(w io.Writer) error {
+				for i := 1; i <= 3; i++ {
+					if _, err := fmt.Fprintf(w, "chunk %d\n", i); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		})
+
+		rec := newFlushRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Code != 200 || rec.Body.String() != "chunk 1\nchunk 2\nchunk 3\n" {
+			t.Fatalf("unexpected response: %d %q", rec.Code, rec.Body.String())
+		}
+		expected := []string{"chunk 1\n", "chunk 1\nchunk 2\n", "chunk 1\nchunk 2\nchunk 3\n"}
+		if got := rec.snapshots(); !slices.Equal(got, expected) {
+			t.Errorf("expected a flush per chunk, got %q", got)
+		}
+	})
+
+	t.Run("stops when the request is canceled", func(t *testing.T) {
+		Reset()
+		ctx, cancel := context.WithCancel(context.Background())
+		var writeErr error
+		handler := H(func() Stream {
+			return func(w io.Writer) error {
+				for i := 0; ; i++ {
+					if i == 2 {
+						cancel()
+					}
+					if _, err := fmt.Fprintf(w, "%d;", i); err != nil {
+						writeErr = err
+						return err
+					}
+				}
+			}
+		})
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+		if !errors.Is(writeErr, context.Canceled) {
+			t.Errorf("expected writes to fail with context.Canceled, got %v", writeErr)
+		}
+		if rec.Body.String() != "0;1;" {
+			t.Errorf("unexpected body %q", rec.Body.String())
+		}
+	})
+
+	t.Run("error before writing", func(t *testing.T) {
+		Reset()
+		handler := H(func() Stream {
+			return func(w io.Writer) error {
+				return &HTTPError{Code: 503, Err: "service_unavailable"}
+			}
+		})
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != 503 {
+			t.Errorf("expected status 503, got %d", rec.Code)
+		}
+	})
+
+	t.Run("error after writing is logged", func(t *testing.T) {
+		Reset()
+		handler := H(func() Stream {
+			return func(w io.Writer) error {
+				io.WriteString(w, "partial")
+				return errors.New("producer failed")
+			}
+		})
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != 200 || rec.Body.String() != "partial" {
+			t.Errorf("unexpected response: %d %q", rec.Code, rec.Body.String())
+		}
+	})
+}
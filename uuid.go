@@ -0,0 +1,48 @@
+package m
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// UUID is a string holding a well-formed UUID in its canonical hyphenated form,
+// e.g. "0b9f3b6e-2f4a-4c1e-9d3a-6b1f0e2c7a55". Path[UUID] rejects anything else
+// with 400 invalid_path_parameter, as do query, form, header, cookie and MultiPath
+// fields of this type. Values are normalized to lowercase. Convert it with your
+// UUID library of choice, e.g. uuid.MustParse(string(id.Value))
+type UUID string
+
+// ParseUUID checks that s is a UUID in the canonical 8-4-4-4-12 hex form
+// and returns it in lowercase
+func ParseUUID(s string) (UUID, error) {
+	if len(s) != 36 {
+		return "", fmt.Errorf("invalid UUID length %d", len(s))
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return "", fmt.Errorf("invalid UUID format")
+			}
+		default:
+			if !isHexDigit(c) {
+				return "", fmt.Errorf("invalid UUID character %q", c)
+			}
+		}
+	}
+	return UUID(strings.ToLower(s)), nil
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func convertUUID(value string) reflect.Value {
+	id, err := ParseUUID(value)
+	if err != nil {
+		return reflect.Value{}
+	}
+	return reflect.ValueOf(id)
+}
@@ -0,0 +1,85 @@
+package m
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseUUID(t *testing.T) {
+	tests := []struct {
+		input string
+		want  UUID
+		ok    bool
+	}{
+		{"0b9f3b6e-2f4a-4c1e-9d3a-6b1f0e2c7a55", "0b9f3b6e-2f4a-4c1e-9d3a-6b1f0e2c7a55", true},
+		{"0B9F3B6E-2F4A-4C1E-9D3A-6B1F0E2C7A55", "0b9f3b6e-2f4a-4c1e-9d3a-6b1f0e2c7a55", true},
+		{"00000000-0000-0000-0000-000000000000", "00000000-0000-0000-0000-000000000000", true},
+		{"0b9f3b6e2f4a4c1e9d3a6b1f0e2c7a55", "", false},
+		{"0b9f3b6e-2f4a-4c1e-9d3a-6b1f0e2c7a5", "", false},
+		{"0b9f3b6e-2f4a-4c1e-9d3a_6b1f0e2c7a55", "", false},
+		{"0b9f3b6g-2f4a-4c1e-9d3a-6b1f0e2c7a55", "", false},
+		{"{0b9f3b6e-2f4a-4c1e-9d3a-6b1f0e2c7a}", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseUUID(tt.input)
+			if (err == nil) != tt.ok {
+				t.Fatalf("expected ok=%v, got error %v", tt.ok, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUUIDExtraction(t *testing.T) {
+	const id = "0b9f3b6e-2f4a-4c1e-9d3a-6b1f0e2c7a55"
+
+	t.Run("path parameter", func(t *testing.T) {
+		Reset()
+		handler := H(func(p Path[UUID]) string { return string(p.Value) })
+
+		rec := httptest.NewRecorder()
+		req := createRequestWithPattern("GET", "/users/"+id, "/users/{id}")
+		req.SetPathValue("id", id)
+		handler(rec, req)
+		if rec.Code != 200 || rec.Body.String() != id {
+			t.Errorf("unexpected response %d %q", rec.Code, rec.Body.String())
+		}
+
+		rec = httptest.NewRecorder()
+		req = createRequestWithPattern("GET", "/users/42", "/users/{id}")
+		req.SetPathValue("id", "42")
+		handler(rec, req)
+		if rec.Code != 400 {
+			t.Fatalf("expected status 400, got %d", rec.Code)
+		}
+		var body HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &body)
+		if body.Err != "invalid_path_parameter" {
+			t.Errorf("expected invalid_path_parameter, got %+v", body)
+		}
+	})
+
+	t.Run("query field", func(t *testing.T) {
+		Reset()
+		type filter struct {
+			Owner UUID `schema:"owner"`
+		}
+		handler := H(func(q Query[filter]) string { return string(q.Value.Owner) })
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/?owner="+id, nil))
+		if rec.Code != 200 || rec.Body.String() != id {
+			t.Errorf("unexpected response %d %q", rec.Code, rec.Body.String())
+		}
+
+		rec = httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/?owner=nope", nil))
+		if rec.Code != 400 {
+			t.Errorf("expected status 400, got %d", rec.Code)
+		}
+	})
+}
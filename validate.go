@@ -0,0 +1,193 @@
+//go:build !novalidator
+
+package m
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Validator validates extracted values, see Config.Validator.
+// Build with the novalidator tag to drop the dependency and skip validation
+type Validator = validator.Validate
+
+// ValidationFunc is the signature of a custom validation rule, see WithValidationRule
+type ValidationFunc = validator.Func
+
+// WithValidator sets a custom validator
+func WithValidator(v *Validator) Option {
+	return func(c *Config) {
+		c.Validator = v
+		c.customValidator = v != nil
+	}
+}
+
+// WithValidationRule adds a custom validation tag to the default validator and to
+// every validation group, so a rule can be added without replacing the validator
+// via WithValidator. The validators are rebuilt with the rule rather than changed
+// in place, so requests already using them are not raced. It panics if the rule
+// cannot be registered, or if the validator came from WithValidator; register
+// rules on such a validator before passing it in
+func WithValidationRule(tag string, fn ValidationFunc) Option {
+	return func(c *Config) {
+		if c.Validator == nil {
+			log.Panicf("WithValidationRule: no validator configured for rule %q", tag)
+		}
+		if c.customValidator {
+			log.Panicf("WithValidationRule: cannot add rule %q to a validator set with WithValidator", tag)
+		}
+
+		rules := append(slices.Clip(c.validationRules), validationRule{tag: tag, fn: fn})
+		v, err := newRuleValidator("validate", rules)
+		if err != nil {
+			log.Panicf("WithValidationRule: %v", err)
+		}
+		groups := make(map[string]*Validator, len(c.ValidationGroups))
+		for name := range c.ValidationGroups {
+			if groups[name], err = newRuleValidator("validate_"+name, rules); err != nil {
+				log.Panicf("WithValidationRule: %v", err)
+			}
+		}
+		c.Validator, c.ValidationGroups, c.validationRules = v, groups, rules
+	}
+}
+
+// WithValidationGroup registers a validation group. Requests validated in the group
+// (see ValidateAs) check the `validate_<group>` struct tags instead of `validate`,
+// so one struct can carry different rules for, say, create and update.
+// Rules added with WithValidationRule apply to every group
+func WithValidationGroup(group string) Option {
+	return func(c *Config) {
+		if group == "" {
+			log.Panic("WithValidationGroup: group name must not be empty")
+		}
+
+		v, err := newRuleValidator("validate_"+group, c.validationRules)
+		if err != nil {
+			log.Panicf("WithValidationGroup: %v", err)
+		}
+
+		groups := make(map[string]*Validator, len(c.ValidationGroups)+1)
+		for name, gv := range c.ValidationGroups {
+			groups[name] = gv
+		}
+		groups[group] = v
+		c.ValidationGroups = groups
+	}
+}
+
+// newDefaultValidator creates a validator with sensible defaults
+func newDefaultValidator() *Validator {
+	v := validator.New()
+	// Use json tag as field name for validation errors
+	v.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+		// Fallback to form tag, then to the schema tag used by Query and Form
+		for _, tag := range []string{"form", "schema"} {
+			name = strings.SplitN(fld.Tag.Get(tag), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return ""
+	})
+	return v
+}
+
+// newRuleValidator creates a default validator reading tagName struct tags,
+// with rules registered on it
+func newRuleValidator(tagName string, rules []validationRule) (*Validator, error) {
+	v := newDefaultValidator()
+	v.SetTagName(tagName)
+	for _, rule := range rules {
+		if err := v.RegisterValidation(rule.tag, rule.fn); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+type validationRule struct {
+	tag string
+	fn  ValidationFunc
+}
+
+// formatValidationError formats validation errors into user-friendly messages
+func formatValidationError(err error) string {
+	var ve validator.ValidationErrors
+	if !errors.As(err, &ve) {
+		return err.Error()
+	}
+
+	if len(ve) == 0 {
+		return "validation failed"
+	}
+
+	messages := make([]string, 0, len(ve))
+	for _, fe := range ve {
+		field := fe.Field()
+		if field == "" {
+			field = fe.StructField()
+		}
+
+		msg := formatFieldError(field, fe)
+		messages = append(messages, msg)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// formatFieldError formats a single field validation error
+func formatFieldError(field string, fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", field)
+	case "min":
+		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+	case "max":
+		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
+	case "len":
+		return fmt.Sprintf("%s must be %s characters long", field, fe.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, fe.Param())
+	case "gte":
+		return fmt.Sprintf("%s must be greater than or equal to %s", field, fe.Param())
+	case "lt":
+		return fmt.Sprintf("%s must be less than %s", field, fe.Param())
+	case "lte":
+		return fmt.Sprintf("%s must be less than or equal to %s", field, fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s]", field, fe.Param())
+	case "url":
+		return fmt.Sprintf("%s must be a valid URL", field)
+	case "uri":
+		return fmt.Sprintf("%s must be a valid URI", field)
+	case "alpha":
+		return fmt.Sprintf("%s must contain only letters", field)
+	case "alphanum":
+		return fmt.Sprintf("%s must contain only letters and numbers", field)
+	case "numeric":
+		return fmt.Sprintf("%s must be numeric", field)
+	case "uuid":
+		return fmt.Sprintf("%s must be a valid UUID", field)
+	default:
+		return fmt.Sprintf("%s failed validation (%s)", field, fe.Tag())
+	}
+}
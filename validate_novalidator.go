@@ -0,0 +1,57 @@
+//go:build novalidator
+
+package m
+
+import "log"
+
+// Validator stands in for the go-playground validator in builds with the
+// novalidator tag. It accepts every value, so validation is skipped
+type Validator struct{}
+
+func (*Validator) Struct(any) error { return nil }
+
+func (*Validator) StructPartial(any, ...string) error { return nil }
+
+// ValidationFunc is the signature of a custom validation rule. In builds with
+// the novalidator tag, rules are accepted but never run
+type ValidationFunc func(field any) bool
+
+// WithValidator sets a custom validator
+func WithValidator(v *Validator) Option {
+	return func(c *Config) {
+		c.Validator = v
+		c.customValidator = v != nil
+	}
+}
+
+// WithValidationRule does nothing in builds with the novalidator tag
+func WithValidationRule(tag string, fn ValidationFunc) Option {
+	return func(c *Config) {}
+}
+
+// WithValidationGroup registers a validation group, so ValidateAs accepts it.
+// In builds with the novalidator tag, its values are not validated
+func WithValidationGroup(group string) Option {
+	return func(c *Config) {
+		if group == "" {
+			log.Panic("WithValidationGroup: group name must not be empty")
+		}
+
+		groups := make(map[string]*Validator, len(c.ValidationGroups)+1)
+		for name, gv := range c.ValidationGroups {
+			groups[name] = gv
+		}
+		groups[group] = &Validator{}
+		c.ValidationGroups = groups
+	}
+}
+
+type validationRule struct{}
+
+func newDefaultValidator() *Validator {
+	return &Validator{}
+}
+
+func formatValidationError(err error) string {
+	return err.Error()
+}
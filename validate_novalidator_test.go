@@ -0,0 +1,44 @@
+//go:build novalidator
+
+package m
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// requireValidator skips a test of validation behavior in builds with the novalidator tag
+func requireValidator(t *testing.T) {
+	t.Helper()
+	t.Skip("validation is disabled in builds with the novalidator tag")
+}
+
+func TestNoValidator(t *testing.T) {
+	Reset()
+	Configure(
+		WithValidationRule("slug", func(any) bool { return false }),
+		WithValidationGroup("update"),
+	)
+	defer Reset()
+
+	type Signup struct {
+		Email string `json:"email" validate:"required,email"`
+		Slug  string `json:"slug" validate:"slug"`
+	}
+	handler := H(func(body JSON[Signup]) string {
+		return "ok"
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{"email":"not-an-email"}`)))
+	if rec.Code != 200 || rec.Body.String() != "ok" {
+		t.Errorf("expected validation to be skipped, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	ValidateAs("update")(handler).ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{}`)))
+	if rec.Code != 200 {
+		t.Errorf("expected validation groups to be accepted, got %d", rec.Code)
+	}
+}
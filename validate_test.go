@@ -0,0 +1,323 @@
+//go:build !novalidator
+
+package m
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/schema"
+)
+
+// requireValidator skips a test of validation behavior in builds with the novalidator tag
+func requireValidator(t *testing.T) {}
+
+func TestCustomValidator(t *testing.T) {
+	t.Run("custom validator with custom rule", func(t *testing.T) {
+		Reset()
+
+		v := validator.New()
+		v.RegisterValidation("isalice", func(fl validator.FieldLevel) bool {
+			return fl.Field().String() == "alice"
+		})
+
+		Initialize(
+			WithValidator(v),
+		)
+
+		type Request struct {
+			Username string `json:"username" validate:"required,isalice"`
+		}
+
+		handler := H(func(body JSON[Request]) Request {
+			return body.Value
+		})
+
+		// Test valid case
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"username":"alice"}`))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		if rec.Code != 200 {
+			t.Errorf("expected status 200 for valid username, got %d", rec.Code)
+		}
+
+		// Test invalid case
+		req = httptest.NewRequest("POST", "/", strings.NewReader(`{"username":"bob"}`))
+		rec = httptest.NewRecorder()
+		handler(rec, req)
+
+		if rec.Code == 200 {
+			t.Error("expected validation error for invalid username")
+		}
+	})
+
+	t.Run("rule registered on the default validator", func(t *testing.T) {
+		Reset()
+		defer Reset()
+
+		Configure(WithValidationRule("slug", func(fl validator.FieldLevel) bool {
+			s := fl.Field().String()
+			return s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyz0123456789-") == ""
+		}))
+
+		type Request struct {
+			Slug  string `json:"slug" validate:"slug"`
+			Email string `json:"email" validate:"omitempty,email"`
+		}
+
+		handler := H(func(body JSON[Request]) Request {
+			return body.Value
+		})
+
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"slug":"hello-world"}`))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != 200 {
+			t.Errorf("expected status 200 for valid slug, got %d", rec.Code)
+		}
+
+		req = httptest.NewRequest("POST", "/", strings.NewReader(`{"slug":"Hello World"}`))
+		rec = httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != 400 {
+			t.Fatalf("expected status 400 for invalid slug, got %d", rec.Code)
+		}
+		var httpErr HTTPError
+		parseJSONResponse(t, rec.Body.Bytes(), &httpErr)
+		if !strings.Contains(httpErr.Message, "slug") {
+			t.Errorf("expected message to name the field, got %q", httpErr.Message)
+		}
+
+		// Built-in rules and the json field names are kept
+		req = httptest.NewRequest("POST", "/", strings.NewReader(`{"slug":"ok","email":"nope"}`))
+		rec = httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != 400 {
+			t.Errorf("expected status 400 for invalid email, got %d", rec.Code)
+		}
+	})
+
+	t.Run("rule swaps in a new validator while serving", func(t *testing.T) {
+		Reset()
+		defer Reset()
+
+		type Request struct {
+			Name string `json:"name" validate:"required"`
+		}
+		handler := H(func(body JSON[Request]) Request { return body.Value })
+		before := global.get().Validator
+
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 20 {
+					rec := httptest.NewRecorder()
+					handler(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"Ann"}`)))
+					if rec.Code != 200 {
+						t.Errorf("expected status 200, got %d", rec.Code)
+					}
+				}
+			}()
+		}
+		for i := range 5 {
+			Configure(WithValidationRule(fmt.Sprintf("rule%d", i), func(validator.FieldLevel) bool { return true }))
+		}
+		wg.Wait()
+
+		if global.get().Validator == before {
+			t.Error("expected the rule to be added to a new validator")
+		}
+		// An undefined tag panics, so the rule never reached the serving validator
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the serving validator to be left unchanged")
+			}
+		}()
+		before.Var("x", "rule0")
+	})
+
+	t.Run("rule on a validator set with WithValidator panics", func(t *testing.T) {
+		Reset()
+		defer Reset()
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic for a custom validator")
+			}
+		}()
+		Configure(WithValidator(validator.New()), WithValidationRule("slug", func(validator.FieldLevel) bool { return true }))
+	})
+
+	t.Run("invalid rule panics", func(t *testing.T) {
+		Reset()
+		defer Reset()
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic for empty tag")
+			}
+		}()
+		Configure(WithValidationRule("", func(validator.FieldLevel) bool { return true }))
+	})
+}
+
+func TestValidationGroups(t *testing.T) {
+	type Account struct {
+		Name  string `json:"name" validate:"required" validate_update:"omitempty,min=2"`
+		Email string `json:"email" validate:"required,email" validate_update:"omitempty,email"`
+		Role  string `json:"role" validate:"omitempty,role" validate_update:"omitempty,role"`
+	}
+
+	setup := func() http.Handler {
+		Reset()
+		Configure(
+			WithValidationGroup("update"),
+			WithValidationRule("role", func(fl validator.FieldLevel) bool {
+				return fl.Field().String() == "admin" || fl.Field().String() == "member"
+			}),
+		)
+		mux := http.NewServeMux()
+		mux.Handle("POST /accounts", H(func(a JSON[Account]) Account { return a.Value }))
+		mux.Handle("PUT /accounts/{id}", ValidateAs("update")(H(func(a JSON[Account]) Account { return a.Value })))
+		mux.Handle("PATCH /accounts/{id}", ValidateAs("update")(H(func(a Patch[Account]) Account { return a.Value })))
+		return mux
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"create requires all fields", "POST", "/accounts", `{"name":"Ann"}`, 400},
+		{"create accepts full body", "POST", "/accounts", `{"name":"Ann","email":"ann@example.com"}`, 200},
+		{"update allows missing fields", "PUT", "/accounts/1", `{"email":"ann@example.com"}`, 200},
+		{"update applies its own rules", "PUT", "/accounts/1", `{"name":"A"}`, 400},
+		{"update checks email format", "PUT", "/accounts/1", `{"email":"nope"}`, 400},
+		{"patch uses the group", "PATCH", "/accounts/1", `{"name":"A"}`, 400},
+		{"custom rule applies to create", "POST", "/accounts", `{"name":"Ann","email":"ann@example.com","role":"root"}`, 400},
+		{"custom rule applies to group", "PUT", "/accounts/1", `{"role":"root"}`, 400},
+		{"custom rule passes in group", "PUT", "/accounts/1", `{"role":"admin"}`, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := setup()
+			defer Reset()
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("rules added before the group apply to it", func(t *testing.T) {
+		Reset()
+		defer Reset()
+		Configure(
+			WithValidationRule("role", func(fl validator.FieldLevel) bool { return fl.Field().String() == "admin" }),
+			WithValidationGroup("update"),
+		)
+		handler := ValidateAs("update")(H(func(a JSON[Account]) Account { return a.Value }))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("PUT", "/", strings.NewReader(`{"role":"root"}`)))
+		if rec.Code != 400 {
+			t.Errorf("expected status 400, got %d", rec.Code)
+		}
+	})
+
+	t.Run("unknown group panics at construction", func(t *testing.T) {
+		Reset()
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic for unregistered group")
+			}
+		}()
+		ValidateAs("missing")
+	})
+
+	t.Run("group removed later is a 500", func(t *testing.T) {
+		Reset()
+		Configure(WithValidationGroup("update"), WithLogger(log.New(io.Discard, "", 0)))
+		handler := ValidateAs("update")(H(func(a JSON[Account]) Account { return a.Value }))
+		Reset()
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("PUT", "/", strings.NewReader(`{"name":"Ann"}`)))
+		if rec.Code != 500 {
+			t.Errorf("expected status 500, got %d", rec.Code)
+		}
+	})
+}
+
+func TestCompleteConfigurationScenario(t *testing.T) {
+	t.Run("full custom configuration", func(t *testing.T) {
+		Reset()
+
+		var logBuf bytes.Buffer
+		customLogger := log.New(&logBuf, "[CUSTOM] ", 0)
+
+		v := validator.New()
+		v.RegisterValidation("positive", func(fl validator.FieldLevel) bool {
+			return fl.Field().Int() > 0
+		})
+
+		decoder := schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+
+		errorHandlerCalled := false
+
+		Initialize(
+			WithLogger(customLogger),
+			WithValidator(v),
+			WithSchemaDecoder(decoder),
+			WithValidation(true),
+			WithJSONMarshal(func(v any) ([]byte, error) {
+				return json.MarshalIndent(v, "", "  ")
+			}),
+			WithErrorHandler(func(w http.ResponseWriter, err error) {
+				errorHandlerCalled = true
+				w.WriteHeader(400)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "custom handler",
+				})
+			}),
+		)
+
+		// Test that custom config is used
+		type Request struct {
+			Count int `json:"count" validate:"required,positive"`
+		}
+
+		handler := H(func(body JSON[Request]) Request {
+			return body.Value
+		})
+
+		// Invalid request (negative number)
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"count":-5}`))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		if !errorHandlerCalled {
+			t.Error("custom error handler should have been called")
+		}
+
+		if rec.Code != 400 {
+			t.Errorf("expected status 400, got %d", rec.Code)
+		}
+	})
+}